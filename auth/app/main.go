@@ -10,12 +10,16 @@ import (
 	_authHandler "github.com/halilylm/secondhand/auth/auth/delivery/http"
 	"github.com/halilylm/secondhand/auth/auth/repository/mongodb"
 	"github.com/halilylm/secondhand/auth/auth/usecase"
+	"github.com/halilylm/secondhand/auth/domain"
+	"github.com/halilylm/secondhand/auth/mailer"
+	"github.com/halilylm/secondhand/auth/password"
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"time"
 )
 
@@ -34,7 +38,7 @@ func main() {
 	appLogger.Init()
 
 	// env variables checkpoint
-	utils.RequireEnvVariables("MONGO_URI", "APP_PORT", "JWT_KEY")
+	utils.RequireEnvVariables("MONGO_URI", "APP_PORT", "JWT_KEY", "MAGIC_LINK_URL")
 
 	// connect to mongodb
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -46,12 +50,58 @@ func main() {
 
 	// init collections
 	userCollection := client.Database("auth").Collection("users")
+	magicLinkCollection := client.Database("auth").Collection("magic_links")
+	magicLinkRequestCollection := client.Database("auth").Collection("magic_link_requests")
+
+	// create indexes
+	if err := mongodb.CreateMagicLinkIndexes(ctx, magicLinkCollection, magicLinkRequestCollection); err != nil {
+		appLogger.Fatal(err)
+	}
 
 	// init repositories
 	userRepo := mongodb.NewUserRepository(userCollection)
+	magicLinkRepo := mongodb.NewMagicLinkRepository(magicLinkCollection, magicLinkRequestCollection)
+
+	// init mailer
+	// mails are only logged when smtp is not configured
+	var appMailer domain.Mailer
+	if os.Getenv("SMTP_HOST") != "" {
+		appMailer = mailer.NewSMTPMailer(
+			os.Getenv("SMTP_HOST"),
+			os.Getenv("SMTP_PORT"),
+			os.Getenv("SMTP_USERNAME"),
+			os.Getenv("SMTP_PASSWORD"),
+			os.Getenv("MAIL_FROM"),
+		)
+	} else {
+		appMailer = mailer.NewLogMailer(appLogger)
+	}
+
+	// init password policy
+	// defaults can be overridden by env variables
+	passwordOpts := password.DefaultOptions()
+	if v, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH")); err == nil {
+		passwordOpts.MinLength = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("PASSWORD_MAX_LENGTH")); err == nil {
+		passwordOpts.MaxLength = v
+	}
+	passwordOpts.RequireSymbol = os.Getenv("PASSWORD_REQUIRE_SYMBOL") == "true"
+	passwordOpts.Breached = password.BundledBreachedList()
+	if path := os.Getenv("PASSWORD_BREACHED_LIST"); path != "" {
+		breached, err := password.LoadBreachedList(path)
+		if err != nil {
+			appLogger.Fatal(err)
+		}
+		passwordOpts.Breached.Merge(breached)
+	}
+	passwordPolicy := password.NewPolicy(passwordOpts)
 
 	// init usecases
-	authUC := usecase.NewAuth(userRepo, appLogger)
+	authUC := usecase.NewAuth(userRepo, magicLinkRepo, appMailer, passwordPolicy, usecase.MagicLinkOptions{
+		Secret:      []byte(os.Getenv("JWT_KEY")),
+		CallbackURL: os.Getenv("MAGIC_LINK_URL"),
+	}, appLogger)
 
 	// set routes
 	e := echo.New()
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/halilylm/gommon/middlewares"
@@ -21,11 +22,15 @@ func NewAuthHandler(g *echo.Group, authUC usecase.Auth) {
 	g.POST("/signup", handler.SignUp)
 	g.GET("/signout", handler.SignOut)
 	g.POST("/signin", handler.SignIn)
+	g.POST("/magiclink", handler.RequestMagicLink)
+	g.GET("/magiclink/callback", handler.MagicLinkCallback)
+	g.POST("/password/reset", handler.ResetPassword)
 
 	// jwt middleware
 	g.Use(middlewares.CurrentUser("jwt"))
 
 	g.GET("/currentuser", handler.CurrentUser)
+	g.PUT("/password", handler.ChangePassword)
 }
 
 // SignUp the user
@@ -96,6 +101,98 @@ func (a *authHandler) SignIn(c echo.Context) error {
 	return c.JSON(http.StatusCreated, loginUser)
 }
 
+// RequestMagicLink sends a sign-in link to the user
+func (a *authHandler) RequestMagicLink(c echo.Context) error {
+	var req domain.MagicLinkRequest
+
+	// bind request body to magic link request
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewBadRequestError(err.Error())))
+	}
+
+	// validate the struct
+	if err := utils.ValidateStruct(&req); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewValidationErrors(err)))
+	}
+
+	// call the usecase
+	if err := a.authUC.RequestMagicLink(c.Request().Context(), req.Email); err != nil {
+		if errors.Is(err, domain.ErrTooManyMagicLinks) {
+			return c.JSON(rest.ErrorResponse(rest.NewRestError(http.StatusTooManyRequests, err.Error(), nil)))
+		}
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+// MagicLinkCallback signs in the user with the magic link token
+func (a *authHandler) MagicLinkCallback(c echo.Context) error {
+	// call the usecase
+	loginUser, err := a.authUC.SignInWithMagicLink(c.Request().Context(), c.QueryParam("token"))
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	// generate jwt token
+	token, err := utils.GenerateJWTToken(loginUser.Email, loginUser.ID)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewInternalServerError()))
+	}
+
+	// set the cookie
+	setCookie(c, token)
+
+	return c.JSON(http.StatusOK, loginUser)
+}
+
+// ChangePassword of the current user
+func (a *authHandler) ChangePassword(c echo.Context) error {
+	var change domain.PasswordChange
+
+	// bind request body to password change
+	if err := c.Bind(&change); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewBadRequestError(err.Error())))
+	}
+
+	// validate the struct
+	if err := utils.ValidateStruct(&change); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewValidationErrors(err)))
+	}
+
+	// get user from the context
+	user := middlewares.UserFromContext(c)
+
+	// call the usecase
+	if err := a.authUC.ChangePassword(c.Request().Context(), user.ID, &change); err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ResetPassword with the token of a magic link
+func (a *authHandler) ResetPassword(c echo.Context) error {
+	var reset domain.PasswordReset
+
+	// bind request body to password reset
+	if err := c.Bind(&reset); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewBadRequestError(err.Error())))
+	}
+
+	// validate the struct
+	if err := utils.ValidateStruct(&reset); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewValidationErrors(err)))
+	}
+
+	// call the usecase
+	if err := a.authUC.ResetPassword(c.Request().Context(), &reset); err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
 // CurrentUser returns the current user
 func (a *authHandler) CurrentUser(c echo.Context) error {
 	return c.JSON(http.StatusOK, middlewares.UserFromContext(c))
@@ -0,0 +1,108 @@
+package mongodb
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/halilylm/secondhand/auth/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type magicLinkRepository struct {
+	collection *mongo.Collection
+	requests   *mongo.Collection
+}
+
+// NewMagicLinkRepository returns a new mongo magic link repository,
+// requests holds the magic links requested per email and window
+func NewMagicLinkRepository(collection, requests *mongo.Collection) domain.MagicLinkRepository {
+	return &magicLinkRepository{collection, requests}
+}
+
+// CreateMagicLinkIndexes removes magic links and
+// request windows once they expired
+func CreateMagicLinkIndexes(ctx context.Context, collection, requests *mongo.Collection) error {
+	for _, c := range []*mongo.Collection{collection, requests} {
+		_, err := c.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Insert creates a new magic link in mongodb
+func (m *magicLinkRepository) Insert(ctx context.Context, link *domain.MagicLink) (*domain.MagicLink, error) {
+	link.ID = uuid.NewString()
+	_, err := m.collection.InsertOne(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// CountRequest counts a magic link request of the email in the window
+// starting at windowStart, counting and reading in one step so
+// concurrent requests cannot slip past the limit
+func (m *magicLinkRepository) CountRequest(ctx context.Context, email string, windowStart time.Time, window time.Duration) (int64, error) {
+	var counter struct {
+		Count int64 `bson:"count"`
+	}
+	filter := bson.M{"_id": email + "|" + strconv.FormatInt(windowStart.Unix(), 10)}
+	update := bson.M{
+		"$inc":         bson.M{"count": 1},
+		"$setOnInsert": bson.M{"email": email, "expires_at": windowStart.Add(window)},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := m.requests.FindOneAndUpdate(ctx, filter, update, opts).Decode(&counter)
+	if mongo.IsDuplicateKeyError(err) {
+		// a concurrent request created the window first
+		err = m.requests.FindOneAndUpdate(ctx, filter, update, opts).Decode(&counter)
+	}
+	return counter.Count, err
+}
+
+// FindUsable finds an unused and unexpired magic link
+func (m *magicLinkRepository) FindUsable(ctx context.Context, tokenHash string, now time.Time) (*domain.MagicLink, error) {
+	var foundLink domain.MagicLink
+	res := m.collection.FindOne(ctx, bson.M{
+		"token_hash": tokenHash,
+		"used_at":    nil,
+		"expires_at": bson.M{"$gt": now},
+	})
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&foundLink); err != nil {
+		return nil, err
+	}
+	return &foundLink, nil
+}
+
+// Use marks an unused and unexpired magic link as used,
+// matching and updating in one step so a link
+// can be used only once
+func (m *magicLinkRepository) Use(ctx context.Context, tokenHash string, now time.Time) (*domain.MagicLink, error) {
+	var usedLink domain.MagicLink
+	res := m.collection.FindOneAndUpdate(ctx, bson.M{
+		"token_hash": tokenHash,
+		"used_at":    nil,
+		"expires_at": bson.M{"$gt": now},
+	}, bson.M{"$set": bson.M{
+		"used_at": now,
+	}}, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&usedLink); err != nil {
+		return nil, err
+	}
+	return &usedLink, nil
+}
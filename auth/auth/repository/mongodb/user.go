@@ -53,3 +53,15 @@ func (u *userRepository) FindByEmail(ctx context.Context, email string) (*domain
 	}
 	return &foundUser, nil
 }
+
+// UpdatePassword replaces the hashed password of the user
+func (u *userRepository) UpdatePassword(ctx context.Context, id, password string) error {
+	res, err := u.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"password": password}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package usecase
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/halilylm/gommon/rest"
+	"github.com/halilylm/secondhand/auth/domain"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// magicLinkTTL how long a magic link can be used
+	magicLinkTTL = 5 * time.Minute
+
+	// magicLinkRateWindow and magicLinkRateLimit limit
+	// how many magic links one email can request
+	magicLinkRateWindow = 15 * time.Minute
+	magicLinkRateLimit  = 3
+)
+
+// MagicLinkOptions configures passwordless sign-in
+type MagicLinkOptions struct {
+	// Secret used to sign magic link tokens
+	Secret []byte
+	// CallbackURL the token is appended to as query parameter
+	CallbackURL string
+}
+
+// RequestMagicLink sends a single-use sign-in link to the email
+func (a *auth) RequestMagicLink(ctx context.Context, email string) error {
+	// rate limit per email, registered or not
+	// so the limit does not reveal registration either
+	windowStart := time.Now().Truncate(magicLinkRateWindow)
+	count, err := a.magicLinkRepo.CountRequest(ctx, email, windowStart, magicLinkRateWindow)
+	if err != nil {
+		a.logger.Error(err)
+		return rest.NewInternalServerError()
+	}
+	if count > magicLinkRateLimit {
+		return domain.ErrTooManyMagicLinks
+	}
+
+	// don't reveal whether the email is registered
+	if _, err := a.userRepo.FindByEmail(ctx, email); err != nil {
+		if err != mongo.ErrNoDocuments {
+			a.logger.Error(err)
+			return rest.NewInternalServerError()
+		}
+		return nil
+	}
+
+	// generate the signed token
+	payload, token, err := a.newMagicLinkToken()
+	if err != nil {
+		a.logger.Error(err)
+		return rest.NewInternalServerError()
+	}
+
+	// save the magic link to the database
+	now := time.Now()
+	link := &domain.MagicLink{
+		Email:     email,
+		TokenHash: hashMagicLinkPayload(payload),
+		ExpiresAt: now.Add(magicLinkTTL),
+		CreatedAt: now,
+	}
+	if _, err := a.magicLinkRepo.Insert(ctx, link); err != nil {
+		a.logger.Error(err)
+		return rest.NewInternalServerError()
+	}
+
+	// send the link
+	mail := &domain.Mail{
+		To:      email,
+		Subject: "Your sign-in link",
+		Body: fmt.Sprintf(
+			"Use the link below to sign in. It expires in %d minutes and can be used once.\n\n%s?token=%s",
+			int(magicLinkTTL.Minutes()), a.magicLink.CallbackURL, url.QueryEscape(token),
+		),
+	}
+	if err := a.mailer.Send(ctx, mail); err != nil {
+		a.logger.Error(err)
+		return rest.NewInternalServerError()
+	}
+
+	return nil
+}
+
+// SignInWithMagicLink exchanges a magic link token for the user
+func (a *auth) SignInWithMagicLink(ctx context.Context, token string) (*domain.User, error) {
+	// verify the signature before touching the database
+	payload, ok := a.verifyMagicLinkToken(token)
+	if !ok {
+		return nil, rest.NewBadRequestError(domain.ErrInvalidMagicLink.Error())
+	}
+
+	// mark the link as used
+	link, err := a.magicLinkRepo.Use(ctx, hashMagicLinkPayload(payload), time.Now())
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, rest.NewBadRequestError(domain.ErrInvalidMagicLink.Error())
+		}
+		a.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+
+	// find the owner of the link
+	foundUser, err := a.userRepo.FindByEmail(ctx, link.Email)
+	if err != nil {
+		a.logger.Error(err)
+		return nil, rest.NewBadRequestError(domain.ErrInvalidMagicLink.Error())
+	}
+
+	// hide user's password in response
+	foundUser.HidePassword()
+
+	return foundUser, nil
+}
+
+// findMagicLinkOwner finds the owner of a usable
+// magic link without using the link
+func (a *auth) findMagicLinkOwner(ctx context.Context, token string) (*domain.User, error) {
+	payload, ok := a.verifyMagicLinkToken(token)
+	if !ok {
+		return nil, rest.NewBadRequestError(domain.ErrInvalidMagicLink.Error())
+	}
+
+	link, err := a.magicLinkRepo.FindUsable(ctx, hashMagicLinkPayload(payload), time.Now())
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, rest.NewBadRequestError(domain.ErrInvalidMagicLink.Error())
+		}
+		a.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+
+	foundUser, err := a.userRepo.FindByEmail(ctx, link.Email)
+	if err != nil {
+		a.logger.Error(err)
+		return nil, rest.NewBadRequestError(domain.ErrInvalidMagicLink.Error())
+	}
+	return foundUser, nil
+}
+
+// newMagicLinkToken generates a random payload
+// and the token carrying the payload and its signature
+func (a *auth) newMagicLinkToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload, payload + "." + a.signMagicLinkPayload(payload), nil
+}
+
+// verifyMagicLinkToken checks the token signature
+// and returns its payload
+func (a *auth) verifyMagicLinkToken(token string) (string, bool) {
+	payload, signature, found := strings.Cut(token, ".")
+	if !found {
+		return "", false
+	}
+	expected := a.signMagicLinkPayload(payload)
+	return payload, hmac.Equal([]byte(signature), []byte(expected))
+}
+
+func (a *auth) signMagicLinkPayload(payload string) string {
+	mac := hmac.New(sha256.New, a.magicLink.Secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// hashMagicLinkPayload so stored links cannot be used
+// by someone reading the database
+func hashMagicLinkPayload(payload string) string {
+	sum := sha256.Sum256([]byte(payload))
+	return hex.EncodeToString(sum[:])
+}
@@ -7,16 +7,36 @@ import (
 	"github.com/halilylm/gommon/rest"
 	"github.com/halilylm/gommon/utils"
 	"github.com/halilylm/secondhand/auth/domain"
+	"github.com/halilylm/secondhand/auth/password"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type auth struct {
-	userRepo domain.UserRepository
-	logger   logger.Logger
+	userRepo      domain.UserRepository
+	magicLinkRepo domain.MagicLinkRepository
+	mailer        domain.Mailer
+	policy        *password.Policy
+	magicLink     MagicLinkOptions
+	logger        logger.Logger
 }
 
 // NewAuth returns auth usecase
-func NewAuth(userRepo domain.UserRepository, logger logger.Logger) Auth {
-	return &auth{userRepo: userRepo, logger: logger}
+func NewAuth(
+	userRepo domain.UserRepository,
+	magicLinkRepo domain.MagicLinkRepository,
+	mailer domain.Mailer,
+	policy *password.Policy,
+	magicLink MagicLinkOptions,
+	logger logger.Logger,
+) Auth {
+	return &auth{
+		userRepo:      userRepo,
+		magicLinkRepo: magicLinkRepo,
+		mailer:        mailer,
+		policy:        policy,
+		magicLink:     magicLink,
+		logger:        logger,
+	}
 }
 
 // SignUp the user
@@ -28,6 +48,11 @@ func (a *auth) SignUp(ctx context.Context, user *domain.User) (*domain.User, err
 		return nil, rest.NewBadRequestError(rest.ErrEmailAlreadyExists.Error())
 	}
 
+	// check the password policy
+	if err := a.policy.Validate(user.Password, user.Email, user.FirstName, user.Surname); err != nil {
+		return nil, rest.NewValidationErrors(err)
+	}
+
 	// hash the password
 	user.Password = utils.HashPassword(user.Password)
 
@@ -82,9 +107,73 @@ func (a *auth) FindUserByEmail(ctx context.Context, email string) (*domain.User,
 	return foundUser, nil
 }
 
+// ChangePassword of the signed-in user
+func (a *auth) ChangePassword(ctx context.Context, userID string, change *domain.PasswordChange) error {
+	// find the user
+	foundUser, err := a.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		a.logger.Error(err)
+		if err == mongo.ErrNoDocuments {
+			return rest.NewNotFoundError()
+		}
+		return rest.NewInternalServerError()
+	}
+
+	// compare the current password
+	if err := utils.CheckPassword(foundUser.Password, change.CurrentPassword); err != nil {
+		return rest.NewBadRequestError(rest.ErrWrongCredentials.Error())
+	}
+
+	return a.setPassword(ctx, foundUser, change.Password)
+}
+
+// ResetPassword of the owner of the magic link token
+func (a *auth) ResetPassword(ctx context.Context, reset *domain.PasswordReset) error {
+	// check the password before the magic link is used
+	// so a rejected password does not burn the link
+	foundUser, err := a.findMagicLinkOwner(ctx, reset.Token)
+	if err != nil {
+		return err
+	}
+	if err := a.policy.Validate(reset.Password, foundUser.Email, foundUser.FirstName, foundUser.Surname); err != nil {
+		return rest.NewValidationErrors(err)
+	}
+
+	// the magic link proves the ownership of the email
+	if _, err := a.SignInWithMagicLink(ctx, reset.Token); err != nil {
+		return err
+	}
+
+	return a.setPassword(ctx, foundUser, reset.Password)
+}
+
+// setPassword checks the new password against
+// the policy and saves it hashed
+func (a *auth) setPassword(ctx context.Context, user *domain.User, newPassword string) error {
+	// check the password policy
+	if err := a.policy.Validate(newPassword, user.Email, user.FirstName, user.Surname); err != nil {
+		return rest.NewValidationErrors(err)
+	}
+
+	// save the hashed password
+	if err := a.userRepo.UpdatePassword(ctx, user.ID, utils.HashPassword(newPassword)); err != nil {
+		a.logger.Error(err)
+		if err == mongo.ErrNoDocuments {
+			return rest.NewNotFoundError()
+		}
+		return rest.NewInternalServerError()
+	}
+
+	return nil
+}
+
 // Auth contract
 type Auth interface {
 	SignUp(ctx context.Context, user *domain.User) (*domain.User, error)
 	SignIn(ctx context.Context, user *domain.User) (*domain.User, error)
 	FindUserByEmail(ctx context.Context, email string) (*domain.User, error)
+	RequestMagicLink(ctx context.Context, email string) error
+	SignInWithMagicLink(ctx context.Context, token string) (*domain.User, error)
+	ChangePassword(ctx context.Context, userID string, change *domain.PasswordChange) error
+	ResetPassword(ctx context.Context, reset *domain.PasswordReset) error
 }
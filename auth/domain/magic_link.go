@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrInvalidMagicLink returned when a magic link
+// is malformed, expired or already used
+var ErrInvalidMagicLink = errors.New("magic link is invalid or expired")
+
+// ErrTooManyMagicLinks returned when an email
+// requested too many magic links in a short time
+var ErrTooManyMagicLinks = errors.New("too many magic links requested, try again later")
+
+// MagicLink is a single-use passwordless sign-in token
+type MagicLink struct {
+	ID        string     `json:"id" bson:"_id,omitempty"`
+	Email     string     `json:"email" bson:"email"`
+	TokenHash string     `json:"-" bson:"token_hash"`
+	ExpiresAt time.Time  `json:"expires_at" bson:"expires_at"`
+	UsedAt    *time.Time `json:"used_at" bson:"used_at"`
+	CreatedAt time.Time  `json:"created_at" bson:"created_at"`
+}
+
+// MagicLinkRequest body of a magic link request
+type MagicLinkRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// MagicLinkRepository to interact db
+type MagicLinkRepository interface {
+	Insert(ctx context.Context, link *MagicLink) (*MagicLink, error)
+	CountRequest(ctx context.Context, email string, windowStart time.Time, window time.Duration) (int64, error)
+	FindUsable(ctx context.Context, tokenHash string, now time.Time) (*MagicLink, error)
+	Use(ctx context.Context, tokenHash string, now time.Time) (*MagicLink, error)
+}
@@ -0,0 +1,15 @@
+package domain
+
+import "context"
+
+// Mail to be delivered to a user
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers mails to users
+type Mailer interface {
+	Send(ctx context.Context, mail *Mail) error
+}
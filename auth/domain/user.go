@@ -11,6 +11,19 @@ type User struct {
 	Password  string `json:"password,omitempty" bson:"password" validate:"required"`
 }
 
+// PasswordChange body of a password change request
+type PasswordChange struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	Password        string `json:"password" validate:"required"`
+}
+
+// PasswordReset body of a password reset request,
+// token is the one sent in the magic link
+type PasswordReset struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
 func (u *User) HidePassword() {
 	u.Password = ""
 }
@@ -20,4 +33,5 @@ type UserRepository interface {
 	Insert(ctx context.Context, user *User) (*User, error)
 	FindByID(ctx context.Context, id string) (*User, error)
 	FindByEmail(ctx context.Context, email string) (*User, error)
+	UpdatePassword(ctx context.Context, id, password string) error
 }
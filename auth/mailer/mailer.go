@@ -0,0 +1,48 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+
+	"github.com/halilylm/gommon/logger"
+	"github.com/halilylm/secondhand/auth/domain"
+)
+
+type smtpMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPMailer returns a mailer sending mails over smtp
+func NewSMTPMailer(host, port, username, password, from string) domain.Mailer {
+	return &smtpMailer{
+		addr: host + ":" + port,
+		auth: smtp.PlainAuth("", username, password, host),
+		from: from,
+	}
+}
+
+// Send the mail over smtp
+func (s *smtpMailer) Send(ctx context.Context, mail *domain.Mail) error {
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s\r\n", s.from, mail.To, mail.Subject, mail.Body)
+	return smtp.SendMail(s.addr, s.auth, s.from, []string{mail.To}, []byte(msg))
+}
+
+type logMailer struct {
+	logger logger.Logger
+}
+
+// NewLogMailer returns a mailer that only logs mails,
+// useful for local development
+func NewLogMailer(logger logger.Logger) domain.Mailer {
+	return &logMailer{logger: logger}
+}
+
+// Send logs the mail, the body is left out
+// since it may carry sign-in tokens
+func (l *logMailer) Send(ctx context.Context, mail *domain.Mail) error {
+	l.logger.Info(fmt.Sprintf("mail to %s: %s", mail.To, mail.Subject))
+	return nil
+}
@@ -0,0 +1,71 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"io"
+	"os"
+	"strings"
+)
+
+// bundledBreached holds SHA-1 hashes of the most common passwords
+//
+//go:embed breached.txt
+var bundledBreached string
+
+// BreachedList is a set of SHA-1 hashes of
+// common or breached passwords
+type BreachedList struct {
+	hashes map[string]struct{}
+}
+
+// BundledBreachedList returns the list shipped with the service
+func BundledBreachedList() *BreachedList {
+	list, _ := ReadBreachedList(strings.NewReader(bundledBreached))
+	return list
+}
+
+// LoadBreachedList loads a list from a file containing one
+// upper or lower case SHA-1 hex hash per line, an optional
+// ":count" suffix as in the pwned passwords dumps is ignored
+func LoadBreachedList(path string) (*BreachedList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadBreachedList(f)
+}
+
+// ReadBreachedList reads a list in the LoadBreachedList format
+func ReadBreachedList(r io.Reader) (*BreachedList, error) {
+	list := &BreachedList{hashes: make(map[string]struct{})}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		hash, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if hash == "" {
+			continue
+		}
+		list.hashes[strings.ToUpper(hash)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Merge adds the hashes of other to the list
+func (b *BreachedList) Merge(other *BreachedList) {
+	for hash := range other.hashes {
+		b.hashes[hash] = struct{}{}
+	}
+}
+
+// Contains reports whether the password is in the list
+func (b *BreachedList) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	_, found := b.hashes[strings.ToUpper(hex.EncodeToString(sum[:]))]
+	return found
+}
@@ -0,0 +1,146 @@
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Options configures the password policy
+type Options struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// Breached passwords are rejected, nil disables the check
+	Breached *BreachedList
+}
+
+// DefaultOptions returns the options used when nothing is configured
+func DefaultOptions() Options {
+	return Options{
+		MinLength:    8,
+		MaxLength:    72,
+		RequireUpper: true,
+		RequireLower: true,
+		RequireDigit: true,
+	}
+}
+
+// Policy validates passwords chosen by users
+type Policy struct {
+	opts     Options
+	validate *validator.Validate
+}
+
+// NewPolicy returns a password policy
+func NewPolicy(opts Options) *Policy {
+	p := &Policy{opts: opts, validate: validator.New()}
+	p.validate.RegisterStructValidation(p.validateCandidate, candidate{})
+	return p
+}
+
+// candidate is a password being validated together
+// with the personal information of its owner
+type candidate struct {
+	Password string
+	Personal []string
+}
+
+// Validate checks the password against the policy.
+// personal holds values like email and name which
+// must not be part of the password. Failures are returned
+// as validator.ValidationErrors so they can be passed
+// to rest.NewValidationErrors directly
+func (p *Policy) Validate(password string, personal ...string) error {
+	return p.validate.Struct(candidate{Password: password, Personal: personal})
+}
+
+// validateCandidate reports every rule the password breaks
+func (p *Policy) validateCandidate(sl validator.StructLevel) {
+	c := sl.Current().Interface().(candidate)
+
+	// the password itself is never reported
+	// so it doesn't end up in a response
+	report := func(tag, param string) {
+		sl.ReportError("", "Password", "Password", tag, param)
+	}
+
+	// length
+	// the maximum is in bytes since bcrypt
+	// ignores whatever comes after 72 bytes
+	length := utf8.RuneCountInString(c.Password)
+	if p.opts.MinLength > 0 && length < p.opts.MinLength {
+		report("min", fmt.Sprint(p.opts.MinLength))
+	}
+	if p.opts.MaxLength > 0 && len([]byte(c.Password)) > p.opts.MaxLength {
+		report("max", fmt.Sprint(p.opts.MaxLength))
+	}
+
+	// character classes
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range c.Password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSymbol = true
+		}
+	}
+	if p.opts.RequireUpper && !hasUpper {
+		report("upper", "")
+	}
+	if p.opts.RequireLower && !hasLower {
+		report("lower", "")
+	}
+	if p.opts.RequireDigit && !hasDigit {
+		report("digit", "")
+	}
+	if p.opts.RequireSymbol && !hasSymbol {
+		report("symbol", "")
+	}
+
+	// personal information like email and name
+	if containsPersonal(c.Password, c.Personal) {
+		report("personal", "")
+	}
+
+	// common and breached passwords
+	if p.opts.Breached != nil && p.opts.Breached.Contains(c.Password) {
+		report("breached", "")
+	}
+}
+
+// containsPersonal reports whether the password contains
+// any of the personal values, the local part of emails
+// is checked on its own as well
+func containsPersonal(password string, personal []string) bool {
+	lowered := strings.ToLower(password)
+	for _, value := range personal {
+		value = strings.ToLower(strings.TrimSpace(value))
+		candidates := []string{value}
+		if local, _, found := strings.Cut(value, "@"); found {
+			candidates = append(candidates, local)
+		}
+		for _, candidate := range candidates {
+			// very short values like initials would
+			// reject too many good passwords
+			if utf8.RuneCountInString(candidate) < 3 {
+				continue
+			}
+			if strings.Contains(lowered, candidate) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package bus
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/halilylm/gommon/events"
+	"github.com/halilylm/gommon/events/nats"
+	"github.com/halilylm/gommon/logger"
+	"github.com/halilylm/secondhand/messaging/jetstream"
+	"github.com/halilylm/secondhand/messaging/kafka"
+	"github.com/halilylm/secondhand/messaging/memory"
+)
+
+// Transports of the bus
+const (
+	// NATS is nats streaming, the default
+	NATS = "nats"
+	// JetStream is nats jetstream
+	JetStream = "jetstream"
+	// Kafka is apache kafka
+	Kafka = "kafka"
+	// Memory is the in-memory bus shared by the process, it only
+	// connects services running in one process such as tests
+	Memory = "memory"
+)
+
+// Config of the bus, Bus is a transport or two joined with "+" while
+// migrating: events are published to both and consumed from the first.
+// Services move from nats to jetstream with "nats+jetstream", then
+// "jetstream+nats" once every service publishes to both, then
+// "jetstream"; events consumed twice are skipped by the inbox
+type Config struct {
+	Bus       string
+	NATSURL   string
+	ClusterID string
+	ClientID  string
+	// Stream is the jetstream stream, jetstream.DefaultStream when empty
+	Stream string
+	// KafkaBrokers are the kafka brokers separated by commas
+	KafkaBrokers string
+	// SingleProcess tells every service runs in this process,
+	// the memory bus is refused otherwise since services in
+	// processes of their own would never hear each other
+	SingleProcess bool
+	Logger        logger.Logger
+}
+
+// Bus is the events.Streaming of the service
+type Bus struct {
+	transports []events.Streaming
+	closers    []func() error
+}
+
+// New connects to the transports of the config
+func New(cfg Config) (*Bus, error) {
+	if cfg.Bus == "" {
+		cfg.Bus = NATS
+	}
+	names := strings.Split(cfg.Bus, "+")
+	if len(names) > 2 {
+		return nil, errors.New("bus: at most two transports can be joined")
+	}
+
+	b := new(Bus)
+	for _, name := range names {
+		if err := b.connect(strings.TrimSpace(name), cfg); err != nil {
+			b.Close()
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func (b *Bus) connect(name string, cfg Config) error {
+	switch name {
+	case NATS:
+		if cfg.NATSURL == "" || cfg.ClusterID == "" || cfg.ClientID == "" {
+			return errors.New("bus: nats needs the url, cluster id and client id")
+		}
+		streaming, err := nats.New(nats.Options{
+			nil,
+			cfg.Logger,
+			[]string{cfg.NATSURL},
+			cfg.ClusterID,
+			cfg.ClientID,
+		})
+		if err != nil {
+			return err
+		}
+		b.transports = append(b.transports, streaming)
+	case JetStream:
+		if cfg.NATSURL == "" {
+			return errors.New("bus: jetstream needs the url")
+		}
+		streaming, err := jetstream.New(jetstream.Options{
+			URL:      cfg.NATSURL,
+			ClientID: cfg.ClientID,
+			Stream:   cfg.Stream,
+		})
+		if err != nil {
+			return err
+		}
+		b.transports = append(b.transports, streaming)
+		b.closers = append(b.closers, streaming.Close)
+	case Kafka:
+		if cfg.KafkaBrokers == "" {
+			return errors.New("bus: kafka needs the brokers")
+		}
+		streaming := kafka.New(kafka.Options{
+			Brokers:  strings.Split(cfg.KafkaBrokers, ","),
+			ClientID: cfg.ClientID,
+			Logger:   cfg.Logger,
+		})
+		b.transports = append(b.transports, streaming)
+		b.closers = append(b.closers, streaming.Close)
+	case Memory:
+		if !cfg.SingleProcess {
+			return errors.New("bus: memory only connects services running in a single process")
+		}
+		// the bus of the process outlives the services using it
+		b.transports = append(b.transports, memory.Shared())
+	default:
+		return errors.New("bus: unknown transport " + name)
+	}
+	return nil
+}
+
+// Publish publishes to every transport, a failed publish
+// is retried by the outbox on all of them again
+func (b *Bus) Publish(subject string, data []byte) error {
+	for _, transport := range b.transports {
+		if err := transport.Publish(subject, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Consume consumes from the first transport
+func (b *Bus) Consume(subject, qgroup string, durable bool, ackWait time.Duration) (<-chan events.Event, error) {
+	return b.transports[0].Consume(subject, qgroup, durable, ackWait)
+}
+
+// Close closes the transports which can be closed,
+// it returns the first error
+func (b *Bus) Close() error {
+	var firstErr error
+	for _, closer := range b.closers {
+		if err := closer(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,156 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/halilylm/gommon/logger"
+	"github.com/halilylm/secondhand/messaging/consumer"
+	"github.com/halilylm/secondhand/messaging/deadletter"
+)
+
+func TestMemoryNeedsSingleProcess(t *testing.T) {
+	if _, err := New(Config{Bus: Memory}); err == nil {
+		t.Fatal("memory bus connected services of separate processes")
+	}
+	b, err := New(Config{Bus: Memory, SingleProcess: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Close()
+}
+
+// deadLetters keeps the letters in memory
+type deadLetters struct {
+	mu      sync.Mutex
+	letters []*deadletter.Letter
+}
+
+func (d *deadLetters) Insert(ctx context.Context, letter *deadletter.Letter) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.letters = append(d.letters, letter)
+	return nil
+}
+
+func (d *deadLetters) List(ctx context.Context, limit int) ([]*deadletter.Letter, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]*deadletter.Letter(nil), d.letters...), nil
+}
+
+func (d *deadLetters) FindByID(ctx context.Context, id string) (*deadletter.Letter, error) {
+	return nil, errors.New("not found")
+}
+
+func (d *deadLetters) MarkReplayed(ctx context.Context, id string, at time.Time) error {
+	return nil
+}
+
+func (d *deadLetters) UnmarkReplayed(ctx context.Context, id string, at time.Time) error {
+	return nil
+}
+
+func (d *deadLetters) Delete(ctx context.Context, id string) error {
+	return nil
+}
+
+// nopLogger drops what the processor logs
+type nopLogger struct {
+	logger.Logger
+}
+
+func (nopLogger) Info(args ...any)  {}
+func (nopLogger) Error(args ...any) {}
+
+type orderCreated struct {
+	ID string `json:"id"`
+}
+
+// group is the consumer group of a service in the test
+type group struct {
+	id       string
+	received chan string
+	fail     bool
+}
+
+func (g *group) GroupID() string {
+	return g.id
+}
+
+func (g *group) Subscriptions() []consumer.Subscription {
+	return []consumer.Subscription{
+		consumer.On("order:created", func(ctx context.Context, eventID string, msg *orderCreated) error {
+			if g.fail {
+				return consumer.Permanent(errors.New("cannot handle " + msg.ID))
+			}
+			g.received <- msg.ID
+			return nil
+		}),
+	}
+}
+
+// TestServicesShareTheMemoryBus runs the consumer groups of several
+// services in one process, each group handles every event once
+func TestServicesShareTheMemoryBus(t *testing.T) {
+	b, err := New(Config{Bus: Memory, SingleProcess: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	letters := new(deadLetters)
+	processor := consumer.NewProcessor(b, letters, nopLogger{}, consumer.DefaultPolicy)
+	products := &group{id: "products-order-consumer", received: make(chan string, 10)}
+	payments := &group{id: "payments-order-consumer", received: make(chan string, 10)}
+	failing := &group{id: "failing-order-consumer", fail: true}
+	runners := consumer.Runners{
+		consumer.NewRunner(b, processor, products, 2),
+		consumer.NewRunner(b, processor, payments, 2),
+		consumer.NewRunner(b, processor, failing, 1),
+	}
+	if err := runners.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer runners.Shutdown(context.Background())
+
+	if err := b.Publish("order:created", []byte(`{"id":"order-1"}`)); err != nil {
+		t.Fatal(err)
+	}
+	for _, g := range []*group{products, payments} {
+		select {
+		case id := <-g.received:
+			if id != "order-1" {
+				t.Errorf("%s got %s, want order-1", g.id, id)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s got no event", g.id)
+		}
+	}
+
+	// the failing group dead letters the event on its own
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		list, _ := letters.List(context.Background(), 10)
+		if len(list) == 1 {
+			if list[0].GroupID != failing.id {
+				t.Errorf("dead lettered for %s, want %s", list[0].GroupID, failing.id)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d dead letters, want 1", len(list))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, g := range []*group{products, payments} {
+		select {
+		case id := <-g.received:
+			t.Errorf("%s got %s again", g.id, id)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
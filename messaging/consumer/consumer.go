@@ -0,0 +1,257 @@
+package consumer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/halilylm/gommon/events"
+	"github.com/halilylm/gommon/logger"
+	"github.com/halilylm/secondhand/messaging/deadletter"
+	"github.com/halilylm/secondhand/messaging/inbox"
+	"github.com/halilylm/secondhand/messaging/projection"
+)
+
+// Policy tells how often a failing event is retried, the attempts
+// and their backoff must fit well in the ack wait of the subscription
+// so the event is not delivered again while it is retried
+type Policy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	// MaxDeferral is how long an event is retried later before it is
+	// dead lettered, counted from the first deferral seen by the instance
+	MaxDeferral time.Duration
+}
+
+// DefaultPolicy retries an event five times within 8 seconds,
+// well inside AckWait even when handlers wait for projections.
+// Events coming too early are dead lettered after an hour
+var DefaultPolicy = Policy{
+	MaxAttempts: 5,
+	Backoff:     500 * time.Millisecond,
+	MaxBackoff:  4 * time.Second,
+	MaxDeferral: time.Hour,
+}
+
+// backoff returns the wait before the attempt after the given one
+func (p Policy) backoff(attempt int) time.Duration {
+	wait := p.Backoff
+	for i := 1; i < attempt; i++ {
+		wait *= 2
+		if wait >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	return wait
+}
+
+// permanentError is a failure retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// Permanent marks the error so the event is dead lettered
+// right away, such as events that cannot be decoded
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// laterError is a failure of an event which came too early
+type laterError struct {
+	err error
+}
+
+func (e *laterError) Error() string {
+	return e.err.Error()
+}
+
+func (e *laterError) Unwrap() error {
+	return e.err
+}
+
+// Later marks the error so the event is left unacked without
+// counting as an attempt, it is delivered again after the ack
+// wait. Events ahead of their projection are retried later as well
+func Later(err error) error {
+	return &laterError{err: err}
+}
+
+// retryLater tells whether the event should be delivered again
+func retryLater(err error) bool {
+	var later *laterError
+	return errors.As(err, &later) || errors.Is(err, projection.ErrDeferred)
+}
+
+// lastDelivery is implemented by events of transports which stop
+// delivering an event after a number of deliveries, such as jetstream
+type lastDelivery interface {
+	LastDelivery() bool
+}
+
+func isLastDelivery(event events.Event) bool {
+	last, ok := event.(lastDelivery)
+	return ok && last.LastDelivery()
+}
+
+// Handler handles a delivered event
+type Handler func(ctx context.Context) error
+
+// Processor acks handled events and retries failing ones,
+// events still failing are dead lettered and acked
+type Processor struct {
+	stream      events.Streaming
+	deadLetters deadletter.Store
+	logger      logger.Logger
+	policy      Policy
+
+	mu sync.Mutex
+	// deferred holds when events were first retried later
+	deferred map[string]time.Time
+	pruned   time.Time
+}
+
+// NewProcessor returns a processor retrying by the policy
+func NewProcessor(stream events.Streaming, deadLetters deadletter.Store, logger logger.Logger, policy Policy) *Processor {
+	return &Processor{
+		stream:      stream,
+		deadLetters: deadLetters,
+		logger:      logger,
+		policy:      policy,
+		deferred:    make(map[string]time.Time),
+	}
+}
+
+// Process runs the handler for the event delivered to the group,
+// events to retry later and the ones which could not be dead
+// lettered are left unacked. Events retried later for longer than
+// the max deferral or delivered for the last time are dead lettered
+func (p *Processor) Process(ctx context.Context, subject, groupID string, event events.Event, handle Handler) {
+	attempt := 1
+	for {
+		err := handle(ctx)
+		if err == nil {
+			p.ack(event)
+			return
+		}
+		deferred := retryLater(err)
+		if deferred && !isLastDelivery(event) && !p.deferredTooLong(subject, groupID, event) {
+			p.logger.Info("retrying " + subject + " for " + groupID + " later: " + err.Error())
+			return
+		}
+		if deferred {
+			err = fmt.Errorf("retried later for too long: %w", err)
+		}
+
+		var permanent *permanentError
+		if deferred || errors.As(err, &permanent) || attempt >= p.policy.MaxAttempts {
+			if err := p.deadLetter(ctx, subject, groupID, event, err, attempt); err != nil {
+				p.logger.Error(err)
+				return
+			}
+			p.ack(event)
+			return
+		}
+
+		p.logger.Error(err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(p.policy.backoff(attempt)):
+		}
+		attempt++
+	}
+}
+
+// deferredTooLong tells whether the event was first retried later by
+// the group more than the max deferral ago, events redelivered to other
+// instances are counted from their first deferral there
+func (p *Processor) deferredTooLong(subject, groupID string, event events.Event) bool {
+	key := deferralKey(subject, groupID, event)
+	now := time.Now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// events handled since or by other instances are forgotten
+	if now.Sub(p.pruned) > p.policy.MaxDeferral {
+		for key, first := range p.deferred {
+			if now.Sub(first) > 2*p.policy.MaxDeferral {
+				delete(p.deferred, key)
+			}
+		}
+		p.pruned = now
+	}
+
+	first, found := p.deferred[key]
+	if !found {
+		p.deferred[key] = now
+		return false
+	}
+	if now.Sub(first) < p.policy.MaxDeferral {
+		return false
+	}
+	delete(p.deferred, key)
+	return true
+}
+
+// deferralKey names the event of the group, by its
+// event id or else by its content
+func deferralKey(subject, groupID string, event events.Event) string {
+	id := inbox.EventID(event)
+	if id == "" {
+		var data json.RawMessage
+		event.Unmarshal(&data)
+		sum := sha256.Sum256(data)
+		id = hex.EncodeToString(sum[:])
+	}
+	return groupID + "|" + subject + "|" + id
+}
+
+// deadLetter stores the event for admins and publishes it on
+// the dead letter subject, the store is the one relied on
+func (p *Processor) deadLetter(ctx context.Context, subject, groupID string, event events.Event, cause error, attempts int) error {
+	var data json.RawMessage
+	if err := event.Unmarshal(&data); err != nil {
+		return err
+	}
+	letter := &deadletter.Letter{
+		ID:        uuid.NewString(),
+		Subject:   subject,
+		GroupID:   groupID,
+		EventID:   inbox.EventID(event),
+		Data:      data,
+		Error:     cause.Error(),
+		Attempts:  attempts,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := p.deadLetters.Insert(ctx, letter); err != nil {
+		return err
+	}
+	p.logger.Error("dead lettered " + subject + " for " + groupID + ": " + cause.Error())
+
+	encodedLetter, err := json.Marshal(letter)
+	if err != nil {
+		p.logger.Error(err)
+		return nil
+	}
+	if err := p.stream.Publish(deadletter.Subject(subject), encodedLetter); err != nil {
+		p.logger.Error(err)
+	}
+	return nil
+}
+
+func (p *Processor) ack(event events.Event) {
+	if err := event.Ack(); err != nil {
+		p.logger.Error(err)
+	}
+}
@@ -0,0 +1,275 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/halilylm/gommon/events"
+	"github.com/halilylm/gommon/logger"
+	"github.com/halilylm/secondhand/messaging/deadletter"
+	"github.com/halilylm/secondhand/messaging/projection"
+)
+
+var testPolicy = Policy{
+	MaxAttempts: 3,
+	Backoff:     time.Millisecond,
+	MaxBackoff:  2 * time.Millisecond,
+	MaxDeferral: 50 * time.Millisecond,
+}
+
+// event is a delivered event counting its acks
+type event struct {
+	data []byte
+	acks int
+}
+
+func (e *event) Unmarshal(v any) error {
+	return json.Unmarshal(e.data, v)
+}
+
+func (e *event) Ack() error {
+	e.acks++
+	return nil
+}
+
+// lastEvent is delivered for the last time
+type lastEvent struct {
+	*event
+}
+
+func (e lastEvent) LastDelivery() bool {
+	return true
+}
+
+// deadLetters keeps the letters in memory
+type deadLetters struct {
+	mu      sync.Mutex
+	letters []*deadletter.Letter
+	err     error
+}
+
+func (d *deadLetters) Insert(ctx context.Context, letter *deadletter.Letter) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err != nil {
+		return d.err
+	}
+	d.letters = append(d.letters, letter)
+	return nil
+}
+
+func (d *deadLetters) List(ctx context.Context, limit int) ([]*deadletter.Letter, error) {
+	return d.letters, nil
+}
+
+func (d *deadLetters) FindByID(ctx context.Context, id string) (*deadletter.Letter, error) {
+	return nil, errors.New("not found")
+}
+
+func (d *deadLetters) MarkReplayed(ctx context.Context, id string, at time.Time) error {
+	return nil
+}
+
+func (d *deadLetters) UnmarkReplayed(ctx context.Context, id string, at time.Time) error {
+	return nil
+}
+
+func (d *deadLetters) Delete(ctx context.Context, id string) error {
+	return nil
+}
+
+// stream records the published subjects
+type stream struct {
+	subjects []string
+}
+
+func (s *stream) Publish(subject string, data []byte) error {
+	s.subjects = append(s.subjects, subject)
+	return nil
+}
+
+func (s *stream) Consume(subject, qgroup string, durable bool, ackWait time.Duration) (<-chan events.Event, error) {
+	return nil, errors.New("not consumed in tests")
+}
+
+// nopLogger drops what the processor logs
+type nopLogger struct {
+	logger.Logger
+}
+
+func (nopLogger) Info(args ...any)  {}
+func (nopLogger) Error(args ...any) {}
+
+// failing fails the given number of times with err and succeeds then
+func failing(times int, err error) (Handler, *int) {
+	calls := 0
+	return func(ctx context.Context) error {
+		calls++
+		if calls <= times {
+			return err
+		}
+		return nil
+	}, &calls
+}
+
+func TestProcess(t *testing.T) {
+	transient := errors.New("database is down")
+	for _, tt := range []struct {
+		name      string
+		handler   func() (Handler, *int)
+		last      bool
+		wantCalls int
+		wantAcks  int
+		wantError string
+	}{
+		{
+			name:      "handled",
+			handler:   func() (Handler, *int) { return failing(0, nil) },
+			wantCalls: 1,
+			wantAcks:  1,
+		},
+		{
+			name:      "retried until handled",
+			handler:   func() (Handler, *int) { return failing(2, transient) },
+			wantCalls: 3,
+			wantAcks:  1,
+		},
+		{
+			name:      "dead lettered after the max attempts",
+			handler:   func() (Handler, *int) { return failing(10, transient) },
+			wantCalls: 3,
+			wantAcks:  1,
+			wantError: transient.Error(),
+		},
+		{
+			name:      "permanent failures are dead lettered right away",
+			handler:   func() (Handler, *int) { return failing(10, Permanent(errors.New("cannot decode"))) },
+			wantCalls: 1,
+			wantAcks:  1,
+			wantError: "cannot decode",
+		},
+		{
+			name:      "later is left unacked",
+			handler:   func() (Handler, *int) { return failing(10, Later(errors.New("not reserved yet"))) },
+			wantCalls: 1,
+		},
+		{
+			name:      "events ahead of the projection are left unacked",
+			handler:   func() (Handler, *int) { return failing(10, fmt.Errorf("product: %w", projection.ErrDeferred)) },
+			wantCalls: 1,
+		},
+		{
+			name:      "later on the last delivery is dead lettered",
+			handler:   func() (Handler, *int) { return failing(10, Later(errors.New("not reserved yet"))) },
+			last:      true,
+			wantCalls: 1,
+			wantAcks:  1,
+			wantError: "not reserved yet",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			letters := new(deadLetters)
+			published := new(stream)
+			p := NewProcessor(published, letters, nopLogger{}, testPolicy)
+			delivered := &event{data: []byte(`{"id":"order-1","event_id":"event-1"}`)}
+			var e events.Event = delivered
+			if tt.last {
+				e = lastEvent{delivered}
+			}
+
+			handle, calls := tt.handler()
+			p.Process(context.Background(), "order:created", "products", e, handle)
+
+			if *calls != tt.wantCalls {
+				t.Errorf("handled %d times, want %d", *calls, tt.wantCalls)
+			}
+			if delivered.acks != tt.wantAcks {
+				t.Errorf("acked %d times, want %d", delivered.acks, tt.wantAcks)
+			}
+			if tt.wantError == "" {
+				if len(letters.letters) != 0 {
+					t.Errorf("dead lettered: %s", letters.letters[0].Error)
+				}
+				return
+			}
+			if len(letters.letters) != 1 {
+				t.Fatalf("%d dead letters, want 1", len(letters.letters))
+			}
+			letter := letters.letters[0]
+			if !strings.Contains(letter.Error, tt.wantError) {
+				t.Errorf("letter error = %q, want %q", letter.Error, tt.wantError)
+			}
+			if letter.EventID != "event-1" || letter.GroupID != "products" || letter.Subject != "order:created" {
+				t.Errorf("letter of %s for %s on %s", letter.EventID, letter.GroupID, letter.Subject)
+			}
+			if len(published.subjects) != 1 || published.subjects[0] != deadletter.Subject("order:created") {
+				t.Errorf("published %v, want the dead letter subject", published.subjects)
+			}
+		})
+	}
+}
+
+func TestProcessDeadLettersEventsDeferredTooLong(t *testing.T) {
+	letters := new(deadLetters)
+	p := NewProcessor(new(stream), letters, nopLogger{}, testPolicy)
+	handle, _ := failing(100, Later(errors.New("not reserved yet")))
+	deliver := func() *event {
+		delivered := &event{data: []byte(`{"id":"order-1","event_id":"event-1"}`)}
+		p.Process(context.Background(), "payment:created", "orders", delivered, handle)
+		return delivered
+	}
+
+	// redeliveries within the max deferral are left unacked
+	for i := 0; i < 3; i++ {
+		if delivered := deliver(); delivered.acks != 0 {
+			t.Fatalf("delivery %d acked", i)
+		}
+	}
+	// another group defers the event on its own
+	other := &event{data: []byte(`{"id":"order-1","event_id":"event-1"}`)}
+	time.Sleep(testPolicy.MaxDeferral)
+	p.Process(context.Background(), "payment:created", "products", other, handle)
+	if other.acks != 0 {
+		t.Error("the deferral of another group was counted")
+	}
+
+	delivered := deliver()
+	if delivered.acks != 1 {
+		t.Fatalf("acked %d times after the max deferral, want 1", delivered.acks)
+	}
+	if len(letters.letters) != 1 || !strings.Contains(letters.letters[0].Error, "too long") {
+		t.Fatalf("letters = %v, want the deferred event", letters.letters)
+	}
+}
+
+func TestProcessLeavesEventsWhichCannotBeDeadLettered(t *testing.T) {
+	letters := &deadLetters{err: errors.New("database is down")}
+	p := NewProcessor(new(stream), letters, nopLogger{}, testPolicy)
+	delivered := &event{data: []byte(`{}`)}
+	handle, _ := failing(10, Permanent(errors.New("cannot decode")))
+	p.Process(context.Background(), "order:created", "products", delivered, handle)
+	if delivered.acks != 0 {
+		t.Error("acked an event which is not dead lettered")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := Policy{Backoff: 500 * time.Millisecond, MaxBackoff: 4 * time.Second}
+	for attempt, want := range map[int]time.Duration{
+		1: 500 * time.Millisecond,
+		2: time.Second,
+		3: 2 * time.Second,
+		4: 4 * time.Second,
+		9: 4 * time.Second,
+	} {
+		if got := policy.backoff(attempt); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package consumer
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/halilylm/gommon/events"
+	"github.com/halilylm/secondhand/messaging/inbox"
+	"github.com/labstack/echo/v4"
+)
+
+// AckWait is how long the stream waits for an ack before redelivering
+const AckWait = time.Minute
+
+// Subscription is a subject a group consumes with its handler
+type Subscription struct {
+	Subject string
+	handle  func(ctx context.Context, event events.Event) error
+}
+
+// On subscribes the handler to the subject, events are decoded
+// into T and the ones which cannot be decoded are dead lettered
+func On[T any](subject string, handle func(ctx context.Context, eventID string, msg *T) error) Subscription {
+	return Subscription{
+		Subject: subject,
+		handle: func(ctx context.Context, event events.Event) error {
+			var msg T
+			if err := event.Unmarshal(&msg); err != nil {
+				return Permanent(err)
+			}
+			return handle(ctx, inbox.EventID(event), &msg)
+		},
+	}
+}
+
+// Group is a consumer group of a service
+type Group interface {
+	GroupID() string
+	Subscriptions() []Subscription
+}
+
+// Health tells whether the workers of a group are consuming
+type Health struct {
+	GroupID  string   `json:"group_id"`
+	Subjects []string `json:"subjects"`
+	Workers  int      `json:"workers"`
+	Healthy  bool     `json:"healthy"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// Runner consumes the subjects of a group with a number of
+// workers per subject until it is shut down
+type Runner struct {
+	stream    events.Streaming
+	processor *Processor
+	group     Group
+	workers   int
+
+	// ctx is given to handlers, it is cancelled only
+	// when shutting down takes too long
+	ctx    context.Context
+	cancel context.CancelFunc
+	stop   chan struct{}
+	once   sync.Once
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	running int
+	err     error
+}
+
+// NewRunner returns a runner of the group with workers per subject
+func NewRunner(stream events.Streaming, processor *Processor, group Group, workers int) *Runner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Runner{
+		stream:    stream,
+		processor: processor,
+		group:     group,
+		workers:   workers,
+		ctx:       ctx,
+		cancel:    cancel,
+		stop:      make(chan struct{}),
+	}
+}
+
+// Start subscribes to the subjects of the group and starts the workers
+func (r *Runner) Start() error {
+	for _, subscription := range r.group.Subscriptions() {
+		deliveredEvents, err := r.stream.Consume(subscription.Subject, r.group.GroupID(), true, AckWait)
+		if err != nil {
+			r.mu.Lock()
+			r.err = err
+			r.mu.Unlock()
+			return err
+		}
+		for i := 0; i < r.workers; i++ {
+			r.wg.Add(1)
+			go r.work(subscription, deliveredEvents)
+		}
+	}
+	return nil
+}
+
+func (r *Runner) work(subscription Subscription, deliveredEvents <-chan events.Event) {
+	defer r.wg.Done()
+	r.track(1)
+	defer r.track(-1)
+	for {
+		select {
+		case <-r.stop:
+			return
+		case event, ok := <-deliveredEvents:
+			if !ok {
+				return
+			}
+			r.processor.Process(r.ctx, subscription.Subject, r.group.GroupID(), event, func(ctx context.Context) error {
+				return subscription.handle(ctx, event)
+			})
+		}
+	}
+}
+
+func (r *Runner) track(delta int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.running += delta
+}
+
+// Shutdown stops taking events and waits for the ones in flight,
+// events still in flight when the context is done are cancelled
+// and left unacked to be delivered again
+func (r *Runner) Shutdown(ctx context.Context) error {
+	r.once.Do(func() {
+		close(r.stop)
+	})
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		r.cancel()
+		return nil
+	case <-ctx.Done():
+		r.cancel()
+		return ctx.Err()
+	}
+}
+
+// Health reports the workers of the group, it is healthy
+// while every worker of every subject is consuming
+func (r *Runner) Health() Health {
+	subscriptions := r.group.Subscriptions()
+	subjects := make([]string, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		subjects = append(subjects, subscription.Subject)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	health := Health{
+		GroupID:  r.group.GroupID(),
+		Subjects: subjects,
+		Workers:  r.running,
+		Healthy:  r.err == nil && r.running == len(subjects)*r.workers,
+	}
+	if r.err != nil {
+		health.Error = r.err.Error()
+	}
+	return health
+}
+
+// Runners are the consumer groups of a service
+type Runners []*Runner
+
+// Start starts every runner, it stops at the first failure
+func (rs Runners) Start() error {
+	for _, r := range rs {
+		if err := r.Start(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown shuts the runners down together within the
+// context, it returns the first error of them
+func (rs Runners) Shutdown(ctx context.Context) error {
+	errs := make(chan error, len(rs))
+	for _, r := range rs {
+		go func(r *Runner) {
+			errs <- r.Shutdown(ctx)
+		}(r)
+	}
+	var shutdownErr error
+	for range rs {
+		if err := <-errs; err != nil && shutdownErr == nil {
+			shutdownErr = err
+		}
+	}
+	return shutdownErr
+}
+
+// HealthHandler reports the health of the runners,
+// unhealthy services answer with service unavailable
+func (rs Runners) HealthHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		status := http.StatusOK
+		healths := make([]Health, 0, len(rs))
+		for _, r := range rs {
+			health := r.Health()
+			if !health.Healthy {
+				status = http.StatusServiceUnavailable
+			}
+			healths = append(healths, health)
+		}
+		return c.JSON(status, healths)
+	}
+}
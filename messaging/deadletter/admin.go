@@ -0,0 +1,116 @@
+package deadletter
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/halilylm/gommon/events"
+	"github.com/halilylm/gommon/logger"
+	"github.com/halilylm/gommon/rest"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// listLimit caps the letters returned at once
+const listLimit = 100
+
+type adminHandler struct {
+	store  Store
+	stream events.Streaming
+	logger logger.Logger
+}
+
+// NewAdminHandler lets admins inspect, replay and discard the dead
+// letters of the service, the group must already use the jwt middleware
+func NewAdminHandler(g *echo.Group, store Store, stream events.Streaming, logger logger.Logger, admin echo.MiddlewareFunc) {
+	handler := &adminHandler{store: store, stream: stream, logger: logger}
+
+	g.GET("", handler.ListLetters, admin)
+	g.GET("/:id", handler.ShowLetter, admin)
+	g.POST("/:id/replay", handler.ReplayLetter, admin)
+	g.DELETE("/:id", handler.DiscardLetter, admin)
+}
+
+func (h *adminHandler) ListLetters(c echo.Context) error {
+	// the newest letters unless told otherwise
+	limit := listLimit
+	if value := c.QueryParam("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > listLimit {
+			return c.JSON(rest.ErrorResponse(rest.NewBadRequestError("limit must be between 1 and " + strconv.Itoa(listLimit))))
+		}
+		limit = parsed
+	}
+
+	letters, err := h.store.List(c.Request().Context(), limit)
+	if err != nil {
+		h.logger.Error(err)
+		return c.JSON(rest.ErrorResponse(rest.NewInternalServerError()))
+	}
+	return c.JSON(http.StatusOK, letters)
+}
+
+func (h *adminHandler) ShowLetter(c echo.Context) error {
+	letter, err := h.findLetter(c)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+	return c.JSON(http.StatusOK, letter)
+}
+
+// ReplayLetter publishes the event on its subject again, groups
+// which handled it already skip it by its event id in their inbox
+// so letters without one cannot be replayed, only discarded
+func (h *adminHandler) ReplayLetter(c echo.Context) error {
+	letter, err := h.findLetter(c)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+	if letter.EventID == "" {
+		return c.JSON(rest.ErrorResponse(rest.NewBadRequestError("dead letter has no event id to replay it by")))
+	}
+
+	// claim the letter so concurrent replays publish it once
+	ctx := c.Request().Context()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	if err := h.store.MarkReplayed(ctx, letter.ID, now); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.JSON(rest.ErrorResponse(rest.NewBadRequestError("dead letter is replayed already")))
+		}
+		h.logger.Error(err)
+		return c.JSON(rest.ErrorResponse(rest.NewInternalServerError()))
+	}
+	if err := h.stream.Publish(letter.Subject, letter.Data); err != nil {
+		h.logger.Error(err)
+		if err := h.store.UnmarkReplayed(ctx, letter.ID, now); err != nil {
+			h.logger.Error(err)
+		}
+		return c.JSON(rest.ErrorResponse(rest.NewInternalServerError()))
+	}
+	letter.ReplayedAt = &now
+	return c.JSON(http.StatusOK, letter)
+}
+
+func (h *adminHandler) DiscardLetter(c echo.Context) error {
+	if err := h.store.Delete(c.Request().Context(), c.Param("id")); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.JSON(rest.ErrorResponse(rest.NewNotFoundError()))
+		}
+		h.logger.Error(err)
+		return c.JSON(rest.ErrorResponse(rest.NewInternalServerError()))
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *adminHandler) findLetter(c echo.Context) (*Letter, error) {
+	letter, err := h.store.FindByID(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, rest.NewNotFoundError()
+		}
+		h.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	return letter, nil
+}
@@ -0,0 +1,132 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Subject returns the subject dead letters of the subject are published on
+func Subject(subject string) string {
+	return subject + ":dead"
+}
+
+// Letter is an event a consumer group gave up on
+type Letter struct {
+	ID         string          `json:"id" bson:"_id"`
+	Subject    string          `json:"subject" bson:"subject"`
+	GroupID    string          `json:"group_id" bson:"group_id"`
+	EventID    string          `json:"event_id,omitempty" bson:"event_id,omitempty"`
+	Data       json.RawMessage `json:"data" bson:"data"`
+	Error      string          `json:"error" bson:"error"`
+	Attempts   int             `json:"attempts" bson:"attempts"`
+	CreatedAt  time.Time       `json:"created_at" bson:"created_at"`
+	ReplayedAt *time.Time      `json:"replayed_at,omitempty" bson:"replayed_at"`
+}
+
+// Store keeps the dead letters of the service until
+// an admin replays or discards them
+type Store interface {
+	Insert(ctx context.Context, letter *Letter) error
+	// List returns the letters waiting for an admin, newest first
+	List(ctx context.Context, limit int) ([]*Letter, error)
+	FindByID(ctx context.Context, id string) (*Letter, error)
+	// MarkReplayed claims the letter for a replay, it returns
+	// mongo.ErrNoDocuments when the letter is replayed already
+	MarkReplayed(ctx context.Context, id string, at time.Time) error
+	// UnmarkReplayed gives the claim of a failed replay back
+	UnmarkReplayed(ctx context.Context, id string, at time.Time) error
+	Delete(ctx context.Context, id string) error
+}
+
+type store struct {
+	collection *mongo.Collection
+}
+
+// NewStore returns a mongo dead letter store
+func NewStore(collection *mongo.Collection) Store {
+	return &store{collection: collection}
+}
+
+// CreateIndexes creates the index the letters are listed by
+func CreateIndexes(ctx context.Context, collection *mongo.Collection) error {
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "replayed_at", Value: 1}, {Key: "created_at", Value: -1}},
+	})
+	return err
+}
+
+func (s *store) Insert(ctx context.Context, letter *Letter) error {
+	_, err := s.collection.InsertOne(ctx, letter)
+	return err
+}
+
+func (s *store) List(ctx context.Context, limit int) ([]*Letter, error) {
+	letters := make([]*Letter, 0)
+	cur, err := s.collection.Find(ctx, bson.M{"replayed_at": nil},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var letter Letter
+		if err := cur.Decode(&letter); err != nil {
+			return nil, err
+		}
+		letters = append(letters, &letter)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return letters, nil
+}
+
+func (s *store) FindByID(ctx context.Context, id string) (*Letter, error) {
+	var letter Letter
+	res := s.collection.FindOne(ctx, bson.M{"_id": id})
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&letter); err != nil {
+		return nil, err
+	}
+	return &letter, nil
+}
+
+func (s *store) MarkReplayed(ctx context.Context, id string, at time.Time) error {
+	res, err := s.collection.UpdateOne(ctx, bson.M{
+		"_id":         id,
+		"replayed_at": nil,
+	}, bson.M{"$set": bson.M{"replayed_at": at}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (s *store) UnmarkReplayed(ctx context.Context, id string, at time.Time) error {
+	_, err := s.collection.UpdateOne(ctx, bson.M{
+		"_id":         id,
+		"replayed_at": at,
+	}, bson.M{"$set": bson.M{"replayed_at": nil}})
+	return err
+}
+
+func (s *store) Delete(ctx context.Context, id string) error {
+	res, err := s.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
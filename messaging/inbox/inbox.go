@@ -0,0 +1,95 @@
+package inbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/halilylm/gommon/events"
+	"github.com/halilylm/secondhand/messaging/outbox"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// processedRetention is how long processed events are remembered,
+// redeliveries come long before it ends
+const processedRetention = 30 * 24 * time.Hour
+
+// errProcessed aborts the transaction of a redelivered event
+var errProcessed = errors.New("event already processed")
+
+// Record is an event processed by a consumer group
+type Record struct {
+	ID          string    `bson:"_id"`
+	GroupID     string    `bson:"group_id"`
+	EventID     string    `bson:"event_id"`
+	ProcessedAt time.Time `bson:"processed_at"`
+}
+
+// Store remembers the events each consumer group processed
+// so redelivered events are handled once
+type Store interface {
+	// Process runs fn unless the group processed the event before,
+	// the event is recorded in the transaction of fn so either both
+	// are stored or neither is. Events without an id always run fn
+	Process(ctx context.Context, groupID, eventID string, fn func(ctx context.Context) error) error
+}
+
+type store struct {
+	collection *mongo.Collection
+	outbox     outbox.Store
+}
+
+// NewStore returns a mongo inbox, events handled in it run
+// in the transactions of the outbox of the service
+func NewStore(collection *mongo.Collection, outbox outbox.Store) Store {
+	return &store{collection: collection, outbox: outbox}
+}
+
+// CreateIndexes expires the records of processed events
+func CreateIndexes(ctx context.Context, collection *mongo.Collection) error {
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "processed_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(processedRetention.Seconds())),
+	})
+	return err
+}
+
+func (s *store) Process(ctx context.Context, groupID, eventID string, fn func(ctx context.Context) error) error {
+	// events published before ids cannot be told apart
+	if eventID == "" {
+		return fn(ctx)
+	}
+
+	err := s.outbox.Transaction(ctx, func(ctx context.Context) error {
+		_, err := s.collection.InsertOne(ctx, &Record{
+			ID:          groupID + "/" + eventID,
+			GroupID:     groupID,
+			EventID:     eventID,
+			ProcessedAt: time.Now().UTC(),
+		})
+		if err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return errProcessed
+			}
+			return err
+		}
+		return fn(ctx)
+	})
+	if err == errProcessed {
+		return nil
+	}
+	return err
+}
+
+// EventID returns the id the outbox gave the event,
+// events published before ids have none
+func EventID(event events.Event) string {
+	var envelope map[string]any
+	if err := event.Unmarshal(&envelope); err != nil {
+		return ""
+	}
+	id, _ := envelope[outbox.EventIDField].(string)
+	return id
+}
@@ -0,0 +1,254 @@
+package jetstream
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/halilylm/gommon/events"
+	"github.com/nats-io/nats.go"
+)
+
+// Defaults of the options left empty, DefaultSubjects matches
+// every subject of the services since they are a single token
+const (
+	DefaultStream     = "EVENTS"
+	DefaultSubjects   = "*"
+	DefaultMaxDeliver = 20
+	DefaultMaxAge     = 7 * 24 * time.Hour
+)
+
+// invalidName matches what stream and consumer names cannot hold
+var invalidName = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// Options of the jetstream connection, Conn is used instead of
+// dialing URL when set, such as a connection to an embedded server
+type Options struct {
+	URL      string
+	ClientID string
+	Conn     *nats.Conn
+	// Stream holds every subject published through the connection
+	Stream string
+	// Subjects of the stream, they are fixed at startup so instances
+	// never race to change them and must match every subject published
+	// or consumed, DefaultSubjects when empty
+	Subjects []string
+	// MaxDeliver caps the deliveries of a message no consumer of
+	// the group manages to ack, events report the last one so it
+	// is dead lettered instead of dropped by the server
+	MaxDeliver int
+	// MaxAge is how long the stream keeps messages
+	MaxAge   time.Duration
+	Replicas int
+}
+
+// Streaming is an events.Streaming on jetstream, every group
+// consuming a subject is a durable consumer with explicit acks
+type Streaming struct {
+	conn       *nats.Conn
+	ownConn    bool
+	js         nats.JetStreamContext
+	stream     string
+	maxDeliver int
+
+	mu   sync.Mutex
+	subs []*nats.Subscription
+}
+
+// New connects to jetstream and provisions the stream
+func New(opts Options) (*Streaming, error) {
+	if opts.Stream == "" {
+		opts.Stream = DefaultStream
+	}
+	if len(opts.Subjects) == 0 {
+		opts.Subjects = []string{DefaultSubjects}
+	}
+	if opts.MaxDeliver == 0 {
+		opts.MaxDeliver = DefaultMaxDeliver
+	}
+	if opts.MaxAge == 0 {
+		opts.MaxAge = DefaultMaxAge
+	}
+	if opts.Replicas == 0 {
+		opts.Replicas = 1
+	}
+
+	conn, ownConn := opts.Conn, opts.Conn == nil
+	if ownConn {
+		var err error
+		conn, err = nats.Connect(opts.URL, nats.Name(opts.ClientID), nats.MaxReconnects(-1))
+		if err != nil {
+			return nil, err
+		}
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Streaming{
+		conn:       conn,
+		ownConn:    ownConn,
+		js:         js,
+		stream:     opts.Stream,
+		maxDeliver: opts.MaxDeliver,
+	}
+	if err := s.provisionStream(opts); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// provisionStream creates the stream or sets the subjects of the
+// options on the existing one, instances share the options so
+// their updates agree
+func (s *Streaming) provisionStream(opts Options) error {
+	info, err := s.js.StreamInfo(s.stream)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		_, err = s.js.AddStream(&nats.StreamConfig{
+			Name:      s.stream,
+			Subjects:  opts.Subjects,
+			Retention: nats.LimitsPolicy,
+			Storage:   nats.FileStorage,
+			MaxAge:    opts.MaxAge,
+			Replicas:  opts.Replicas,
+		})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	config := info.Config
+	if sameSubjects(config.Subjects, opts.Subjects) {
+		return nil
+	}
+	// subjects added one by one before are covered by the options
+	config.Subjects = opts.Subjects
+	_, err = s.js.UpdateStream(&config)
+	return err
+}
+
+func sameSubjects(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
+
+// Publish stores the message in the stream, it returns
+// once jetstream acknowledged the message
+func (s *Streaming) Publish(subject string, data []byte) error {
+	_, err := s.js.Publish(subject, data)
+	return err
+}
+
+// Consume joins the durable consumer of the group for the subject,
+// durable groups start from the first message of the stream and the
+// others from the next one, messages not acked within the ack wait
+// are delivered again until the max deliveries are reached, the last
+// delivery is told by the LastDelivery method of the event
+func (s *Streaming) Consume(subject, qgroup string, durable bool, ackWait time.Duration) (<-chan events.Event, error) {
+	name := ConsumerName(qgroup, subject)
+	if err := s.provisionConsumer(name, subject, durable, ackWait); err != nil {
+		return nil, err
+	}
+
+	deliveredEvents := make(chan events.Event)
+	sub, err := s.js.QueueSubscribe(subject, name, func(msg *nats.Msg) {
+		deliveredEvents <- &event{msg: msg, maxDeliver: s.maxDeliver}
+	}, nats.Bind(s.stream, name), nats.ManualAck())
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.subs = append(s.subs, sub)
+	s.mu.Unlock()
+	return deliveredEvents, nil
+}
+
+// provisionConsumer creates the consumer of the group or
+// updates its ack wait and max deliveries when they changed
+func (s *Streaming) provisionConsumer(name, subject string, durable bool, ackWait time.Duration) error {
+	info, err := s.js.ConsumerInfo(s.stream, name)
+	if errors.Is(err, nats.ErrConsumerNotFound) {
+		deliverPolicy := nats.DeliverAllPolicy
+		if !durable {
+			deliverPolicy = nats.DeliverNewPolicy
+		}
+		_, err = s.js.AddConsumer(s.stream, &nats.ConsumerConfig{
+			Durable:        name,
+			DeliverSubject: nats.NewInbox(),
+			DeliverGroup:   name,
+			DeliverPolicy:  deliverPolicy,
+			AckPolicy:      nats.AckExplicitPolicy,
+			AckWait:        ackWait,
+			MaxDeliver:     s.maxDeliver,
+			FilterSubject:  subject,
+		})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.Config.AckWait == ackWait && info.Config.MaxDeliver == s.maxDeliver {
+		return nil
+	}
+	config := info.Config
+	config.AckWait = ackWait
+	config.MaxDeliver = s.maxDeliver
+	_, err = s.js.UpdateConsumer(s.stream, &config)
+	return err
+}
+
+// Close stops the subscriptions, messages in flight are not acked
+// and delivered again, connections given in the options stay open
+func (s *Streaming) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sub := range s.subs {
+		if err := sub.Drain(); err != nil {
+			return err
+		}
+	}
+	s.subs = nil
+	if s.ownConn {
+		return s.conn.Drain()
+	}
+	return nil
+}
+
+// ConsumerName is the durable consumer of the group for the subject,
+// groups consume every subject with a consumer of their own
+func ConsumerName(qgroup, subject string) string {
+	return invalidName.ReplaceAllString(qgroup+"_"+subject, "_")
+}
+
+// event is a message delivered to a consumer
+type event struct {
+	msg        *nats.Msg
+	maxDeliver int
+}
+
+func (e *event) Unmarshal(v any) error {
+	return json.Unmarshal(e.msg.Data, v)
+}
+
+func (e *event) Ack() error {
+	return e.msg.Ack()
+}
+
+// LastDelivery tells whether the server stops delivering
+// the message when it is not acked this time
+func (e *event) LastDelivery() bool {
+	meta, err := e.msg.Metadata()
+	if err != nil {
+		return false
+	}
+	return meta.NumDelivered >= uint64(e.maxDeliver)
+}
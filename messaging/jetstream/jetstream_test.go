@@ -0,0 +1,323 @@
+package jetstream
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/halilylm/gommon/events"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+)
+
+const testWait = 5 * time.Second
+
+type message struct {
+	N int `json:"n"`
+}
+
+// runServer starts an embedded server with jetstream
+func runServer(t *testing.T) *server.Server {
+	t.Helper()
+	opts := test.DefaultTestOptions
+	opts.Port = -1
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	s := test.RunServer(&opts)
+	t.Cleanup(s.Shutdown)
+	return s
+}
+
+func connect(t *testing.T, s *server.Server) *nats.Conn {
+	t.Helper()
+	conn, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(conn.Close)
+	return conn
+}
+
+func newStreaming(t *testing.T, s *server.Server, opts Options) *Streaming {
+	t.Helper()
+	opts.Conn = connect(t, s)
+	streaming, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { streaming.Close() })
+	return streaming
+}
+
+func publish(t *testing.T, s *Streaming, subject string, n int) {
+	t.Helper()
+	data, _ := json.Marshal(message{N: n})
+	if err := s.Publish(subject, data); err != nil {
+		t.Fatalf("publish %s: %v", subject, err)
+	}
+}
+
+func receive(t *testing.T, deliveries <-chan events.Event) (events.Event, int) {
+	t.Helper()
+	select {
+	case e := <-deliveries:
+		var msg message
+		if err := e.Unmarshal(&msg); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		return e, msg.N
+	case <-time.After(testWait):
+		t.Fatal("no message delivered")
+		return nil, 0
+	}
+}
+
+func noDelivery(t *testing.T, deliveries <-chan events.Event, wait time.Duration) {
+	t.Helper()
+	select {
+	case e := <-deliveries:
+		var msg message
+		e.Unmarshal(&msg)
+		t.Fatalf("unexpected delivery of %d", msg.N)
+	case <-time.After(wait):
+	}
+}
+
+func TestNewProvisionsTheStream(t *testing.T) {
+	s := runServer(t)
+	streaming := newStreaming(t, s, Options{})
+
+	info, err := streaming.js.StreamInfo(DefaultStream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameSubjects(info.Config.Subjects, []string{DefaultSubjects}) {
+		t.Errorf("subjects = %v, want %s", info.Config.Subjects, DefaultSubjects)
+	}
+	if info.Config.MaxAge != DefaultMaxAge {
+		t.Errorf("max age = %v, want %v", info.Config.MaxAge, DefaultMaxAge)
+	}
+}
+
+func TestNewReplacesSubjectsAddedOneByOne(t *testing.T) {
+	s := runServer(t)
+	js, _ := connect(t, s).JetStream()
+	_, err := js.AddStream(&nats.StreamConfig{
+		Name:     DefaultStream,
+		Subjects: []string{"product:created", "order:created"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	streaming := newStreaming(t, s, Options{})
+	info, err := js.StreamInfo(DefaultStream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameSubjects(info.Config.Subjects, []string{DefaultSubjects}) {
+		t.Errorf("subjects = %v, want %s", info.Config.Subjects, DefaultSubjects)
+	}
+	publish(t, streaming, "payment:created", 1)
+}
+
+// TestInstancesPublishEverySubject starts instances together, none
+// of them loses the subjects of the others
+func TestInstancesPublishEverySubject(t *testing.T) {
+	s := runServer(t)
+	subjects := []string{"product:created", "order:created", "payment:created", "offer:agreed"}
+
+	instances := make([]*Streaming, len(subjects))
+	var wg sync.WaitGroup
+	for i := range subjects {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn, err := nats.Connect(s.ClientURL())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			t.Cleanup(conn.Close)
+			if instances[i], err = New(Options{Conn: conn}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if t.Failed() {
+		t.FailNow()
+	}
+
+	for i, subject := range subjects {
+		deliveries, err := instances[i].Consume(subject, "group", true, time.Minute)
+		if err != nil {
+			t.Fatalf("consume %s: %v", subject, err)
+		}
+		publish(t, instances[(i+1)%len(instances)], subject, i)
+		e, n := receive(t, deliveries)
+		if n != i {
+			t.Errorf("%s got %d, want %d", subject, n, i)
+		}
+		e.Ack()
+	}
+	for _, instance := range instances {
+		instance.Close()
+	}
+}
+
+func TestConsumeProvisionsTheGroupConsumer(t *testing.T) {
+	s := runServer(t)
+	streaming := newStreaming(t, s, Options{MaxDeliver: 3})
+	if _, err := streaming.Consume("order:created", "products", true, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	name := ConsumerName("products", "order:created")
+	if name != "products_order_created" {
+		t.Errorf("consumer name = %s", name)
+	}
+	info, err := streaming.js.ConsumerInfo(DefaultStream, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := info.Config
+	if config.Durable != name || config.FilterSubject != "order:created" {
+		t.Errorf("consumer %s filters %s", config.Durable, config.FilterSubject)
+	}
+	if config.AckPolicy != nats.AckExplicitPolicy || config.AckWait != time.Minute || config.MaxDeliver != 3 {
+		t.Errorf("ack policy %v, ack wait %v, max deliver %d", config.AckPolicy, config.AckWait, config.MaxDeliver)
+	}
+
+	// another instance with a new ack wait updates the consumer
+	other := newStreaming(t, s, Options{MaxDeliver: 3})
+	if _, err := other.Consume("order:created", "products", true, 2*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	info, err = other.js.ConsumerInfo(DefaultStream, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Config.AckWait != 2*time.Minute {
+		t.Errorf("ack wait = %v, want 2m", info.Config.AckWait)
+	}
+}
+
+func TestDurableGroupGetsEarlierMessages(t *testing.T) {
+	s := runServer(t)
+	streaming := newStreaming(t, s, Options{})
+	publish(t, streaming, "order:created", 1)
+
+	durable, err := streaming.Consume("order:created", "durable", true, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transient, err := streaming.Consume("order:created", "transient", false, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish(t, streaming, "order:created", 2)
+
+	for _, want := range []int{1, 2} {
+		e, n := receive(t, durable)
+		if n != want {
+			t.Errorf("durable got %d, want %d", n, want)
+		}
+		e.Ack()
+	}
+	e, n := receive(t, transient)
+	if n != 2 {
+		t.Errorf("transient got %d, want 2", n)
+	}
+	e.Ack()
+}
+
+func TestGroupInstancesShareTheMessages(t *testing.T) {
+	s := runServer(t)
+	first := newStreaming(t, s, Options{})
+	second := newStreaming(t, s, Options{})
+	a, err := first.Consume("order:created", "products", true, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := second.Consume("order:created", "products", true, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < 10; n++ {
+		publish(t, first, "order:created", n)
+	}
+
+	seen := make(map[int]int)
+	for received := 0; received < 10; received++ {
+		var e events.Event
+		select {
+		case e = <-a:
+		case e = <-b:
+		case <-time.After(testWait):
+			t.Fatalf("%d messages delivered, want 10", received)
+		}
+		var msg message
+		e.Unmarshal(&msg)
+		e.Ack()
+		seen[msg.N]++
+	}
+	select {
+	case <-a:
+		t.Error("a message was delivered twice")
+	case <-b:
+		t.Error("a message was delivered twice")
+	case <-time.After(200 * time.Millisecond):
+	}
+	for n := 0; n < 10; n++ {
+		if seen[n] != 1 {
+			t.Errorf("message %d delivered %d times", n, seen[n])
+		}
+	}
+}
+
+func TestUnackedMessagesAreDeliveredAgain(t *testing.T) {
+	s := runServer(t)
+	streaming := newStreaming(t, s, Options{})
+	ackWait := 200 * time.Millisecond
+	deliveries, err := streaming.Consume("order:created", "products", true, ackWait)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish(t, streaming, "order:created", 1)
+
+	_, n := receive(t, deliveries)
+	delivered := time.Now()
+	e, again := receive(t, deliveries)
+	if again != n {
+		t.Fatalf("got %d again, want %d", again, n)
+	}
+	if elapsed := time.Since(delivered); elapsed < ackWait/2 {
+		t.Errorf("delivered again after %v, before the ack wait", elapsed)
+	}
+	if err := e.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	noDelivery(t, deliveries, 2*ackWait)
+}
+
+func TestMaxDeliverReportsTheLastDelivery(t *testing.T) {
+	s := runServer(t)
+	streaming := newStreaming(t, s, Options{MaxDeliver: 2})
+	ackWait := 100 * time.Millisecond
+	deliveries, err := streaming.Consume("order:created", "products", true, ackWait)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish(t, streaming, "order:created", 1)
+
+	for _, last := range []bool{false, true} {
+		e, _ := receive(t, deliveries)
+		if got := e.(*event).LastDelivery(); got != last {
+			t.Errorf("last delivery = %t, want %t", got, last)
+		}
+	}
+	noDelivery(t, deliveries, 5*ackWait)
+}
@@ -0,0 +1,294 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/halilylm/gommon/events"
+	"github.com/halilylm/gommon/logger"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// fetchBackoff is the wait after a failed fetch
+const fetchBackoff = time.Second
+
+// consumer delivers the messages of a consumer group to the workers
+// reading its channel, the state is owned by the run goroutine
+type consumer struct {
+	reader     Reader
+	subject    string
+	logger     logger.Logger
+	ackWait    time.Duration
+	maxDeliver int
+	out        chan events.Event
+	fetched    chan kafkago.Message
+	acks       chan *delivery
+	closed     chan struct{}
+
+	// partitions are the messages not committed yet in offset order
+	partitions map[int][]*delivery
+	// keys are the messages not acked yet by key, the first one is
+	// delivered and the others wait for it to be acked
+	keys map[string][]*delivery
+	// ready are the messages to hand out next
+	ready []*delivery
+	// pending counts the messages of the partitions
+	pending int
+}
+
+// delivery is a message fetched by the consumer
+type delivery struct {
+	msg   kafkago.Message
+	acked bool
+	// queued tells the message is in ready
+	queued bool
+	// deadline is when the message is delivered again,
+	// it is zero while the message is not handed out
+	deadline time.Time
+	// delivered counts the times the message was handed out
+	delivered int
+}
+
+func newConsumer(reader Reader, subject string, logger logger.Logger, ackWait time.Duration, maxDeliver int) *consumer {
+	return &consumer{
+		reader:     reader,
+		subject:    subject,
+		logger:     logger,
+		ackWait:    ackWait,
+		maxDeliver: maxDeliver,
+		out:        make(chan events.Event),
+		fetched:    make(chan kafkago.Message),
+		acks:       make(chan *delivery),
+		closed:     make(chan struct{}),
+		partitions: make(map[int][]*delivery),
+		keys:       make(map[string][]*delivery),
+	}
+}
+
+// fetch reads the messages of the group until the context is done
+func (c *consumer) fetch(ctx context.Context) {
+	for {
+		msg, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			c.logger.Error(err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(fetchBackoff):
+			}
+			continue
+		}
+		select {
+		case c.fetched <- msg:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// run hands out the fetched messages and handles their acks until
+// the context is done, fetching pauses while too many are pending
+func (c *consumer) run(ctx context.Context) {
+	defer close(c.closed)
+	ticker := time.NewTicker(c.tick())
+	defer ticker.Stop()
+
+	for {
+		var fetched chan kafkago.Message
+		if c.pending < maxPending {
+			fetched = c.fetched
+		}
+		var out chan events.Event
+		var next events.Event
+		if len(c.ready) > 0 {
+			out = c.out
+			d := c.ready[0]
+			next = &event{consumer: c, delivery: d, last: d.delivered+1 >= c.maxDeliver}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-fetched:
+			c.track(msg)
+			if c.pending >= maxPending {
+				c.commitAll(ctx)
+			}
+		case out <- next:
+			c.handedOut()
+		case d := <-c.acks:
+			c.ack(ctx, d)
+		case now := <-ticker.C:
+			c.redeliver(ctx, now)
+			c.commitAll(ctx)
+		}
+	}
+}
+
+// tick is how often ack timeouts are checked
+func (c *consumer) tick() time.Duration {
+	if tick := c.ackWait / 10; tick > 10*time.Millisecond {
+		return tick
+	}
+	return 10 * time.Millisecond
+}
+
+// track keeps the fetched message until it is committed, it is ready
+// unless a message before it with the same key is not acked yet.
+// Messages of the other subjects of the topic are acked as they come
+func (c *consumer) track(msg kafkago.Message) {
+	c.pending++
+	if subjectOf(msg) != c.subject {
+		c.partitions[msg.Partition] = append(c.partitions[msg.Partition], &delivery{msg: msg, acked: true})
+		return
+	}
+	d := &delivery{msg: msg}
+	c.partitions[msg.Partition] = append(c.partitions[msg.Partition], d)
+	if len(msg.Key) == 0 {
+		c.queue(d)
+		return
+	}
+	key := string(msg.Key)
+	c.keys[key] = append(c.keys[key], d)
+	if len(c.keys[key]) == 1 {
+		c.queue(d)
+	}
+}
+
+func (c *consumer) queue(d *delivery) {
+	d.queued = true
+	c.ready = append(c.ready, d)
+}
+
+// handedOut starts the ack timeout of the message a worker took
+func (c *consumer) handedOut() {
+	d := c.ready[0]
+	c.ready = c.ready[1:]
+	d.queued = false
+	d.deadline = time.Now().Add(c.ackWait)
+	d.delivered++
+}
+
+// ack releases the next message of the key and commits
+// the messages of the partition acked so far
+func (c *consumer) ack(ctx context.Context, d *delivery) {
+	// messages delivered again may be acked twice
+	if d.acked {
+		return
+	}
+	d.acked = true
+	if d.queued {
+		c.unqueue(d)
+	}
+	if len(d.msg.Key) > 0 {
+		key := string(d.msg.Key)
+		waiting := c.keys[key][1:]
+		if len(waiting) == 0 {
+			delete(c.keys, key)
+		} else {
+			c.keys[key] = waiting
+			c.queue(waiting[0])
+		}
+	}
+	c.commit(ctx, d.msg.Partition)
+}
+
+func (c *consumer) unqueue(d *delivery) {
+	for i, ready := range c.ready {
+		if ready == d {
+			c.ready = append(c.ready[:i], c.ready[i+1:]...)
+			break
+		}
+	}
+	d.queued = false
+}
+
+// commit commits the offset of the last message acked along with
+// every message before it, failed commits are retried on the next tick
+func (c *consumer) commit(ctx context.Context, partition int) {
+	deliveries := c.partitions[partition]
+	acked := 0
+	for acked < len(deliveries) && deliveries[acked].acked {
+		acked++
+	}
+	if acked == 0 {
+		return
+	}
+	if err := c.reader.CommitMessages(ctx, deliveries[acked-1].msg); err != nil {
+		if ctx.Err() == nil {
+			c.logger.Error(err)
+		}
+		return
+	}
+	c.pending -= acked
+	if acked == len(deliveries) {
+		delete(c.partitions, partition)
+		return
+	}
+	c.partitions[partition] = deliveries[acked:]
+}
+
+func (c *consumer) commitAll(ctx context.Context) {
+	for partition := range c.partitions {
+		c.commit(ctx, partition)
+	}
+}
+
+// redeliver queues the messages whose ack timed out again, the
+// ones delivered the max times are given up on so they stop holding
+// back their key and the commits of their partition
+func (c *consumer) redeliver(ctx context.Context, now time.Time) {
+	var givenUp []*delivery
+	for _, deliveries := range c.partitions {
+		for _, d := range deliveries {
+			if d.acked || d.queued || d.deadline.IsZero() || now.Before(d.deadline) {
+				continue
+			}
+			d.deadline = time.Time{}
+			if d.delivered >= c.maxDeliver {
+				givenUp = append(givenUp, d)
+				continue
+			}
+			c.queue(d)
+		}
+	}
+	for _, d := range givenUp {
+		c.logger.Error(fmt.Errorf("kafka: %s at offset %d of partition %d not acked after %d deliveries, giving up",
+			c.subject, d.msg.Offset, d.msg.Partition, d.delivered))
+		c.ack(ctx, d)
+	}
+}
+
+// event is a message handed out to a worker
+type event struct {
+	consumer *consumer
+	delivery *delivery
+	// last tells it is the last delivery of the message
+	last bool
+}
+
+func (e *event) Unmarshal(v any) error {
+	return json.Unmarshal(e.delivery.msg.Value, v)
+}
+
+// LastDelivery tells whether the message is given up on
+// when it is not acked this time
+func (e *event) LastDelivery() bool {
+	return e.last
+}
+
+// Ack marks the message done, its offset is committed
+// once the messages before it are done too
+func (e *event) Ack() error {
+	select {
+	case e.consumer.acks <- e.delivery:
+		return nil
+	case <-e.consumer.closed:
+		return ErrClosed
+	}
+}
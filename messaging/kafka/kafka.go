@@ -0,0 +1,273 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/halilylm/gommon/events"
+	"github.com/halilylm/gommon/logger"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+const (
+	// publishTimeout caps how long a publish waits for the brokers
+	publishTimeout = 10 * time.Second
+
+	// maxPending caps the messages of a consumer fetched but not
+	// committed, the skipped ones of the other subjects included
+	maxPending = 1024
+
+	// DefaultMaxDeliver is the max deliveries when none is given
+	DefaultMaxDeliver = 20
+
+	// SubjectHeader is the header holding the subject of a message
+	SubjectHeader = "subject"
+)
+
+// ErrClosed returned when the streaming is used after it was closed
+var ErrClosed = errors.New("kafka: streaming closed")
+
+// invalidTopic matches what topic names cannot hold
+var invalidTopic = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// Reader reads the topic of a consumer group
+type Reader interface {
+	FetchMessage(ctx context.Context) (kafkago.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafkago.Message) error
+	Close() error
+}
+
+// Writer writes messages to their topics
+type Writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafkago.Message) error
+	Close() error
+}
+
+// Broker opens the readers and the writer of the streaming,
+// tests give a stand-in running in the process
+type Broker interface {
+	Writer() Writer
+	// Reader starts from the offset when the group has none committed
+	Reader(topic, groupID string, startOffset int64) Reader
+}
+
+// Options of the kafka connection, Broker is used instead
+// of connecting to Brokers when set
+type Options struct {
+	Brokers  []string
+	ClientID string
+	Broker   Broker
+	// MaxDeliver caps the deliveries of a message no worker manages
+	// to ack, events report the last one so it is dead lettered
+	// before the consumer gives up and moves past it
+	MaxDeliver int
+	Logger     logger.Logger
+}
+
+// Streaming is an events.Streaming on kafka, the subjects of an
+// aggregate such as product:created and product:deleted share a topic
+// and every group consuming a subject is a consumer group of its own
+// skipping the other subjects of the topic. Messages are keyed by the
+// id of their aggregate so the events of an aggregate on one subject
+// are delivered in order, events of the other subjects are consumed
+// apart and may come before or after them, consumers order those by
+// the version of the aggregate. Offsets are committed once every
+// message before them is acked so events are delivered at least once
+type Streaming struct {
+	writer     Writer
+	broker     Broker
+	logger     logger.Logger
+	maxDeliver int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu        sync.Mutex
+	consumers map[string]*consumer
+}
+
+// New returns a streaming publishing and consuming through the broker
+func New(opts Options) *Streaming {
+	broker := opts.Broker
+	if broker == nil {
+		broker = NewBroker(opts.Brokers, opts.ClientID)
+	}
+	if opts.MaxDeliver == 0 {
+		opts.MaxDeliver = DefaultMaxDeliver
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Streaming{
+		writer:     broker.Writer(),
+		broker:     broker,
+		logger:     opts.Logger,
+		maxDeliver: opts.MaxDeliver,
+		ctx:        ctx,
+		cancel:     cancel,
+		consumers:  make(map[string]*consumer),
+	}
+}
+
+// Publish writes the message to the topic of the subject, it
+// returns once every in-sync replica stored the message
+func (s *Streaming) Publish(subject string, data []byte) error {
+	if s.ctx.Err() != nil {
+		return ErrClosed
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, publishTimeout)
+	defer cancel()
+	return s.writer.WriteMessages(ctx, kafkago.Message{
+		Topic:   Topic(subject),
+		Key:     AggregateKey(data),
+		Value:   data,
+		Headers: []kafkago.Header{{Key: SubjectHeader, Value: []byte(subject)}},
+	})
+}
+
+// Consume joins the consumer group of the group for the subject, new
+// durable groups start from the first message of the topic and the
+// others from the next one, messages not acked within the ack wait are
+// delivered again until the max deliveries are reached. A message is
+// delivered once the one before it with the same key is acked or given
+// up on, consuming again with the group shares its channel
+func (s *Streaming) Consume(subject, qgroup string, durable bool, ackWait time.Duration) (<-chan events.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx.Err() != nil {
+		return nil, ErrClosed
+	}
+
+	groupID := GroupID(qgroup, subject)
+	if c, found := s.consumers[groupID]; found {
+		return c.out, nil
+	}
+	startOffset := kafkago.FirstOffset
+	if !durable {
+		startOffset = kafkago.LastOffset
+	}
+	reader := s.broker.Reader(Topic(subject), groupID, startOffset)
+	c := newConsumer(reader, subject, s.logger, ackWait, s.maxDeliver)
+	s.consumers[groupID] = c
+
+	s.wg.Add(2)
+	go func() {
+		defer s.wg.Done()
+		c.fetch(s.ctx)
+	}()
+	go func() {
+		defer s.wg.Done()
+		c.run(s.ctx)
+	}()
+	return c.out, nil
+}
+
+// Close stops consuming and closes the readers and the writer,
+// messages not acked yet are delivered again to the group
+func (s *Streaming) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancel()
+	s.wg.Wait()
+
+	var firstErr error
+	for _, c := range s.consumers {
+		if err := c.reader.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if err := s.writer.Close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
+
+// Topic is the topic of the subject, subjects share the
+// topic of their aggregate named before the colon
+func Topic(subject string) string {
+	aggregate, _, _ := strings.Cut(subject, ":")
+	return invalidTopic.ReplaceAllString(aggregate, ".")
+}
+
+// GroupID is the consumer group of the group for the subject, the
+// order of the events holds within the subject only
+func GroupID(qgroup, subject string) string {
+	return qgroup + "." + invalidTopic.ReplaceAllString(subject, ".")
+}
+
+// AggregateKey is the id of the aggregate the event is about, the
+// aggregate_id the outbox adds to events whose aggregate is not their
+// id or else their id, events without one are spread over the partitions
+func AggregateKey(data []byte) []byte {
+	var aggregate struct {
+		AggregateID string `json:"aggregate_id"`
+		ID          string `json:"id"`
+	}
+	if err := json.Unmarshal(data, &aggregate); err != nil {
+		return nil
+	}
+	if aggregate.AggregateID != "" {
+		return []byte(aggregate.AggregateID)
+	}
+	if aggregate.ID != "" {
+		return []byte(aggregate.ID)
+	}
+	return nil
+}
+
+// subjectOf is the subject of the message
+func subjectOf(msg kafkago.Message) string {
+	for _, header := range msg.Headers {
+		if header.Key == SubjectHeader {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+// broker connects to kafka
+type broker struct {
+	brokers []string
+	dialer  *kafkago.Dialer
+	writer  *kafkago.Writer
+}
+
+// NewBroker returns the broker of the kafka cluster, topics
+// are created by the brokers as they are first written
+func NewBroker(brokers []string, clientID string) Broker {
+	return &broker{
+		brokers: brokers,
+		dialer: &kafkago.Dialer{
+			ClientID:  clientID,
+			Timeout:   10 * time.Second,
+			DualStack: true,
+		},
+		writer: &kafkago.Writer{
+			Addr:                   kafkago.TCP(brokers...),
+			Balancer:               &kafkago.Hash{},
+			RequiredAcks:           kafkago.RequireAll,
+			BatchTimeout:           10 * time.Millisecond,
+			AllowAutoTopicCreation: true,
+			Transport:              &kafkago.Transport{ClientID: clientID},
+		},
+	}
+}
+
+func (b *broker) Writer() Writer {
+	return b.writer
+}
+
+func (b *broker) Reader(topic, groupID string, startOffset int64) Reader {
+	return kafkago.NewReader(kafkago.ReaderConfig{
+		Brokers:     b.brokers,
+		GroupID:     groupID,
+		Topic:       topic,
+		Dialer:      b.dialer,
+		StartOffset: startOffset,
+		MaxWait:     time.Second,
+	})
+}
@@ -0,0 +1,514 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/halilylm/gommon/events"
+	"github.com/halilylm/gommon/logger"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+const testWait = 2 * time.Second
+
+// testBroker is a kafka stand-in keeping the topics in memory,
+// messages are spread over the partitions by key like the brokers do
+type testBroker struct {
+	partitions int
+
+	mu      sync.Mutex
+	topics  map[string][][]kafkago.Message
+	offsets map[string]map[int]int64
+	commits map[string][]kafkago.Message
+	// written is closed and replaced on every write
+	written chan struct{}
+	next    int
+}
+
+func newTestBroker(partitions int) *testBroker {
+	return &testBroker{
+		partitions: partitions,
+		topics:     make(map[string][][]kafkago.Message),
+		offsets:    make(map[string]map[int]int64),
+		commits:    make(map[string][]kafkago.Message),
+		written:    make(chan struct{}),
+	}
+}
+
+func (b *testBroker) Writer() Writer {
+	return (*testWriter)(b)
+}
+
+func (b *testBroker) Reader(topic, groupID string, startOffset int64) Reader {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r := &testReader{broker: b, topic: topic, groupID: groupID, positions: make(map[int]int64)}
+	for partition := 0; partition < b.partitions; partition++ {
+		offset, committed := b.offsets[groupID][partition]
+		switch {
+		case committed:
+		case startOffset == kafkago.LastOffset:
+			offset = int64(len(b.partition(topic, partition)))
+		default:
+			offset = 0
+		}
+		r.positions[partition] = offset
+	}
+	return r
+}
+
+// partition is the log of the partition of the topic, b.mu is held
+func (b *testBroker) partition(topic string, partition int) []kafkago.Message {
+	if partitions := b.topics[topic]; partitions != nil {
+		return partitions[partition]
+	}
+	return nil
+}
+
+// committed is the next offset of the group in the partition
+func (b *testBroker) committed(groupID string, partition int) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.offsets[groupID][partition]
+}
+
+func (b *testBroker) commitsOf(groupID string) []kafkago.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]kafkago.Message(nil), b.commits[groupID]...)
+}
+
+type testWriter testBroker
+
+func (w *testWriter) WriteMessages(ctx context.Context, msgs ...kafkago.Message) error {
+	b := (*testBroker)(w)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, msg := range msgs {
+		if b.topics[msg.Topic] == nil {
+			b.topics[msg.Topic] = make([][]kafkago.Message, b.partitions)
+		}
+		partition := b.next % b.partitions
+		b.next++
+		if len(msg.Key) > 0 {
+			h := fnv.New32a()
+			h.Write(msg.Key)
+			partition = int(h.Sum32() % uint32(b.partitions))
+		}
+		msg.Partition = partition
+		msg.Offset = int64(len(b.topics[msg.Topic][partition]))
+		b.topics[msg.Topic][partition] = append(b.topics[msg.Topic][partition], msg)
+	}
+	close(b.written)
+	b.written = make(chan struct{})
+	return nil
+}
+
+func (w *testWriter) Close() error {
+	return nil
+}
+
+// testReader reads the partitions of the topic in turns
+type testReader struct {
+	broker    *testBroker
+	topic     string
+	groupID   string
+	positions map[int]int64
+	turn      int
+}
+
+func (r *testReader) FetchMessage(ctx context.Context) (kafkago.Message, error) {
+	b := r.broker
+	for {
+		b.mu.Lock()
+		for i := 0; i < b.partitions; i++ {
+			partition := (r.turn + i) % b.partitions
+			log := b.partition(r.topic, partition)
+			if position := r.positions[partition]; position < int64(len(log)) {
+				r.positions[partition]++
+				r.turn = partition + 1
+				b.mu.Unlock()
+				return log[position], nil
+			}
+		}
+		written := b.written
+		b.mu.Unlock()
+
+		select {
+		case <-written:
+		case <-ctx.Done():
+			return kafkago.Message{}, ctx.Err()
+		}
+	}
+}
+
+func (r *testReader) CommitMessages(ctx context.Context, msgs ...kafkago.Message) error {
+	b := r.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.offsets[r.groupID] == nil {
+		b.offsets[r.groupID] = make(map[int]int64)
+	}
+	for _, msg := range msgs {
+		b.offsets[r.groupID][msg.Partition] = msg.Offset + 1
+		b.commits[r.groupID] = append(b.commits[r.groupID], msg)
+	}
+	return nil
+}
+
+func (r *testReader) Close() error {
+	return nil
+}
+
+// nopLogger drops what the streaming logs
+type nopLogger struct {
+	logger.Logger
+}
+
+func (nopLogger) Info(args ...any)  {}
+func (nopLogger) Error(args ...any) {}
+
+type message struct {
+	ID string `json:"id"`
+	N  int    `json:"n"`
+}
+
+func newStreaming(t *testing.T, broker Broker) *Streaming {
+	t.Helper()
+	s := New(Options{Broker: broker, Logger: nopLogger{}})
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func publish(t *testing.T, s *Streaming, subject, id string, n int) {
+	t.Helper()
+	data, _ := json.Marshal(message{ID: id, N: n})
+	if err := s.Publish(subject, data); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+}
+
+func receive(t *testing.T, deliveries <-chan events.Event) (events.Event, message) {
+	t.Helper()
+	select {
+	case e := <-deliveries:
+		var msg message
+		if err := e.Unmarshal(&msg); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		return e, msg
+	case <-time.After(testWait):
+		t.Fatal("no message delivered")
+		return nil, message{}
+	}
+}
+
+func noDelivery(t *testing.T, deliveries <-chan events.Event, wait time.Duration) {
+	t.Helper()
+	select {
+	case e := <-deliveries:
+		var msg message
+		e.Unmarshal(&msg)
+		t.Fatalf("unexpected delivery of %s %d", msg.ID, msg.N)
+	case <-time.After(wait):
+	}
+}
+
+// eventually waits for the condition to hold
+func eventually(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(testWait)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal(what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestTopic(t *testing.T) {
+	for subject, want := range map[string]string{
+		"product:created":          "product",
+		"product:deleted":          "product",
+		"payment:refund-requested": "payment",
+		"order":                    "order",
+	} {
+		if got := Topic(subject); got != want {
+			t.Errorf("Topic(%q) = %q, want %q", subject, got, want)
+		}
+	}
+	if GroupID("orders", "product:created") == GroupID("orders", "product:deleted") {
+		t.Error("subjects of a topic share the consumer group")
+	}
+}
+
+func TestAggregateKey(t *testing.T) {
+	for data, want := range map[string]string{
+		`{"id":"payment-1","order_id":"order-1","aggregate_id":"order-1"}`: "order-1",
+		`{"id":"product-1"}`:       "product-1",
+		`{"order_id":"order-1"}`:   "",
+		`not json`:                 "",
+		`{"aggregate_id":"offer"}`: "offer",
+	} {
+		if got := string(AggregateKey([]byte(data))); got != want {
+			t.Errorf("AggregateKey(%s) = %q, want %q", data, got, want)
+		}
+	}
+}
+
+func TestEventsOfAnAggregateShareTheTopicAndPartition(t *testing.T) {
+	broker := newTestBroker(4)
+	s := newStreaming(t, broker)
+	publish(t, s, "product:created", "product-1", 1)
+	publish(t, s, "product:updated", "product-1", 2)
+	publish(t, s, "product:deleted", "product-1", 3)
+
+	var log []kafkago.Message
+	for partition := 0; partition < broker.partitions; partition++ {
+		if msgs := broker.partition("product", partition); len(msgs) > 0 {
+			if log != nil {
+				t.Fatal("the events of the product are spread over partitions")
+			}
+			log = msgs
+		}
+	}
+	for i, want := range []string{"product:created", "product:updated", "product:deleted"} {
+		if got := subjectOf(log[i]); got != want {
+			t.Errorf("message %d is %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestConsumeSkipsTheOtherSubjectsOfTheTopic(t *testing.T) {
+	broker := newTestBroker(1)
+	s := newStreaming(t, broker)
+	// skipped messages after the last ack are committed on the ticks
+	deliveries, err := s.Consume("product:deleted", "orders", true, 200*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish(t, s, "product:created", "product-1", 1)
+	publish(t, s, "product:deleted", "product-1", 2)
+	publish(t, s, "product:updated", "product-2", 3)
+
+	e, msg := receive(t, deliveries)
+	if msg.N != 2 {
+		t.Fatalf("got %d, want the deleted event", msg.N)
+	}
+	e.Ack()
+	noDelivery(t, deliveries, 100*time.Millisecond)
+	groupID := GroupID("orders", "product:deleted")
+	eventually(t, "skipped messages are not committed", func() bool {
+		return broker.committed(groupID, 0) == 3
+	})
+}
+
+func TestMessagesOfAKeyAreDeliveredInOrder(t *testing.T) {
+	broker := newTestBroker(1)
+	s := newStreaming(t, broker)
+	deliveries, err := s.Consume("product:updated", "orders", true, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish(t, s, "product:updated", "a", 1)
+	publish(t, s, "product:updated", "a", 2)
+	publish(t, s, "product:updated", "b", 3)
+
+	// the second update of a waits for the first one
+	first, msg := receive(t, deliveries)
+	if msg.ID != "a" || msg.N != 1 {
+		t.Fatalf("got %s %d, want a 1", msg.ID, msg.N)
+	}
+	other, msg := receive(t, deliveries)
+	if msg.ID != "b" || msg.N != 3 {
+		t.Fatalf("got %s %d, want b 3", msg.ID, msg.N)
+	}
+	noDelivery(t, deliveries, 100*time.Millisecond)
+
+	first.Ack()
+	e, msg := receive(t, deliveries)
+	if msg.ID != "a" || msg.N != 2 {
+		t.Fatalf("got %s %d, want a 2", msg.ID, msg.N)
+	}
+	e.Ack()
+	other.Ack()
+}
+
+func TestOffsetsAreCommittedInOrder(t *testing.T) {
+	broker := newTestBroker(1)
+	s := newStreaming(t, broker)
+	deliveries, err := s.Consume("order:created", "products", true, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < 3; n++ {
+		publish(t, s, "order:created", string(rune('a'+n)), n)
+	}
+	received := make([]events.Event, 3)
+	for range received {
+		e, msg := receive(t, deliveries)
+		received[msg.N] = e
+	}
+
+	groupID := GroupID("products", "order:created")
+	received[2].Ack()
+	received[1].Ack()
+	// commits happen on acks and ticks, give the ticks a chance
+	time.Sleep(50 * time.Millisecond)
+	if committed := broker.committed(groupID, 0); committed != 0 {
+		t.Fatalf("committed %d before the first message was acked", committed)
+	}
+
+	received[0].Ack()
+	eventually(t, "acked messages are not committed", func() bool {
+		return broker.committed(groupID, 0) == 3
+	})
+	var last int64 = -1
+	for _, msg := range broker.commitsOf(groupID) {
+		if msg.Offset < last {
+			t.Errorf("committed %d after %d", msg.Offset, last)
+		}
+		last = msg.Offset
+	}
+}
+
+func TestUnackedMessagesAreDeliveredAgain(t *testing.T) {
+	broker := newTestBroker(1)
+	s := newStreaming(t, broker)
+	ackWait := 50 * time.Millisecond
+	deliveries, err := s.Consume("order:created", "products", true, ackWait)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish(t, s, "order:created", "order-1", 1)
+
+	receive(t, deliveries)
+	delivered := time.Now()
+	e, msg := receive(t, deliveries)
+	if msg.N != 1 {
+		t.Fatalf("got %d again, want 1", msg.N)
+	}
+	if elapsed := time.Since(delivered); elapsed < ackWait {
+		t.Errorf("delivered again after %v, before the ack wait", elapsed)
+	}
+	e.Ack()
+	noDelivery(t, deliveries, 4*ackWait)
+	if committed := broker.committed(GroupID("products", "order:created"), 0); committed != 1 {
+		t.Errorf("committed %d, want 1", committed)
+	}
+}
+
+func TestMessagesAreGivenUpAfterTheMaxDeliveries(t *testing.T) {
+	broker := newTestBroker(1)
+	s := New(Options{Broker: broker, MaxDeliver: 2, Logger: nopLogger{}})
+	t.Cleanup(func() { s.Close() })
+	deliveries, err := s.Consume("order:created", "products", true, 30*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish(t, s, "order:created", "order-1", 1)
+	publish(t, s, "order:created", "order-1", 2)
+
+	for _, last := range []bool{false, true} {
+		e, msg := receive(t, deliveries)
+		if msg.N != 1 {
+			t.Fatalf("got %d, want 1", msg.N)
+		}
+		if got := e.(*event).LastDelivery(); got != last {
+			t.Errorf("last delivery = %t, want %t", got, last)
+		}
+	}
+	// the message given up on stops holding back its key
+	e, msg := receive(t, deliveries)
+	if msg.N != 2 {
+		t.Fatalf("got %d, want the next message of the key", msg.N)
+	}
+	e.Ack()
+	eventually(t, "messages given up on are not committed", func() bool {
+		return broker.committed(GroupID("products", "order:created"), 0) == 2
+	})
+}
+
+func TestFetchingPausesWhileTooManyMessagesArePending(t *testing.T) {
+	broker := newTestBroker(1)
+	s := newStreaming(t, broker)
+	deliveries, err := s.Consume("product:deleted", "orders", true, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish(t, s, "product:deleted", "product-1", 1)
+	first, _ := receive(t, deliveries)
+	// skipped messages behind the unacked one cannot be committed
+	for n := 0; n < maxPending; n++ {
+		publish(t, s, "product:created", "product-2", n)
+	}
+	publish(t, s, "product:deleted", "product-3", 2)
+	noDelivery(t, deliveries, 100*time.Millisecond)
+
+	first.Ack()
+	e, msg := receive(t, deliveries)
+	if msg.N != 2 {
+		t.Fatalf("got %d, want 2", msg.N)
+	}
+	e.Ack()
+}
+
+func TestUncommittedMessagesAreDeliveredToTheNextInstance(t *testing.T) {
+	broker := newTestBroker(1)
+	first := New(Options{Broker: broker, Logger: nopLogger{}})
+	deliveries, err := first.Consume("order:created", "products", true, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish(t, first, "order:created", "a", 1)
+	publish(t, first, "order:created", "b", 2)
+	receive(t, deliveries)
+	e, _ := receive(t, deliveries)
+	e.Ack()
+	first.Close()
+
+	// the second message was acked but the first one was not
+	second := newStreaming(t, broker)
+	deliveries, err = second.Consume("order:created", "products", true, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []int{1, 2} {
+		e, msg := receive(t, deliveries)
+		if msg.N != want {
+			t.Errorf("got %d, want %d", msg.N, want)
+		}
+		e.Ack()
+	}
+}
+
+func TestTransientGroupsStartFromTheNextMessage(t *testing.T) {
+	broker := newTestBroker(1)
+	s := newStreaming(t, broker)
+	publish(t, s, "order:created", "a", 1)
+	deliveries, err := s.Consume("order:created", "transient", false, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish(t, s, "order:created", "b", 2)
+	e, msg := receive(t, deliveries)
+	if msg.N != 2 {
+		t.Errorf("got %d, want 2", msg.N)
+	}
+	e.Ack()
+}
+
+func TestClosedStreaming(t *testing.T) {
+	s := New(Options{Broker: newTestBroker(1), Logger: nopLogger{}})
+	s.Close()
+	if err := s.Publish("order:created", []byte(`{}`)); err != ErrClosed {
+		t.Errorf("publish = %v, want ErrClosed", err)
+	}
+	if _, err := s.Consume("order:created", "products", true, time.Minute); err != ErrClosed {
+		t.Errorf("consume = %v, want ErrClosed", err)
+	}
+}
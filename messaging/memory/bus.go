@@ -0,0 +1,247 @@
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/halilylm/gommon/events"
+)
+
+// Bus is an in-process events.Streaming for tests and local runs,
+// subjects keep every message so durable groups subscribing late
+// get them all, messages not acked in time are delivered again
+type Bus struct {
+	mu       sync.Mutex
+	subjects map[string]*subject
+	closed   chan struct{}
+	once     sync.Once
+}
+
+// subject holds the messages published on it and the groups consuming it
+type subject struct {
+	messages [][]byte
+	groups   map[string]*group
+}
+
+// group is a queue group of a subject, every message is delivered
+// to one of its consumers which all read the same channel
+type group struct {
+	bus     *Bus
+	subject *subject
+	ackWait time.Duration
+	out     chan events.Event
+	wake    chan struct{}
+	// next is the sequence of the next message to deliver
+	next int
+	// pending are the deadlines of the delivered messages not acked yet
+	pending map[int]time.Time
+}
+
+// ErrClosed returned when publishing on a closed bus
+var ErrClosed = errors.New("memory: bus closed")
+
+var (
+	shared     *Bus
+	sharedOnce sync.Once
+)
+
+// New returns an empty bus
+func New() *Bus {
+	return &Bus{
+		subjects: make(map[string]*subject),
+		closed:   make(chan struct{}),
+	}
+}
+
+// Shared returns the bus of the process, services
+// running in one process talk to each other through it
+func Shared() *Bus {
+	sharedOnce.Do(func() {
+		shared = New()
+	})
+	return shared
+}
+
+// Publish stores the message on the subject and wakes its groups
+func (b *Bus) Publish(subjectName string, data []byte) error {
+	if b.isClosed() {
+		return ErrClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := b.subject(subjectName)
+	s.messages = append(s.messages, append([]byte(nil), data...))
+	for _, g := range s.groups {
+		g.notify()
+	}
+	return nil
+}
+
+// Consume joins the queue group of the subject, durable groups start
+// from the first message of the subject and the others from the next
+// one, consuming again with the same group shares its channel
+func (b *Bus) Consume(subjectName, qgroup string, durable bool, ackWait time.Duration) (<-chan events.Event, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := b.subject(subjectName)
+	if g, found := s.groups[qgroup]; found {
+		return g.out, nil
+	}
+
+	g := &group{
+		bus:     b,
+		subject: s,
+		ackWait: ackWait,
+		out:     make(chan events.Event),
+		wake:    make(chan struct{}, 1),
+		pending: make(map[int]time.Time),
+	}
+	if !durable {
+		g.next = len(s.messages)
+	}
+	s.groups[qgroup] = g
+	go g.run()
+	return g.out, nil
+}
+
+// Close stops delivering messages, channels handed
+// out are left open for the consumers to drain
+func (b *Bus) Close() {
+	b.once.Do(func() {
+		close(b.closed)
+	})
+}
+
+func (b *Bus) isClosed() bool {
+	select {
+	case <-b.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// subject returns the subject by its name, it must
+// be called with the lock of the bus held
+func (b *Bus) subject(name string) *subject {
+	s, found := b.subjects[name]
+	if !found {
+		s = &subject{groups: make(map[string]*group)}
+		b.subjects[name] = s
+	}
+	return s
+}
+
+func (g *group) notify() {
+	select {
+	case g.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers the messages of the group one at a time
+// until the bus is closed
+func (g *group) run() {
+	for {
+		if g.bus.isClosed() {
+			return
+		}
+		e, wait := g.nextEvent(time.Now())
+		if e == nil {
+			if !g.sleep(wait) {
+				return
+			}
+			continue
+		}
+		select {
+		case g.out <- e:
+			g.delivered(e.seq)
+		case <-g.bus.closed:
+			return
+		}
+	}
+}
+
+// nextEvent returns a message whose ack timed out or the next new one,
+// otherwise it returns how long until the first ack times out
+func (g *group) nextEvent(now time.Time) (*event, time.Duration) {
+	g.bus.mu.Lock()
+	defer g.bus.mu.Unlock()
+
+	seq, wait := -1, time.Duration(-1)
+	for pendingSeq, deadline := range g.pending {
+		if !deadline.After(now) {
+			if seq == -1 || pendingSeq < seq {
+				seq = pendingSeq
+			}
+			continue
+		}
+		if until := deadline.Sub(now); wait < 0 || until < wait {
+			wait = until
+		}
+	}
+	if seq == -1 && g.next < len(g.subject.messages) {
+		seq = g.next
+		g.next++
+	}
+	if seq == -1 {
+		return nil, wait
+	}
+
+	// the message is pending from now on so an ack
+	// arriving before the delivery is recorded counts
+	g.pending[seq] = now.Add(g.ackWait)
+	return &event{group: g, seq: seq, data: g.subject.messages[seq]}, 0
+}
+
+// delivered restarts the ack timeout once a consumer took
+// the message, waiting for a free consumer does not count
+func (g *group) delivered(seq int) {
+	g.bus.mu.Lock()
+	defer g.bus.mu.Unlock()
+	if _, found := g.pending[seq]; found {
+		g.pending[seq] = time.Now().Add(g.ackWait)
+	}
+}
+
+// sleep waits for a new message or the first ack timeout,
+// it returns false when the bus is closed
+func (g *group) sleep(wait time.Duration) bool {
+	var timeout <-chan time.Time
+	if wait >= 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-g.wake:
+	case <-timeout:
+	case <-g.bus.closed:
+		return false
+	}
+	return true
+}
+
+func (g *group) ack(seq int) {
+	g.bus.mu.Lock()
+	defer g.bus.mu.Unlock()
+	delete(g.pending, seq)
+}
+
+// event is a message delivered to a group
+type event struct {
+	group *group
+	seq   int
+	data  []byte
+}
+
+func (e *event) Unmarshal(v any) error {
+	return json.Unmarshal(e.data, v)
+}
+
+func (e *event) Ack() error {
+	e.group.ack(e.seq)
+	return nil
+}
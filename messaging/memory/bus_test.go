@@ -0,0 +1,201 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/halilylm/gommon/events"
+)
+
+const testWait = 2 * time.Second
+
+type message struct {
+	N int `json:"n"`
+}
+
+func publish(t *testing.T, b *Bus, subject string, n int) {
+	t.Helper()
+	data, _ := json.Marshal(message{N: n})
+	if err := b.Publish(subject, data); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+}
+
+func receive(t *testing.T, deliveries <-chan events.Event) (events.Event, int) {
+	t.Helper()
+	select {
+	case e := <-deliveries:
+		var msg message
+		if err := e.Unmarshal(&msg); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		return e, msg.N
+	case <-time.After(testWait):
+		t.Fatal("no message delivered")
+		return nil, 0
+	}
+}
+
+func noDelivery(t *testing.T, deliveries <-chan events.Event, wait time.Duration) {
+	t.Helper()
+	select {
+	case e := <-deliveries:
+		var msg message
+		e.Unmarshal(&msg)
+		t.Fatalf("unexpected delivery of %d", msg.N)
+	case <-time.After(wait):
+	}
+}
+
+func TestQueueGroupDeliversOnce(t *testing.T) {
+	b := New()
+	defer b.Close()
+	deliveries, err := b.Consume("product:created", "orders", true, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// consuming again with the group shares the channel
+	again, err := b.Consume("product:created", "orders", true, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != deliveries {
+		t.Fatal("the group got a second channel")
+	}
+
+	for n := 0; n < 10; n++ {
+		publish(t, b, "product:created", n)
+	}
+
+	// workers of the group share the messages, each is handled once
+	var mu sync.Mutex
+	seen := make(map[int]int)
+	var wg sync.WaitGroup
+	for w := 0; w < 3; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case e := <-deliveries:
+					var msg message
+					e.Unmarshal(&msg)
+					e.Ack()
+					mu.Lock()
+					seen[msg.N]++
+					mu.Unlock()
+				case <-time.After(200 * time.Millisecond):
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for n := 0; n < 10; n++ {
+		if seen[n] != 1 {
+			t.Errorf("message %d delivered %d times", n, seen[n])
+		}
+	}
+}
+
+func TestEveryGroupGetsTheMessage(t *testing.T) {
+	b := New()
+	defer b.Close()
+	orders, _ := b.Consume("product:created", "orders", true, time.Minute)
+	payments, _ := b.Consume("product:created", "payments", true, time.Minute)
+
+	publish(t, b, "product:created", 1)
+	for _, deliveries := range []<-chan events.Event{orders, payments} {
+		e, n := receive(t, deliveries)
+		if n != 1 {
+			t.Errorf("got %d, want 1", n)
+		}
+		e.Ack()
+	}
+}
+
+func TestDurableGroupGetsEarlierMessages(t *testing.T) {
+	b := New()
+	defer b.Close()
+	publish(t, b, "order:created", 1)
+
+	durable, _ := b.Consume("order:created", "durable", true, time.Minute)
+	transient, _ := b.Consume("order:created", "transient", false, time.Minute)
+	publish(t, b, "order:created", 2)
+
+	for _, want := range []int{1, 2} {
+		e, n := receive(t, durable)
+		if n != want {
+			t.Errorf("durable got %d, want %d", n, want)
+		}
+		e.Ack()
+	}
+	e, n := receive(t, transient)
+	if n != 2 {
+		t.Errorf("transient got %d, want 2", n)
+	}
+	e.Ack()
+}
+
+func TestAckedMessagesAreNotDeliveredAgain(t *testing.T) {
+	b := New()
+	defer b.Close()
+	deliveries, _ := b.Consume("order:created", "orders", true, 50*time.Millisecond)
+	publish(t, b, "order:created", 1)
+
+	e, _ := receive(t, deliveries)
+	if err := e.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	noDelivery(t, deliveries, 200*time.Millisecond)
+}
+
+func TestUnackedMessagesAreDeliveredAgain(t *testing.T) {
+	b := New()
+	defer b.Close()
+	ackWait := 50 * time.Millisecond
+	deliveries, _ := b.Consume("order:created", "orders", true, ackWait)
+	publish(t, b, "order:created", 1)
+
+	_, n := receive(t, deliveries)
+	delivered := time.Now()
+	e, again := receive(t, deliveries)
+	if again != n {
+		t.Fatalf("got %d again, want %d", again, n)
+	}
+	if elapsed := time.Since(delivered); elapsed < ackWait {
+		t.Errorf("delivered again after %v, before the ack wait", elapsed)
+	}
+	e.Ack()
+	noDelivery(t, deliveries, 200*time.Millisecond)
+}
+
+func TestClosedBusStopsDelivering(t *testing.T) {
+	b := New()
+	deliveries, _ := b.Consume("order:created", "orders", true, 10*time.Millisecond)
+	publish(t, b, "order:created", 1)
+	receive(t, deliveries)
+	b.Close()
+	if err := b.Publish("order:created", []byte(`{"n":2}`)); err != ErrClosed {
+		t.Errorf("publish after close = %v, want ErrClosed", err)
+	}
+	// the unacked message is not delivered again either
+	noDelivery(t, deliveries, 100*time.Millisecond)
+}
+
+func TestSharedIsOneBus(t *testing.T) {
+	if Shared() != Shared() {
+		t.Fatal("shared buses differ")
+	}
+	subject := fmt.Sprintf("shared:%d", time.Now().UnixNano())
+	deliveries, _ := Shared().Consume(subject, "orders", true, time.Minute)
+	publish(t, Shared(), subject, 7)
+	e, n := receive(t, deliveries)
+	if n != 7 {
+		t.Errorf("got %d, want 7", n)
+	}
+	e.Ack()
+}
@@ -0,0 +1,161 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/halilylm/gommon/logger"
+	"github.com/halilylm/gommon/rest"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// sentRetention is how long sent messages are kept
+const sentRetention = 7 * 24 * time.Hour
+
+// EventIDField is the field of the event id in published events
+const EventIDField = "event_id"
+
+// AggregateIDField is the field of the aggregate id in published
+// events added with ForAggregate
+const AggregateIDField = "aggregate_id"
+
+// Message is an event in the outbox, messages
+// without SentAt are waiting for the relay.
+// The id is published as the event_id of the event
+type Message struct {
+	ID        string     `bson:"_id"`
+	Subject   string     `bson:"subject"`
+	Data      []byte     `bson:"data"`
+	CreatedAt time.Time  `bson:"created_at"`
+	Attempts  int        `bson:"attempts"`
+	LastError string     `bson:"last_error,omitempty"`
+	SentAt    *time.Time `bson:"sent_at"`
+}
+
+// Store writes events to the outbox in the same transaction
+// as the state change they describe
+type Store interface {
+	// Transaction runs fn in a transaction, repositories and Add
+	// must be called with the context given to fn to take part in it.
+	// Contexts already in a transaction join it. Errors of fn are
+	// returned as they are, failures of the transaction itself are
+	// returned as internal server errors
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+	// Add writes the event to the outbox with a unique event_id,
+	// it is published by the relay once the transaction commits.
+	// Events wrapped with ForAggregate carry their aggregate_id too
+	Add(ctx context.Context, subject string, msg any) error
+}
+
+type store struct {
+	collection *mongo.Collection
+	logger     logger.Logger
+}
+
+// NewStore returns a mongo outbox, the collection must be in
+// a replica set for its writes to be transactional
+func NewStore(collection *mongo.Collection, logger logger.Logger) Store {
+	return &store{collection: collection, logger: logger}
+}
+
+// CreateIndexes creates the indexes the relay relies on,
+// sent messages expire after a week
+func CreateIndexes(ctx context.Context, collection *mongo.Collection) error {
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "sent_at", Value: 1}, {Key: "created_at", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "sent_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(sentRetention.Seconds())).SetName("sent_at_ttl"),
+		},
+	})
+	return err
+}
+
+func (s *store) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	// the caller commits its own transaction
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+
+	session, err := s.collection.Database().Client().StartSession()
+	if err != nil {
+		s.logger.Error(err)
+		return rest.NewInternalServerError()
+	}
+	defer session.EndSession(ctx)
+
+	// fnErr tells the errors of fn from the ones of the transaction
+	var fnErr error
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (any, error) {
+		fnErr = fn(sessionCtx)
+		return nil, fnErr
+	})
+	if err == nil {
+		return nil
+	}
+	if err == fnErr {
+		return err
+	}
+	s.logger.Error(err)
+	return rest.NewInternalServerError()
+}
+
+func (s *store) Add(ctx context.Context, subject string, msg any) error {
+	id := uuid.NewString()
+	data, err := withEventID(msg, id)
+	if err != nil {
+		return err
+	}
+	_, err = s.collection.InsertOne(ctx, &Message{
+		ID:        id,
+		Subject:   subject,
+		Data:      data,
+		CreatedAt: time.Now().UTC(),
+	})
+	return err
+}
+
+// aggregateEvent is an event added with ForAggregate
+type aggregateEvent struct {
+	aggregateID string
+	msg         any
+}
+
+// ForAggregate tells the aggregate of the event when it is not the id
+// of the event, such as the order of a payment. Transports keeping the
+// events of an aggregate on a subject in order read it from the
+// aggregate_id field
+func ForAggregate(aggregateID string, msg any) any {
+	return aggregateEvent{aggregateID: aggregateID, msg: msg}
+}
+
+// withEventID encodes the event with its id so consumers
+// can tell redeliveries from new events
+func withEventID(msg any, id string) ([]byte, error) {
+	aggregateID := ""
+	if event, ok := msg.(aggregateEvent); ok {
+		aggregateID, msg = event.aggregateID, event.msg
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	fields[EventIDField], err = json.Marshal(id)
+	if err != nil {
+		return nil, err
+	}
+	if aggregateID != "" {
+		if fields[AggregateIDField], err = json.Marshal(aggregateID); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(fields)
+}
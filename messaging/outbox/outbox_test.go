@@ -0,0 +1,44 @@
+package outbox
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type paymentCreated struct {
+	ID      string `json:"id"`
+	OrderID string `json:"order_id"`
+}
+
+func TestWithEventID(t *testing.T) {
+	msg := paymentCreated{ID: "payment-1", OrderID: "order-1"}
+	for _, tt := range []struct {
+		name      string
+		msg       any
+		aggregate string
+	}{
+		{name: "event", msg: msg},
+		{name: "pointer", msg: &msg},
+		{name: "aggregate", msg: ForAggregate(msg.OrderID, &msg), aggregate: "order-1"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := withEventID(tt.msg, "event-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fields map[string]string
+			if err := json.Unmarshal(data, &fields); err != nil {
+				t.Fatal(err)
+			}
+			if fields["id"] != "payment-1" || fields["order_id"] != "order-1" {
+				t.Errorf("event fields lost: %s", data)
+			}
+			if fields[EventIDField] != "event-1" {
+				t.Errorf("event_id = %q, want event-1", fields[EventIDField])
+			}
+			if aggregate, found := fields[AggregateIDField]; aggregate != tt.aggregate || found != (tt.aggregate != "") {
+				t.Errorf("aggregate_id = %q, want %q", aggregate, tt.aggregate)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/halilylm/gommon/events"
+	"github.com/halilylm/gommon/logger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// batchSize caps the messages published on each tick
+	batchSize = 100
+
+	// maxBackoff caps the wait after failed publishes
+	maxBackoff = time.Minute
+)
+
+// Relay publishes the messages of the outbox in the order they were
+// written, a message is retried until it is published so events are
+// delivered at least once
+type Relay struct {
+	collection *mongo.Collection
+	stream     events.Streaming
+	logger     logger.Logger
+	interval   time.Duration
+}
+
+// NewRelay returns a relay checking the outbox every interval
+func NewRelay(collection *mongo.Collection, stream events.Streaming, logger logger.Logger, interval time.Duration) *Relay {
+	return &Relay{
+		collection: collection,
+		stream:     stream,
+		logger:     logger,
+		interval:   interval,
+	}
+}
+
+// Run publishes the outbox until the context is done,
+// failed publishes are retried with a growing backoff
+func (r *Relay) Run(ctx context.Context) {
+	wait := r.interval
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		if err := r.publishPending(ctx); err != nil {
+			r.logger.Error(err)
+			wait *= 2
+			if wait > maxBackoff {
+				wait = maxBackoff
+			}
+			continue
+		}
+		wait = r.interval
+	}
+}
+
+// publishPending publishes waiting messages oldest first and stops at
+// the first failure so later events never overtake earlier ones
+func (r *Relay) publishPending(ctx context.Context) error {
+	for {
+		messages, err := r.pending(ctx)
+		if err != nil {
+			return err
+		}
+		for _, message := range messages {
+			if err := r.stream.Publish(message.Subject, message.Data); err != nil {
+				r.markFailed(ctx, message, err)
+				return err
+			}
+			if err := r.markSent(ctx, message); err != nil {
+				// published again on the next tick
+				return err
+			}
+		}
+		if len(messages) < batchSize {
+			return nil
+		}
+	}
+}
+
+func (r *Relay) pending(ctx context.Context) ([]*Message, error) {
+	messages := make([]*Message, 0, batchSize)
+	cur, err := r.collection.Find(ctx, bson.M{"sent_at": nil},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}).SetLimit(batchSize))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var message Message
+		if err := cur.Decode(&message); err != nil {
+			return nil, err
+		}
+		messages = append(messages, &message)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (r *Relay) markSent(ctx context.Context, message *Message) error {
+	_, err := r.collection.UpdateByID(ctx, message.ID, bson.M{"$set": bson.M{
+		"sent_at": time.Now().UTC(),
+	}})
+	return err
+}
+
+// markFailed records the failed attempt, the message stays in the outbox
+func (r *Relay) markFailed(ctx context.Context, message *Message, publishErr error) {
+	_, err := r.collection.UpdateByID(ctx, message.ID, bson.M{
+		"$inc": bson.M{"attempts": 1},
+		"$set": bson.M{"last_error": publishErr.Error()},
+	})
+	if err != nil {
+		r.logger.Error(err)
+	}
+}
@@ -0,0 +1,167 @@
+package projection
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"sync"
+	"time"
+)
+
+// NoVersion is the version of entities not projected yet,
+// their first event has version zero
+const NoVersion = -1
+
+// pollInterval is how often parked events check the stored
+// version, other instances applying events cannot wake them
+const pollInterval = time.Second
+
+// ErrDeferred is returned for events still ahead of the projection
+// after the wait, they are left unacked to be delivered again
+var ErrDeferred = errors.New("event is ahead of the projection")
+
+// Decision tells what to do with a versioned event
+type Decision int
+
+const (
+	// Apply the event, it is the next version
+	Apply Decision = iota
+	// Defer the event until its predecessor is applied
+	Defer
+	// Discard the event, the version is applied already
+	Discard
+)
+
+// Classify compares the version of the event with the stored one
+func Classify(stored, incoming int) Decision {
+	switch {
+	case incoming <= stored:
+		return Discard
+	case incoming == stored+1:
+		return Apply
+	default:
+		return Defer
+	}
+}
+
+// VersionFunc returns the stored version of the entity,
+// NoVersion if it is not projected yet
+type VersionFunc func(ctx context.Context, id string) (int, error)
+
+// Projector applies versioned events to a replica in order,
+// whatever order they are delivered in
+type Projector struct {
+	maxWait time.Duration
+	metrics *expvar.Map
+
+	mu      sync.Mutex
+	waiters map[string][]chan struct{}
+}
+
+// NewProjector returns a projector parking early events for at most
+// maxWait, its counters are published in expvar as projection_<name>
+func NewProjector(name string, maxWait time.Duration) *Projector {
+	return &Projector{
+		maxWait: maxWait,
+		metrics: expvar.NewMap("projection_" + name),
+		waiters: make(map[string][]chan struct{}),
+	}
+}
+
+// Handle applies the event of the entity once it is the next version,
+// stale events are discarded and early ones wait for their predecessor
+func (p *Projector) Handle(ctx context.Context, id string, version int, current VersionFunc, apply func(ctx context.Context) error) error {
+	deadline := time.Now().Add(p.maxWait)
+	for {
+		stored, err := current(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		switch Classify(stored, version) {
+		case Discard:
+			p.metrics.Add("discarded", 1)
+			return nil
+		case Apply:
+			if err := apply(ctx); err != nil {
+				// applied by another worker meanwhile
+				if stored, currentErr := current(ctx, id); currentErr == nil && Classify(stored, version) == Discard {
+					p.metrics.Add("discarded", 1)
+					return nil
+				}
+				return err
+			}
+			p.metrics.Add("applied", 1)
+			p.wake(id)
+			return nil
+		}
+
+		p.metrics.Add("deferred", 1)
+		if !p.park(ctx, id, deadline) {
+			p.metrics.Add("expired", 1)
+			return ErrDeferred
+		}
+	}
+}
+
+// park waits until an event of the entity is applied, the poll
+// interval passes or the deadline is reached, it returns false
+// when the event should not be checked again
+func (p *Projector) park(ctx context.Context, id string, deadline time.Time) bool {
+	wait := time.Until(deadline)
+	if wait <= 0 {
+		return false
+	}
+	if wait > pollInterval {
+		wait = pollInterval
+	}
+
+	woken := make(chan struct{})
+	p.mu.Lock()
+	p.waiters[id] = append(p.waiters[id], woken)
+	p.mu.Unlock()
+	p.metrics.Add("parked", 1)
+	defer p.metrics.Add("parked", -1)
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		p.forget(id, woken)
+		return false
+	case <-woken:
+		return true
+	case <-timer.C:
+		p.forget(id, woken)
+		return true
+	}
+}
+
+// wake lets the events parked for the entity check again
+func (p *Projector) wake(id string) {
+	p.mu.Lock()
+	waiters := p.waiters[id]
+	delete(p.waiters, id)
+	p.mu.Unlock()
+	for _, woken := range waiters {
+		close(woken)
+	}
+}
+
+// forget removes the waiter of an event that stopped waiting
+func (p *Projector) forget(id string, woken chan struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	waiters := p.waiters[id]
+	for i, waiter := range waiters {
+		if waiter == woken {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(waiters) == 0 {
+		delete(p.waiters, id)
+		return
+	}
+	p.waiters[id] = waiters
+}
@@ -0,0 +1,137 @@
+package projection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// projectors numbers the projectors of the tests,
+// expvar names cannot be published twice
+var projectors atomic.Int64
+
+func newProjector(maxWait time.Duration) *Projector {
+	return NewProjector(fmt.Sprintf("test_%d", projectors.Add(1)), maxWait)
+}
+
+func TestClassify(t *testing.T) {
+	for _, tt := range []struct {
+		name             string
+		stored, incoming int
+		want             Decision
+	}{
+		{"first event", NoVersion, 0, Apply},
+		{"next version", 3, 4, Apply},
+		{"same version", 3, 3, Discard},
+		{"older version", 3, 1, Discard},
+		{"first event of an older replica", 0, 0, Discard},
+		{"gap of one", 3, 5, Defer},
+		{"not projected yet", NoVersion, 2, Defer},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify(tt.stored, tt.incoming); got != tt.want {
+				t.Errorf("Classify(%d, %d) = %v, want %v", tt.stored, tt.incoming, got, tt.want)
+			}
+		})
+	}
+}
+
+// replica keeps the versions of the entities
+type replica struct {
+	mu       sync.Mutex
+	versions map[string]int
+}
+
+func (r *replica) current(ctx context.Context, id string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if version, found := r.versions[id]; found {
+		return version, nil
+	}
+	return NoVersion, nil
+}
+
+func (r *replica) apply(id string, version int) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.versions[id] = version
+		return nil
+	}
+}
+
+func TestHandle(t *testing.T) {
+	r := &replica{versions: map[string]int{"a": 1}}
+	p := newProjector(time.Second)
+	ctx := context.Background()
+
+	if err := p.Handle(ctx, "a", 1, r.current, func(ctx context.Context) error {
+		t.Error("applied a stale event")
+		return nil
+	}); err != nil {
+		t.Fatalf("stale event: %v", err)
+	}
+	if err := p.Handle(ctx, "a", 2, r.current, r.apply("a", 2)); err != nil {
+		t.Fatalf("next event: %v", err)
+	}
+	if version, _ := r.current(ctx, "a"); version != 2 {
+		t.Errorf("version = %d, want 2", version)
+	}
+}
+
+func TestHandleWaitsForThePredecessor(t *testing.T) {
+	r := &replica{versions: map[string]int{"a": 1}}
+	p := newProjector(5 * time.Second)
+	ctx := context.Background()
+
+	done := make(chan error)
+	go func() {
+		done <- p.Handle(ctx, "a", 3, r.current, r.apply("a", 3))
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("early event handled before its predecessor: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := p.Handle(ctx, "a", 2, r.current, r.apply("a", 2)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(pollInterval / 2):
+		t.Fatal("early event was not woken by its predecessor")
+	}
+	if version, _ := r.current(ctx, "a"); version != 3 {
+		t.Errorf("version = %d, want 3", version)
+	}
+}
+
+func TestHandleDefersAfterTheWait(t *testing.T) {
+	r := &replica{versions: map[string]int{}}
+	p := newProjector(20 * time.Millisecond)
+	err := p.Handle(context.Background(), "a", 2, r.current, r.apply("a", 2))
+	if !errors.Is(err, ErrDeferred) {
+		t.Errorf("err = %v, want ErrDeferred", err)
+	}
+}
+
+func TestHandleDiscardsEventsAppliedMeanwhile(t *testing.T) {
+	r := &replica{versions: map[string]int{"a": 1}}
+	p := newProjector(time.Second)
+	err := p.Handle(context.Background(), "a", 2, r.current, func(ctx context.Context) error {
+		// another worker applied the version first
+		r.apply("a", 2)(ctx)
+		return errors.New("duplicate key")
+	})
+	if err != nil {
+		t.Errorf("err = %v, want the event discarded", err)
+	}
+}
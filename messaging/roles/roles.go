@@ -0,0 +1,61 @@
+package roles
+
+import (
+	"strings"
+
+	"github.com/halilylm/gommon/middlewares"
+	"github.com/halilylm/gommon/rest"
+	"github.com/labstack/echo/v4"
+)
+
+// Role of a user
+type Role string
+
+const (
+	Admin     Role = "admin"
+	Moderator Role = "moderator"
+)
+
+// Roles grants roles to users by their ids, services
+// grant them from ADMIN_USER_IDS and MODERATOR_USER_IDS
+type Roles struct {
+	users map[Role]map[string]struct{}
+}
+
+// New returns roles nobody has yet
+func New() *Roles {
+	return &Roles{users: make(map[Role]map[string]struct{})}
+}
+
+// Grant the role to users, ids is a comma separated
+// list as it comes from env variables
+func (r *Roles) Grant(role Role, ids string) {
+	if r.users[role] == nil {
+		r.users[role] = make(map[string]struct{})
+	}
+	for _, id := range strings.Split(ids, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			r.users[role][id] = struct{}{}
+		}
+	}
+}
+
+// Has checks if the user has the role
+func (r *Roles) Has(userID string, role Role) bool {
+	_, found := r.users[role][userID]
+	return found
+}
+
+// Require only lets users with the role through,
+// it must run after the jwt middleware
+func (r *Roles) Require(role Role) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user := middlewares.UserFromContext(c)
+			if user == nil || !r.Has(user.ID, role) {
+				return c.JSON(rest.ErrorResponse(rest.NewUnauthorizedError()))
+			}
+			return next(c)
+		}
+	}
+}
@@ -3,7 +3,11 @@ package main
 import (
 	"context"
 	"errors"
+	"expvar"
 	"github.com/halilylm/gommon/rest"
+	_cartHandler "github.com/halilylm/secondhand/orders/cart/delivery/http"
+	_cartRepo "github.com/halilylm/secondhand/orders/cart/repository/mongodb"
+	_cartUC "github.com/halilylm/secondhand/orders/cart/usecase"
 	_orderStream "github.com/halilylm/secondhand/orders/orders/delivery/natstream"
 	_productStream "github.com/halilylm/secondhand/orders/product/delivery/natsream"
 	_productRepo "github.com/halilylm/secondhand/orders/product/repository/mongodb"
@@ -15,10 +19,17 @@ import (
 	"time"
 
 	"github.com/halilylm/gommon/db"
-	"github.com/halilylm/gommon/events/nats"
 	"github.com/halilylm/gommon/logger/sugared"
 	"github.com/halilylm/gommon/utils"
+	"github.com/halilylm/secondhand/messaging/bus"
+	"github.com/halilylm/secondhand/messaging/consumer"
+	"github.com/halilylm/secondhand/messaging/deadletter"
+	"github.com/halilylm/secondhand/messaging/inbox"
+	"github.com/halilylm/secondhand/messaging/outbox"
+	"github.com/halilylm/secondhand/messaging/projection"
+	"github.com/halilylm/secondhand/messaging/roles"
 	_orderHandler "github.com/halilylm/secondhand/orders/orders/delivery/http"
+	_orderTicker "github.com/halilylm/secondhand/orders/orders/delivery/ticker"
 	_orderRepo "github.com/halilylm/secondhand/orders/orders/repository/mongodb"
 	_orderUC "github.com/halilylm/secondhand/orders/orders/usecase"
 	_productUC "github.com/halilylm/secondhand/orders/product/usecase"
@@ -40,7 +51,7 @@ func main() {
 	appLogger.Init()
 
 	// env variables checkpoint
-	utils.RequireEnvVariables("MONGO_URI", "APP_PORT", "JWT_KEY", "NATS_URI", "NATS_CLUSTER_ID", "NATS_CLIENT_ID")
+	utils.RequireEnvVariables("MONGO_URI", "APP_PORT", "JWT_KEY")
 
 	// connect to mongodb
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -50,13 +61,19 @@ func main() {
 		appLogger.Fatal(err)
 	}
 
-	// connect to nats
-	streaming, err := nats.New(nats.Options{
-		nil,
-		appLogger,
-		[]string{os.Getenv("NATS_URI")},
-		os.Getenv("NATS_CLUSTER_ID"),
-		os.Getenv("NATS_CLIENT_ID"),
+	// connect to the event bus
+	// nats streaming unless another bus is configured,
+	// two joined with "+" while migrating between them,
+	// memory when every service runs in this process
+	streaming, err := bus.New(bus.Config{
+		Bus:           os.Getenv("EVENT_BUS"),
+		NATSURL:       os.Getenv("NATS_URI"),
+		ClusterID:     os.Getenv("NATS_CLUSTER_ID"),
+		ClientID:      os.Getenv("NATS_CLIENT_ID"),
+		Stream:        os.Getenv("JETSTREAM_STREAM"),
+		KafkaBrokers:  os.Getenv("KAFKA_BROKERS"),
+		SingleProcess: os.Getenv("SINGLE_PROCESS") == "true",
+		Logger:        appLogger,
 	})
 	if err != nil {
 		appLogger.Fatal(err)
@@ -65,13 +82,54 @@ func main() {
 	// init collections
 	orderCollection := client.Database("orders").Collection("order")
 	productCollection := client.Database("orders").Collection("product")
+	cartCollection := client.Database("orders").Collection("cart")
+	outboxCollection := client.Database("orders").Collection("outbox")
+	inboxCollection := client.Database("orders").Collection("inbox")
+	deadLetterCollection := client.Database("orders").Collection("dead_letter")
+	sagaCollection := client.Database("orders").Collection("saga")
+
+	// create indexes
+	if err := outbox.CreateIndexes(ctx, outboxCollection); err != nil {
+		appLogger.Fatal(err)
+	}
+	if err := inbox.CreateIndexes(ctx, inboxCollection); err != nil {
+		appLogger.Fatal(err)
+	}
+	if err := deadletter.CreateIndexes(ctx, deadLetterCollection); err != nil {
+		appLogger.Fatal(err)
+	}
+	if err := _orderRepo.CreateOrderIndexes(ctx, orderCollection); err != nil {
+		appLogger.Fatal(err)
+	}
+	if err := _orderRepo.CreateSagaIndexes(ctx, sagaCollection); err != nil {
+		appLogger.Fatal(err)
+	}
+
+	// backfill the stock of products replicated by earlier versions
+	if err := _productRepo.MigrateProducts(context.Background(), productCollection, orderCollection); err != nil {
+		appLogger.Fatal(err)
+	}
 
 	// init repositories
 	orderRepo := _orderRepo.NewOrderRepository(orderCollection)
 	productRepo := _productRepo.NewProductRepository(productCollection)
+	cartRepo := _cartRepo.NewCartRepository(cartCollection)
+	sagaRepo := _orderRepo.NewSagaRepository(sagaCollection)
+	outboxStore := outbox.NewStore(outboxCollection, appLogger)
+	inboxStore := inbox.NewStore(inboxCollection, outboxStore)
+	deadLetterStore := deadletter.NewStore(deadLetterCollection)
+
+	// init the consumer processor
+	// failing events are retried then dead lettered
+	processor := consumer.NewProcessor(streaming, deadLetterStore, appLogger, consumer.DefaultPolicy)
+
+	// init projections
+	// early events wait at most 10 seconds for their predecessor
+	projector := projection.NewProjector("orders_product", 10*time.Second)
 
 	// init usecases
-	orderUC := _orderUC.NewOrder(productRepo, orderRepo, appLogger, streaming)
+	orderUC := _orderUC.NewOrder(productRepo, orderRepo, cartRepo, sagaRepo, appLogger, outboxStore)
+	cartUC := _cartUC.NewCart(cartRepo, productRepo, appLogger)
 	ticketUC := _productUC.NewProduct(productRepo, appLogger)
 
 	// set routes
@@ -85,14 +143,43 @@ func main() {
 		return c.JSON(rest.ErrorResponse(rest.NewNotFoundError()))
 	}
 
+	// projection metrics are served on the internal
+	// listener only, never on the public port
+	metrics := echo.New()
+	metrics.GET("/debug/vars", echo.WrapHandler(expvar.Handler()))
+
 	// init handlers
 	_orderHandler.NewOrderHandler(v1, orderUC)
+	_cartHandler.NewCartHandler(v1.Group("/cart"), cartUC, orderUC)
+	appRoles := roles.New()
+	appRoles.Grant(roles.Admin, os.Getenv("ADMIN_USER_IDS"))
+	admin := appRoles.Require(roles.Admin)
+	_orderHandler.NewSagaHandler(v1.Group("/sagas"), orderUC, admin)
+	deadletter.NewAdminHandler(v1.Group("/dead-letters"), deadLetterStore, streaming, appLogger, admin)
+
+	// init consumer groups
+	// each group runs 10 workers per subject
+	runners := consumer.Runners{
+		consumer.NewRunner(streaming, processor, _productStream.NewProductConsumerGroup(ticketUC, inboxStore, projector, "orders-product-consumer"), 10),
+		consumer.NewRunner(streaming, processor, _orderStream.NewPaymentConsumerGroup(orderUC, inboxStore, "orders-payment-consumer"), 10),
+		consumer.NewRunner(streaming, processor, _orderStream.NewAuctionConsumerGroup(orderUC, inboxStore, "orders-auction-consumer"), 10),
+		consumer.NewRunner(streaming, processor, _orderStream.NewOfferConsumerGroup(orderUC, inboxStore, "orders-offer-consumer"), 10),
+		consumer.NewRunner(streaming, processor, _orderStream.NewSagaConsumerGroup(orderUC, inboxStore, "orders-saga-consumer"), 10),
+	}
+	if err := runners.Start(); err != nil {
+		appLogger.Fatal(err)
+	}
+	e.GET("/health", runners.HealthHandler())
 
-	productConsumerGroup := _productStream.NewProductConsumerGroup(streaming, ticketUC, "orders-product-consumer")
-	productConsumerGroup.RunConsumers()
+	// publish the outbox until shutdown
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+	go outbox.NewRelay(outboxCollection, streaming, appLogger, time.Second).Run(relayCtx)
 
-	paymentConsumerGroup := _orderStream.NewPaymentConsumerGroup(streaming, orderUC, "orders-payment-consumer")
-	paymentConsumerGroup.RunConsumers()
+	// compensate timed out sagas until shutdown
+	expirerCtx, stopExpirer := context.WithCancel(context.Background())
+	defer stopExpirer()
+	go _orderTicker.NewExpirer(orderUC, 10*time.Second).Run(expirerCtx)
 
 	// start the application
 	go func() {
@@ -100,17 +187,37 @@ func main() {
 			appLogger.Fatal(err)
 		}
 	}()
+	if addr := os.Getenv("METRICS_ADDR"); addr != "" {
+		go func() {
+			if err := metrics.Start(addr); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				appLogger.Fatal(err)
+			}
+		}()
+	}
 
 	// block until program interrupted
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt)
 	<-quit
 
+	// stop expiring sagas and publishing the outbox
+	stopExpirer()
+	stopRelay()
+
 	// graceful shutdown
 	// don't wait more than 30 seconds
 	// to gracefully shut down the server
 	ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
+	if err := runners.Shutdown(ctx); err != nil {
+		appLogger.Error(err)
+	}
+	if err := streaming.Close(); err != nil {
+		appLogger.Error(err)
+	}
+	if err := metrics.Shutdown(ctx); err != nil {
+		appLogger.Error(err)
+	}
 	if err := e.Shutdown(ctx); err != nil {
 		appLogger.Fatal(err)
 	}
@@ -0,0 +1,92 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/halilylm/gommon/middlewares"
+	"github.com/halilylm/gommon/rest"
+	"github.com/halilylm/gommon/utils"
+	"github.com/halilylm/secondhand/orders/cart/usecase"
+	"github.com/halilylm/secondhand/orders/domain"
+	_orderUC "github.com/halilylm/secondhand/orders/orders/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+type cartHandler struct {
+	cartUC  usecase.Cart
+	orderUC _orderUC.Order
+}
+
+// NewCartHandler handler for the cart of the user,
+// the group must already use the jwt middleware
+func NewCartHandler(g *echo.Group, cartUC usecase.Cart, orderUC _orderUC.Order) {
+	handler := &cartHandler{cartUC: cartUC, orderUC: orderUC}
+
+	g.GET("", handler.ShowCart)
+	g.PUT("/:product_id", handler.SetItem)
+	g.DELETE("/:product_id", handler.RemoveItem)
+	g.POST("/checkout", handler.Checkout)
+}
+
+func (h *cartHandler) ShowCart(c echo.Context) error {
+	// get user from the context
+	user := middlewares.UserFromContext(c)
+
+	// call the usecase
+	cart, err := h.cartUC.ShowCart(c.Request().Context(), user.ID)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, cart)
+}
+
+func (h *cartHandler) SetItem(c echo.Context) error {
+	// bind the request, the body is optional
+	var request domain.CartItemRequest
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewBadRequestError(err.Error())))
+	}
+
+	// validate the struct
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewValidationErrors(err)))
+	}
+
+	// get user from the context
+	user := middlewares.UserFromContext(c)
+
+	// call the usecase
+	cart, err := h.cartUC.SetItem(c.Request().Context(), user.ID, c.Param("product_id"), request.Quantity)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, cart)
+}
+
+func (h *cartHandler) RemoveItem(c echo.Context) error {
+	// get user from the context
+	user := middlewares.UserFromContext(c)
+
+	// call the usecase
+	cart, err := h.cartUC.RemoveItem(c.Request().Context(), user.ID, c.Param("product_id"))
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, cart)
+}
+
+func (h *cartHandler) Checkout(c echo.Context) error {
+	// get user from the context
+	user := middlewares.UserFromContext(c)
+
+	// call the usecase
+	createdOrder, err := h.orderUC.Checkout(c.Request().Context(), user.ID)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusCreated, createdOrder)
+}
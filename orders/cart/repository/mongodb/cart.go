@@ -0,0 +1,105 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/halilylm/secondhand/orders/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type cartRepository struct {
+	collection *mongo.Collection
+}
+
+// NewCartRepository returns a new mongo cart repository
+func NewCartRepository(collection *mongo.Collection) domain.CartRepository {
+	return &cartRepository{collection: collection}
+}
+
+// FindByUser finds the cart of the user
+func (c *cartRepository) FindByUser(ctx context.Context, userID string) (*domain.Cart, error) {
+	var foundCart domain.Cart
+	res := c.collection.FindOne(ctx, bson.M{"_id": userID})
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&foundCart); err != nil {
+		return nil, err
+	}
+	return &foundCart, nil
+}
+
+// SetItem sets the quantity of a product in the cart or adds it,
+// the cart is created with its first item
+func (c *cartRepository) SetItem(ctx context.Context, userID string, item *domain.CartItem, maxItems int) (*domain.Cart, error) {
+	var updatedCart domain.Cart
+	now := time.Now().UTC()
+
+	// the product is in the cart already
+	res := c.collection.FindOneAndUpdate(ctx, bson.M{
+		"_id":              userID,
+		"items.product_id": item.ProductID,
+	}, bson.M{"$set": bson.M{
+		"items.$.quantity": item.Quantity,
+		"updated_at":       now,
+	}}, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if res.Err() == nil {
+		if err := res.Decode(&updatedCart); err != nil {
+			return nil, err
+		}
+		return &updatedCart, nil
+	}
+	if res.Err() != mongo.ErrNoDocuments {
+		return nil, res.Err()
+	}
+
+	// add the product to a cart with room left, a full cart
+	// does not match so the upsert collides with its id
+	res = c.collection.FindOneAndUpdate(ctx, bson.M{
+		"_id":                               userID,
+		"items.product_id":                  bson.M{"$ne": item.ProductID},
+		fmt.Sprintf("items.%d", maxItems-1): bson.M{"$exists": false},
+	}, bson.M{
+		"$push": bson.M{"items": item},
+		"$set":  bson.M{"updated_at": now},
+	}, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After))
+	if res.Err() != nil {
+		if mongo.IsDuplicateKeyError(res.Err()) {
+			return nil, domain.ErrCartFull
+		}
+		return nil, res.Err()
+	}
+	if err := res.Decode(&updatedCart); err != nil {
+		return nil, err
+	}
+	return &updatedCart, nil
+}
+
+// RemoveItem takes the product out of the cart
+func (c *cartRepository) RemoveItem(ctx context.Context, userID, productID string) (*domain.Cart, error) {
+	var updatedCart domain.Cart
+	res := c.collection.FindOneAndUpdate(ctx, bson.M{
+		"_id":              userID,
+		"items.product_id": productID,
+	}, bson.M{
+		"$pull": bson.M{"items": bson.M{"product_id": productID}},
+		"$set":  bson.M{"updated_at": time.Now().UTC()},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&updatedCart); err != nil {
+		return nil, err
+	}
+	return &updatedCart, nil
+}
+
+// Delete empties the cart of the user
+func (c *cartRepository) Delete(ctx context.Context, userID string) error {
+	_, err := c.collection.DeleteOne(ctx, bson.M{"_id": userID})
+	return err
+}
@@ -0,0 +1,124 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/halilylm/gommon/logger"
+	"github.com/halilylm/gommon/rest"
+	"github.com/halilylm/secondhand/orders/domain"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type cart struct {
+	cartRepo    domain.CartRepository
+	productRepo domain.ProductRepository
+	logger      logger.Logger
+}
+
+// NewCart returns cart usecase
+func NewCart(cartRepo domain.CartRepository, productRepo domain.ProductRepository, logger logger.Logger) Cart {
+	return &cart{cartRepo: cartRepo, productRepo: productRepo, logger: logger}
+}
+
+// ShowCart returns the cart of the user with the current
+// products, users without a cart get an empty one
+func (c *cart) ShowCart(ctx context.Context, userID string) (*domain.Cart, error) {
+	foundCart, err := c.cartRepo.FindByUser(ctx, userID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return &domain.Cart{UserID: userID, Items: []*domain.CartItem{}}, nil
+		}
+		c.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	if err := c.attachProducts(ctx, foundCart); err != nil {
+		return nil, err
+	}
+	return foundCart, nil
+}
+
+// SetItem puts the product in the cart of the user with the
+// quantity, stock is only checked at checkout
+func (c *cart) SetItem(ctx context.Context, userID, productID string, quantity int) (*domain.Cart, error) {
+	// a single item unless told otherwise
+	if quantity == 0 {
+		quantity = 1
+	}
+
+	// find the ticket
+	product, err := c.productRepo.FindByID(ctx, productID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, rest.NewNotFoundError()
+		}
+		c.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	if err := product.Orderable(); err != nil {
+		return nil, rest.NewBadRequestError(err.Error())
+	}
+
+	updatedCart, err := c.cartRepo.SetItem(ctx, userID, &domain.CartItem{
+		ProductID: productID,
+		Quantity:  quantity,
+		AddedAt:   time.Now().UTC(),
+	}, domain.MaxCartItems)
+	if err != nil {
+		if err == domain.ErrCartFull {
+			return nil, rest.NewBadRequestError(err.Error())
+		}
+		c.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	if err := c.attachProducts(ctx, updatedCart); err != nil {
+		return nil, err
+	}
+	return updatedCart, nil
+}
+
+// RemoveItem takes the product out of the cart of the user
+func (c *cart) RemoveItem(ctx context.Context, userID, productID string) (*domain.Cart, error) {
+	updatedCart, err := c.cartRepo.RemoveItem(ctx, userID, productID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, rest.NewNotFoundError()
+		}
+		c.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	if err := c.attachProducts(ctx, updatedCart); err != nil {
+		return nil, err
+	}
+	return updatedCart, nil
+}
+
+// attachProducts sets the replicas of the products on the cart items
+func (c *cart) attachProducts(ctx context.Context, cart *domain.Cart) error {
+	if len(cart.Items) == 0 {
+		return nil
+	}
+	productIDs := make([]string, 0, len(cart.Items))
+	for _, item := range cart.Items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	products, err := c.productRepo.FindByIDs(ctx, productIDs)
+	if err != nil {
+		c.logger.Error(err)
+		return rest.NewInternalServerError()
+	}
+	productsByID := make(map[string]*domain.Product, len(products))
+	for _, product := range products {
+		productsByID[product.ID] = product
+	}
+	for _, item := range cart.Items {
+		item.Product = productsByID[item.ProductID]
+	}
+	return nil
+}
+
+type Cart interface {
+	ShowCart(ctx context.Context, userID string) (*domain.Cart, error)
+	SetItem(ctx context.Context, userID, productID string, quantity int) (*domain.Cart, error)
+	RemoveItem(ctx context.Context, userID, productID string) (*domain.Cart, error)
+}
@@ -0,0 +1,23 @@
+package domain
+
+import "errors"
+
+// ListingAuction marks products sold by auction
+const ListingAuction = "auction"
+
+// ErrAuctionProduct returned when ordering a product
+// that is sold to the winning bidder
+var ErrAuctionProduct = errors.New("auction products are sold to the winning bidder")
+
+// AuctionWon is published by products when
+// an auction closes above its reserve price
+const AuctionWon = "auction:won"
+
+// AuctionWonEvent consumed to create
+// the order of the winning bidder
+type AuctionWonEvent struct {
+	ProductID string `json:"product_id"`
+	UserID    string `json:"user_id"`
+	BidID     string `json:"bid_id"`
+	Amount    int    `json:"amount"`
+}
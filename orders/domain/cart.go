@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// MaxCartItems a cart can hold
+const MaxCartItems = 20
+
+// ErrEmptyCart returned when checking out a cart without items
+var ErrEmptyCart = errors.New("cart is empty")
+
+// ErrCartFull returned when a cart reached the maximum number of items
+var ErrCartFull = errors.New("cart is full")
+
+// ErrProductUnavailable returned when a product in
+// the cart cannot be ordered anymore
+var ErrProductUnavailable = errors.New("product is not available")
+
+// Cart of a user, kept until checkout
+type Cart struct {
+	// UserID is the id of the cart, a user has one cart
+	UserID    string      `json:"user_id" bson:"_id"`
+	Items     []*CartItem `json:"items" bson:"items"`
+	UpdatedAt time.Time   `json:"updated_at" bson:"updated_at"`
+}
+
+// CartItem is a product the user wants to order
+type CartItem struct {
+	ProductID string    `json:"product_id" bson:"product_id"`
+	Quantity  int       `json:"quantity" bson:"quantity"`
+	AddedAt   time.Time `json:"added_at" bson:"added_at"`
+	// Product is the current replica of the product,
+	// set for responses only
+	Product *Product `json:"product,omitempty" bson:"-"`
+}
+
+// CartItemRequest holds what the user sends to put
+// a product in the cart, the quantity defaults to one
+type CartItemRequest struct {
+	Quantity int `json:"quantity" validate:"min=0"`
+}
+
+// CartRepository to interact db
+type CartRepository interface {
+	FindByUser(ctx context.Context, userID string) (*Cart, error)
+	SetItem(ctx context.Context, userID string, item *CartItem, maxItems int) (*Cart, error)
+	RemoveItem(ctx context.Context, userID, productID string) (*Cart, error)
+	Delete(ctx context.Context, userID string) error
+}
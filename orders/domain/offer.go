@@ -0,0 +1,28 @@
+package domain
+
+// OfferAgreed is published by products when the
+// seller and the buyer agree on the price
+const OfferAgreed = "offer:agreed"
+
+// OfferAgreedEvent consumed to create the order
+// of the buyer at the agreed price
+type OfferAgreedEvent struct {
+	OfferID   string `json:"offer_id"`
+	Version   int    `json:"version"`
+	ProductID string `json:"product_id"`
+	UserID    string `json:"user_id"`
+	Amount    int    `json:"amount"`
+	Quantity  int    `json:"quantity"`
+}
+
+// OfferDeclined is published when the order of an agreed
+// offer cannot be placed, products open the offer again
+const OfferDeclined = "offer:declined"
+
+// OfferDeclinedEvent tells products why the agreed
+// offer as of the version has no order
+type OfferDeclinedEvent struct {
+	OfferID string `json:"offer_id"`
+	Version int    `json:"version"`
+	Reason  string `json:"reason"`
+}
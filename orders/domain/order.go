@@ -3,16 +3,145 @@ package domain
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"github.com/halilylm/gommon/events/common/messages"
 	"github.com/halilylm/gommon/events/common/types"
 )
 
+// ErrOutOfStock returned when the remaining
+// stock cannot cover the order
+var ErrOutOfStock = errors.New("not enough stock")
+
+// ErrOrderComplete returned when deleting a paid order
+var ErrOrderComplete = errors.New("paid orders cannot be deleted")
+
 // Order domain
 type Order struct {
 	ID        string            `json:"id" bson:"_id,omitempty"`
 	UserID    string            `json:"user_id" bson:"user_id"`
 	Status    types.OrderStatus `json:"status" bson:"status"`
 	ProductID string            `json:"product_id" bson:"product_id"`
-	Version   int               `json:"version,omitempty" bson:"version"`
+	Quantity  int               `json:"quantity" bson:"quantity"`
+	Charge    int               `json:"charge" bson:"charge"`
+	// OfferID is set for orders of agreed offers
+	OfferID string `json:"offer_id,omitempty" bson:"offer_id,omitempty"`
+	// BidID is set for orders of won auctions
+	BidID string `json:"bid_id,omitempty" bson:"bid_id,omitempty"`
+	// Product is the product as it was ordered,
+	// receipts keep it whatever happens to the product
+	Product ProductSnapshot `json:"product" bson:"product"`
+	// Items are the line items of orders checked out
+	// from the cart, ProductID is empty for them
+	Items []*LineItem `json:"items,omitempty" bson:"items,omitempty"`
+	// Fulfilments group the line items by seller
+	Fulfilments []*Fulfilment `json:"fulfilments,omitempty" bson:"fulfilments,omitempty"`
+	Version     int           `json:"version,omitempty" bson:"version"`
+}
+
+// LineItem is a product of a cart order, the
+// snapshot keeps it as it was when ordered
+type LineItem struct {
+	ProductID string          `json:"product_id" bson:"product_id"`
+	SellerID  string          `json:"seller_id" bson:"seller_id"`
+	Quantity  int             `json:"quantity" bson:"quantity"`
+	Charge    int             `json:"charge" bson:"charge"`
+	Product   ProductSnapshot `json:"product" bson:"product"`
+}
+
+// Fulfilment is the part of a cart order a seller ships
+type Fulfilment struct {
+	SellerID   string   `json:"seller_id" bson:"seller_id"`
+	ProductIDs []string `json:"product_ids" bson:"product_ids"`
+	Charge     int      `json:"charge" bson:"charge"`
+}
+
+// NewFulfilments groups the line items by seller
+// in the order the sellers first appear
+func NewFulfilments(items []*LineItem) []*Fulfilment {
+	fulfilments := make([]*Fulfilment, 0)
+	bySeller := make(map[string]*Fulfilment)
+	for _, item := range items {
+		fulfilment, found := bySeller[item.SellerID]
+		if !found {
+			fulfilment = &Fulfilment{SellerID: item.SellerID}
+			bySeller[item.SellerID] = fulfilment
+			fulfilments = append(fulfilments, fulfilment)
+		}
+		fulfilment.ProductIDs = append(fulfilment.ProductIDs, item.ProductID)
+		fulfilment.Charge += item.Charge
+	}
+	return fulfilments
+}
+
+// OrderItem is a product and quantity of an order in events
+type OrderItem struct {
+	ProductID string `json:"product_id" bson:"product_id"`
+	Quantity  int    `json:"quantity" bson:"quantity"`
+	Charge    int    `json:"charge" bson:"charge"`
+}
+
+// OrderItems returns the products of the order, orders of a single
+// product and those placed before quantities hold one item
+func (o *Order) OrderItems() []OrderItem {
+	if len(o.Items) > 0 {
+		items := make([]OrderItem, 0, len(o.Items))
+		for _, item := range o.Items {
+			items = append(items, OrderItem{
+				ProductID: item.ProductID,
+				Quantity:  item.Quantity,
+				Charge:    item.Charge,
+			})
+		}
+		return items
+	}
+	quantity := o.Quantity
+	if quantity == 0 {
+		quantity = 1
+	}
+	return []OrderItem{{ProductID: o.ProductID, Quantity: quantity, Charge: o.Charge}}
+}
+
+// ProductSnapshot holds the title and price of the product at
+// order time, the version points into the product history
+type ProductSnapshot struct {
+	Title   string `json:"title" bson:"title"`
+	Price   int    `json:"price" bson:"price"`
+	Version int    `json:"version" bson:"version"`
+}
+
+// OrderRequest holds what the buyer sends with the order,
+// the quantity defaults to a single item
+type OrderRequest struct {
+	Quantity int `json:"quantity" validate:"min=0"`
+}
+
+// OrderCompleted is published when the order is paid
+const OrderCompleted = "order:completed"
+
+// OrderCompletedEvent tells other services the buyer got the
+// product, cart orders publish one for each of their sellers
+type OrderCompletedEvent struct {
+	ID        string `json:"id"`
+	Version   int    `json:"version"`
+	UserID    string `json:"user_id"`
+	ProductID string `json:"product_id"`
+	// SellerID is set for the sellers of cart orders
+	SellerID string `json:"seller_id,omitempty"`
+}
+
+// OrderCreatedEvent is the shared event extended with the
+// ordered quantity and the line items of cart orders
+type OrderCreatedEvent struct {
+	messages.OrderCreatedEvent
+	Quantity int         `json:"quantity"`
+	Items    []OrderItem `json:"items,omitempty"`
+}
+
+// OrderCancelledEvent is the shared event
+// extended with the line items of cart orders
+type OrderCancelledEvent struct {
+	messages.OrderCancelledEvent
+	Items []OrderItem `json:"items,omitempty"`
 }
 
 func (o *Order) Marshal() []byte {
@@ -22,9 +151,11 @@ func (o *Order) Marshal() []byte {
 
 type OrderRepository interface {
 	Insert(ctx context.Context, order *Order) (*Order, error)
-	IsReserved(ctx context.Context, ticketID string) bool
 	FindByID(ctx context.Context, id string) (*Order, error)
+	FindByBidID(ctx context.Context, bidID string) (*Order, error)
+	FindByOfferID(ctx context.Context, offerID string) (*Order, error)
 	Delete(ctx context.Context, id string) error
 	ListUserOrders(ctx context.Context, userID string) ([]*Order, error)
 	UpdateStatus(ctx context.Context, id string, status types.OrderStatus) (*Order, error)
+	Cancel(ctx context.Context, id string) (*Order, error)
 }
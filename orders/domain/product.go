@@ -2,18 +2,73 @@ package domain
 
 import (
 	"context"
+	"github.com/halilylm/gommon/events/common/messages"
 )
 
 // Product domain
 type Product struct {
-	ID      string `json:"id" bson:"_id,omitempty" validate:"required"`
-	Title   string `json:"title" bson:"title"`
-	Price   int    `json:"price" bson:"price"`
-	Version int    `json:"version" bson:"version"`
+	ID       string `json:"id" bson:"_id,omitempty" validate:"required"`
+	Title    string `json:"title" bson:"title"`
+	Price    int    `json:"price" bson:"price"`
+	Version  int    `json:"version" bson:"version"`
+	Deleted  bool   `json:"deleted" bson:"deleted"`
+	Quantity int    `json:"quantity" bson:"quantity"`
+	Listing  string `json:"listing" bson:"listing"`
+	SellerID string `json:"seller_id" bson:"seller_id"`
+	// Hidden products wait for or were
+	// rejected by moderators, they cannot be ordered
+	Hidden bool `json:"hidden" bson:"hidden"`
+	// Reserved is the stock held by active orders,
+	// kept by this service only
+	Reserved int `json:"reserved" bson:"reserved"`
+}
+
+// Orderable tells why the product cannot be put in a cart,
+// auctions are sold to the winning bidder only
+func (p *Product) Orderable() error {
+	if p.Deleted || p.Hidden {
+		return ErrProductUnavailable
+	}
+	if p.Listing == ListingAuction {
+		return ErrAuctionProduct
+	}
+	return nil
+}
+
+// ProductCreatedEvent is the shared event
+// extended with the stock, listing and visibility of the product
+type ProductCreatedEvent struct {
+	messages.ProductCreatedEvent
+	Quantity int    `json:"quantity"`
+	Listing  string `json:"listing"`
+	Hidden   bool   `json:"hidden"`
+}
+
+// ProductUpdatedEvent is the shared event
+// extended with the stock, listing and visibility of the product
+type ProductUpdatedEvent struct {
+	messages.ProductUpdatedEvent
+	Quantity int    `json:"quantity"`
+	Listing  string `json:"listing"`
+	Hidden   bool   `json:"hidden"`
+}
+
+// ProductDeleted is published by products when
+// a seller takes down a listing
+const ProductDeleted = "product:deleted"
+
+// ProductDeletedEvent consumed to mark the replica unavailable
+type ProductDeletedEvent struct {
+	ID      string `json:"id"`
+	Version int    `json:"version"`
 }
 
 type ProductRepository interface {
 	FindByID(ctx context.Context, id string) (*Product, error)
+	FindByIDs(ctx context.Context, ids []string) ([]*Product, error)
 	Insert(ctx context.Context, product *Product) (*Product, error)
 	Update(ctx context.Context, product *Product) (*Product, error)
+	MarkDeleted(ctx context.Context, id string, version int) (*Product, error)
+	Reserve(ctx context.Context, id string, quantity int) (*Product, error)
+	Release(ctx context.Context, id string, quantity int) (*Product, error)
 }
@@ -0,0 +1,167 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrSagaConflict returned when another event moved
+// the saga on while this one was being handled
+var ErrSagaConflict = errors.New("saga changed meanwhile")
+
+// ErrNotReserved returned when the payment of an order
+// arrives before the products confirmed the reservation
+var ErrNotReserved = errors.New("order is not reserved yet")
+
+// SagaStep is where a purchase is in its saga
+type SagaStep string
+
+const (
+	// SagaReserving waits for products to hold the stock
+	SagaReserving SagaStep = "reserving"
+	// SagaAwaitingPayment waits for the buyer to pay
+	SagaAwaitingPayment SagaStep = "awaiting_payment"
+	// SagaCompleted is a paid order
+	SagaCompleted SagaStep = "completed"
+	// SagaCancelled is an order rejected, timed out or cancelled,
+	// its stock is given back
+	SagaCancelled SagaStep = "cancelled"
+	// SagaRefunding waits for payments to refund
+	// an order paid after it was cancelled
+	SagaRefunding SagaStep = "refunding"
+	// SagaRefunded is a cancelled order whose payment is given back
+	SagaRefunded SagaStep = "refunded"
+)
+
+// Timeouts of the steps, the saga is compensated
+// when nothing moved it on within them
+const (
+	ReservationTimeout = time.Minute
+	PaymentTimeout     = 30 * time.Minute
+	RefundTimeout      = 10 * time.Minute
+)
+
+// Saga tracks the purchase of an order across the services, it shares
+// the id of the order and outlives it when the buyer deletes the order
+type Saga struct {
+	ID     string      `json:"id" bson:"_id"`
+	UserID string      `json:"user_id" bson:"user_id"`
+	Step   SagaStep    `json:"step" bson:"step"`
+	Items  []OrderItem `json:"items" bson:"items"`
+	// OrderVersion is the version of the order the
+	// last event of the saga was published with
+	OrderVersion int    `json:"order_version" bson:"order_version"`
+	PaymentID    string `json:"payment_id,omitempty" bson:"payment_id,omitempty"`
+	StripeID     string `json:"stripe_id,omitempty" bson:"stripe_id,omitempty"`
+	// Reason tells why the saga was compensated
+	Reason string `json:"reason,omitempty" bson:"reason,omitempty"`
+	// Deadline is when the step times out,
+	// finished sagas have none
+	Deadline  *time.Time        `json:"deadline,omitempty" bson:"deadline,omitempty"`
+	History   []*SagaTransition `json:"history" bson:"history"`
+	CreatedAt time.Time         `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at" bson:"updated_at"`
+	Version   int               `json:"version" bson:"version"`
+}
+
+// SagaTransition is a step the saga took
+type SagaTransition struct {
+	Step   SagaStep  `json:"step" bson:"step"`
+	Reason string    `json:"reason,omitempty" bson:"reason,omitempty"`
+	At     time.Time `json:"at" bson:"at"`
+}
+
+// NewSaga starts the saga of the order, waiting for the reservation
+func NewSaga(order *Order, now time.Time) *Saga {
+	saga := &Saga{
+		ID:           order.ID,
+		UserID:       order.UserID,
+		Items:        order.OrderItems(),
+		OrderVersion: order.Version,
+		CreatedAt:    now,
+	}
+	saga.MoveTo(SagaReserving, "order placed", now)
+	return saga
+}
+
+// MoveTo takes the saga to the step and sets the deadline of it
+func (s *Saga) MoveTo(step SagaStep, reason string, now time.Time) {
+	s.Step = step
+	s.UpdatedAt = now
+	s.History = append(s.History, &SagaTransition{Step: step, Reason: reason, At: now})
+	s.Deadline = nil
+	if timeout, found := step.Timeout(); found {
+		deadline := now.Add(timeout)
+		s.Deadline = &deadline
+	}
+	if step == SagaCancelled {
+		s.Reason = reason
+	}
+}
+
+// Timeout returns how long the step may take
+func (s SagaStep) Timeout() (time.Duration, bool) {
+	switch s {
+	case SagaReserving:
+		return ReservationTimeout, true
+	case SagaAwaitingPayment:
+		return PaymentTimeout, true
+	case SagaRefunding:
+		return RefundTimeout, true
+	}
+	return 0, false
+}
+
+// ActiveSagaSteps are the steps waiting for an event
+var ActiveSagaSteps = []SagaStep{SagaReserving, SagaAwaitingPayment, SagaRefunding}
+
+// OrderReserved is published by products when
+// the stock of every item of the order is held
+const OrderReserved = "order:reserved"
+
+// OrderReservedEvent tells the order can be paid
+type OrderReservedEvent struct {
+	ID string `json:"id"`
+}
+
+// OrderRejected is published by products when
+// the stock of the order cannot be held
+const OrderRejected = "order:rejected"
+
+// OrderRejectedEvent tells why the order cannot be fulfilled
+type OrderRejectedEvent struct {
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// RefundRequested is published when an order
+// is paid after its saga was cancelled
+const RefundRequested = "payment:refund-requested"
+
+// RefundRequestedEvent asks payments to give the charge back
+type RefundRequestedEvent struct {
+	OrderID   string `json:"order_id"`
+	PaymentID string `json:"payment_id"`
+	StripeID  string `json:"stripe_id"`
+	Reason    string `json:"reason"`
+}
+
+// PaymentRefunded is published by payments when a charge is given back
+const PaymentRefunded = "payment:refunded"
+
+// PaymentRefundedEvent tells the order is refunded
+type PaymentRefundedEvent struct {
+	ID       string `json:"id"`
+	OrderID  string `json:"order_id"`
+	RefundID string `json:"refund_id"`
+}
+
+// SagaRepository keeps the sagas of the purchases
+type SagaRepository interface {
+	Insert(ctx context.Context, saga *Saga) (*Saga, error)
+	FindByID(ctx context.Context, id string) (*Saga, error)
+	Update(ctx context.Context, saga *Saga) (*Saga, error)
+	FindDue(ctx context.Context, now time.Time, limit int) ([]*Saga, error)
+	ListActive(ctx context.Context, limit int) ([]*Saga, error)
+}
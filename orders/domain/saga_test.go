@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSagaStepTimeout(t *testing.T) {
+	for _, tt := range []struct {
+		step     SagaStep
+		want     time.Duration
+		timesOut bool
+	}{
+		{SagaReserving, ReservationTimeout, true},
+		{SagaAwaitingPayment, PaymentTimeout, true},
+		{SagaRefunding, RefundTimeout, true},
+		{SagaCompleted, 0, false},
+		{SagaCancelled, 0, false},
+		{SagaRefunded, 0, false},
+	} {
+		t.Run(string(tt.step), func(t *testing.T) {
+			timeout, found := tt.step.Timeout()
+			if timeout != tt.want || found != tt.timesOut {
+				t.Errorf("Timeout() = %v, %t, want %v, %t", timeout, found, tt.want, tt.timesOut)
+			}
+		})
+	}
+}
+
+func TestSagaMoveTo(t *testing.T) {
+	placed := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	now := placed.Add(time.Minute)
+	for _, tt := range []struct {
+		name       string
+		step       SagaStep
+		reason     string
+		deadline   time.Duration
+		wantReason string
+	}{
+		{name: "reserved", step: SagaAwaitingPayment, reason: "order reserved", deadline: PaymentTimeout},
+		{name: "paid", step: SagaCompleted, reason: "order paid"},
+		{name: "rejected", step: SagaCancelled, reason: "out of stock", wantReason: "out of stock"},
+		{name: "paid after cancel", step: SagaRefunding, reason: "order paid after cancel", deadline: RefundTimeout},
+		{name: "refunded", step: SagaRefunded, reason: "payment refunded"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			saga := NewSaga(&Order{ID: "order-1", UserID: "user-1"}, placed)
+			if saga.Step != SagaReserving || saga.Deadline == nil || !saga.Deadline.Equal(placed.Add(ReservationTimeout)) {
+				t.Fatalf("new saga at %s until %v", saga.Step, saga.Deadline)
+			}
+
+			saga.MoveTo(tt.step, tt.reason, now)
+			if saga.Step != tt.step || !saga.UpdatedAt.Equal(now) {
+				t.Errorf("saga at %s updated at %v", saga.Step, saga.UpdatedAt)
+			}
+			if tt.deadline == 0 && saga.Deadline != nil {
+				t.Errorf("finished saga has a deadline %v", *saga.Deadline)
+			}
+			if tt.deadline != 0 && (saga.Deadline == nil || !saga.Deadline.Equal(now.Add(tt.deadline))) {
+				t.Errorf("deadline = %v, want %v", saga.Deadline, now.Add(tt.deadline))
+			}
+			if saga.Reason != tt.wantReason {
+				t.Errorf("reason = %q, want %q", saga.Reason, tt.wantReason)
+			}
+			if len(saga.History) != 2 {
+				t.Fatalf("%d transitions, want 2", len(saga.History))
+			}
+			last := saga.History[1]
+			if last.Step != tt.step || last.Reason != tt.reason || !last.At.Equal(now) {
+				t.Errorf("transition to %s for %q at %v", last.Step, last.Reason, last.At)
+			}
+		})
+	}
+}
@@ -3,6 +3,8 @@ package http
 import (
 	"github.com/halilylm/gommon/middlewares"
 	"github.com/halilylm/gommon/rest"
+	"github.com/halilylm/gommon/utils"
+	"github.com/halilylm/secondhand/orders/domain"
 	"github.com/halilylm/secondhand/orders/orders/usecase"
 	"github.com/labstack/echo/v4"
 	"net/http"
@@ -29,11 +31,22 @@ func (o *orderHandler) NewOrder(c echo.Context) error {
 	// id of wanted order
 	ticketID := c.Param("ticket_id")
 
+	// bind the request, the body is optional
+	var request domain.OrderRequest
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewBadRequestError(err.Error())))
+	}
+
+	// validate the struct
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewValidationErrors(err)))
+	}
+
 	// get user from the context
 	user := middlewares.UserFromContext(c)
 
 	// call the usecase
-	createdOrder, err := o.orderUC.NewOrder(c.Request().Context(), ticketID, user.ID)
+	createdOrder, err := o.orderUC.NewOrder(c.Request().Context(), ticketID, user.ID, request.Quantity)
 	if err != nil {
 		return c.JSON(rest.ErrorResponse(err))
 	}
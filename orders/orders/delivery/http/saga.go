@@ -0,0 +1,61 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/halilylm/gommon/middlewares"
+	"github.com/halilylm/gommon/rest"
+	"github.com/halilylm/secondhand/orders/orders/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// sagaListLimit caps the sagas listed at once
+const sagaListLimit = 100
+
+type sagaHandler struct {
+	orderUC usecase.Order
+}
+
+// NewSagaHandler shows buyers where their purchase is
+// and lets admins list the purchases in flight
+func NewSagaHandler(g *echo.Group, orderUC usecase.Order, admin echo.MiddlewareFunc) {
+	handler := &sagaHandler{orderUC: orderUC}
+
+	// jwt middleware
+	g.Use(middlewares.CurrentUser("jwt"))
+
+	g.GET("", handler.ListSagas, admin)
+	g.GET("/:id", handler.ShowSaga)
+}
+
+// ListSagas lists the active sagas, the ones past
+// their deadline are stuck until they are compensated
+func (s *sagaHandler) ListSagas(c echo.Context) error {
+	limit := sagaListLimit
+	if value := c.QueryParam("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > sagaListLimit {
+			return c.JSON(rest.ErrorResponse(rest.NewBadRequestError("limit must be between 1 and " + strconv.Itoa(sagaListLimit))))
+		}
+		limit = parsed
+	}
+
+	sagas, err := s.orderUC.ActiveSagas(c.Request().Context(), limit)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+	return c.JSON(http.StatusOK, sagas)
+}
+
+// ShowSaga shows the step of the purchase of the order
+func (s *sagaHandler) ShowSaga(c echo.Context) error {
+	// get user from the context
+	user := middlewares.UserFromContext(c)
+
+	foundSaga, err := s.orderUC.ShowSaga(c.Request().Context(), c.Param("id"), user.ID)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+	return c.JSON(http.StatusOK, foundSaga)
+}
@@ -0,0 +1,44 @@
+package natstream
+
+import (
+	"context"
+	"github.com/halilylm/secondhand/messaging/consumer"
+	"github.com/halilylm/secondhand/messaging/inbox"
+	"github.com/halilylm/secondhand/orders/domain"
+	"github.com/halilylm/secondhand/orders/orders/usecase"
+)
+
+type AuctionConsumerGroup struct {
+	orderUC usecase.Order
+	inbox   inbox.Store
+	groupID string
+}
+
+func NewAuctionConsumerGroup(
+	orderUC usecase.Order,
+	inbox inbox.Store,
+	groupID string,
+) *AuctionConsumerGroup {
+	return &AuctionConsumerGroup{
+		orderUC: orderUC,
+		inbox:   inbox,
+		groupID: groupID,
+	}
+}
+
+func (acg *AuctionConsumerGroup) GroupID() string {
+	return acg.groupID
+}
+
+func (acg *AuctionConsumerGroup) Subscriptions() []consumer.Subscription {
+	return []consumer.Subscription{
+		consumer.On(domain.AuctionWon, acg.wonAuction),
+	}
+}
+
+func (acg *AuctionConsumerGroup) wonAuction(ctx context.Context, eventID string, deliveredEvent *domain.AuctionWonEvent) error {
+	return acg.inbox.Process(ctx, acg.groupID, eventID, func(ctx context.Context) error {
+		_, err := acg.orderUC.NewAuctionOrder(ctx, deliveredEvent)
+		return err
+	})
+}
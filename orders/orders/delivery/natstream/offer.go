@@ -0,0 +1,44 @@
+package natstream
+
+import (
+	"context"
+	"github.com/halilylm/secondhand/messaging/consumer"
+	"github.com/halilylm/secondhand/messaging/inbox"
+	"github.com/halilylm/secondhand/orders/domain"
+	"github.com/halilylm/secondhand/orders/orders/usecase"
+)
+
+type OfferConsumerGroup struct {
+	orderUC usecase.Order
+	inbox   inbox.Store
+	groupID string
+}
+
+func NewOfferConsumerGroup(
+	orderUC usecase.Order,
+	inbox inbox.Store,
+	groupID string,
+) *OfferConsumerGroup {
+	return &OfferConsumerGroup{
+		orderUC: orderUC,
+		inbox:   inbox,
+		groupID: groupID,
+	}
+}
+
+func (ocg *OfferConsumerGroup) GroupID() string {
+	return ocg.groupID
+}
+
+func (ocg *OfferConsumerGroup) Subscriptions() []consumer.Subscription {
+	return []consumer.Subscription{
+		consumer.On(domain.OfferAgreed, ocg.agreedOffer),
+	}
+}
+
+func (ocg *OfferConsumerGroup) agreedOffer(ctx context.Context, eventID string, deliveredEvent *domain.OfferAgreedEvent) error {
+	return ocg.inbox.Process(ctx, ocg.groupID, eventID, func(ctx context.Context) error {
+		_, err := ocg.orderUC.NewOfferOrder(ctx, deliveredEvent)
+		return err
+	})
+}
@@ -2,67 +2,52 @@ package natstream
 
 import (
 	"context"
-	"github.com/halilylm/gommon/events"
+	"errors"
 	"github.com/halilylm/gommon/events/common/messages"
-	"github.com/halilylm/gommon/events/common/types"
+	"github.com/halilylm/secondhand/messaging/consumer"
+	"github.com/halilylm/secondhand/messaging/inbox"
+	"github.com/halilylm/secondhand/orders/domain"
 	"github.com/halilylm/secondhand/orders/orders/usecase"
 	"log"
-	"sync"
-	"time"
 )
 
 type PaymentConsumerGroup struct {
-	stream  events.Streaming
 	orderUC usecase.Order
+	inbox   inbox.Store
 	groupID string
 }
 
 func NewPaymentConsumerGroup(
-	stream events.Streaming,
 	orderUC usecase.Order,
+	inbox inbox.Store,
 	groupID string,
 ) *PaymentConsumerGroup {
 	return &PaymentConsumerGroup{
-		stream:  stream,
 		orderUC: orderUC,
+		inbox:   inbox,
 		groupID: groupID,
 	}
 }
 
-func (pcg *PaymentConsumerGroup) consumeCreatedPayments(
-	workersNum int,
-	topic string,
-) {
-	wg := &sync.WaitGroup{}
-	for i := 0; i <= workersNum; i++ {
-		wg.Add(1)
-		go func(workerID int) {
-			log.Printf("%d started working\n", workerID)
-			deliveredEvents, err := pcg.stream.Consume(topic, pcg.groupID, true, time.Minute)
-			if err != nil {
-				log.Fatal(err)
-			}
-			for event := range deliveredEvents {
-				var deliveredEvent messages.PaymentCreatedEvent
-				if err := event.Unmarshal(&deliveredEvent); err != nil {
-					log.Println(err)
-					continue
-				}
-				log.Println(deliveredEvent.OrderID)
-				updatedOrder, err := pcg.orderUC.UpdateStatus(context.TODO(), deliveredEvent.OrderID, types.Complete)
-				if err != nil {
-					log.Println(err)
-					continue
-				}
-				log.Println(updatedOrder.Status)
-				if err := event.Ack(); err != nil {
-					log.Println(err)
-				}
-			}
-		}(i)
+func (pcg *PaymentConsumerGroup) GroupID() string {
+	return pcg.groupID
+}
+
+func (pcg *PaymentConsumerGroup) Subscriptions() []consumer.Subscription {
+	return []consumer.Subscription{
+		consumer.On(messages.PaymentCreated, pcg.createdPayment),
 	}
 }
 
-func (pcg *PaymentConsumerGroup) RunConsumers() {
-	go pcg.consumeCreatedPayments(10, messages.PaymentCreated)
+func (pcg *PaymentConsumerGroup) createdPayment(ctx context.Context, eventID string, deliveredEvent *messages.PaymentCreatedEvent) error {
+	log.Println(deliveredEvent.OrderID)
+	err := pcg.inbox.Process(ctx, pcg.groupID, eventID, func(ctx context.Context) error {
+		return pcg.orderUC.OrderPaid(ctx, deliveredEvent)
+	})
+	// payments before the reservation is decided wait for it
+	// without being dead lettered, the saga times out otherwise
+	if errors.Is(err, domain.ErrNotReserved) {
+		return consumer.Later(err)
+	}
+	return err
 }
@@ -0,0 +1,59 @@
+package natstream
+
+import (
+	"context"
+	"github.com/halilylm/secondhand/messaging/consumer"
+	"github.com/halilylm/secondhand/messaging/inbox"
+	"github.com/halilylm/secondhand/orders/domain"
+	"github.com/halilylm/secondhand/orders/orders/usecase"
+)
+
+// SagaConsumerGroup moves the sagas of the purchases
+// on with the replies of products and payments
+type SagaConsumerGroup struct {
+	orderUC usecase.Order
+	inbox   inbox.Store
+	groupID string
+}
+
+func NewSagaConsumerGroup(
+	orderUC usecase.Order,
+	inbox inbox.Store,
+	groupID string,
+) *SagaConsumerGroup {
+	return &SagaConsumerGroup{
+		orderUC: orderUC,
+		inbox:   inbox,
+		groupID: groupID,
+	}
+}
+
+func (scg *SagaConsumerGroup) GroupID() string {
+	return scg.groupID
+}
+
+func (scg *SagaConsumerGroup) Subscriptions() []consumer.Subscription {
+	return []consumer.Subscription{
+		consumer.On(domain.OrderReserved, scg.reservedOrder),
+		consumer.On(domain.OrderRejected, scg.rejectedOrder),
+		consumer.On(domain.PaymentRefunded, scg.refundedPayment),
+	}
+}
+
+func (scg *SagaConsumerGroup) reservedOrder(ctx context.Context, eventID string, deliveredEvent *domain.OrderReservedEvent) error {
+	return scg.inbox.Process(ctx, scg.groupID, eventID, func(ctx context.Context) error {
+		return scg.orderUC.OrderReserved(ctx, deliveredEvent.ID)
+	})
+}
+
+func (scg *SagaConsumerGroup) rejectedOrder(ctx context.Context, eventID string, deliveredEvent *domain.OrderRejectedEvent) error {
+	return scg.inbox.Process(ctx, scg.groupID, eventID, func(ctx context.Context) error {
+		return scg.orderUC.OrderRejected(ctx, deliveredEvent.ID, deliveredEvent.Reason)
+	})
+}
+
+func (scg *SagaConsumerGroup) refundedPayment(ctx context.Context, eventID string, deliveredEvent *domain.PaymentRefundedEvent) error {
+	return scg.inbox.Process(ctx, scg.groupID, eventID, func(ctx context.Context) error {
+		return scg.orderUC.OrderRefunded(ctx, deliveredEvent)
+	})
+}
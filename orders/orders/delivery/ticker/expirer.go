@@ -0,0 +1,39 @@
+package ticker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/halilylm/secondhand/orders/orders/usecase"
+)
+
+// Expirer compensates timed out sagas periodically
+type Expirer struct {
+	orderUC  usecase.Order
+	interval time.Duration
+}
+
+// NewExpirer returns an expirer checking sagas every interval
+func NewExpirer(orderUC usecase.Order, interval time.Duration) *Expirer {
+	return &Expirer{
+		orderUC:  orderUC,
+		interval: interval,
+	}
+}
+
+// Run expires due sagas until the context is done
+func (e *Expirer) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.orderUC.ExpireDueSagas(ctx); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
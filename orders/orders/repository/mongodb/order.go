@@ -19,6 +19,24 @@ func NewOrderRepository(collection *mongo.Collection) domain.OrderRepository {
 	return &orderRepository{collection: collection}
 }
 
+// CreateOrderIndexes creates the indexes keeping one
+// order per agreed offer and per winning bid
+func CreateOrderIndexes(ctx context.Context, collection *mongo.Collection) error {
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "offer_id", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"offer_id": bson.M{"$exists": true}}),
+		},
+		{
+			Keys: bson.D{{Key: "bid_id", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"bid_id": bson.M{"$exists": true}}),
+		},
+	})
+	return err
+}
+
 func (o *orderRepository) Insert(ctx context.Context, order *domain.Order) (*domain.Order, error) {
 	order.ID = uuid.NewString()
 	_, err := o.collection.InsertOne(ctx, order)
@@ -28,11 +46,6 @@ func (o *orderRepository) Insert(ctx context.Context, order *domain.Order) (*dom
 	return order, nil
 }
 
-func (o *orderRepository) IsReserved(ctx context.Context, ticketID string) bool {
-	count, _ := o.collection.CountDocuments(ctx, bson.M{"ticket_id": ticketID, "status": bson.M{"$in": []types.OrderStatus{types.Created, types.AwaitingPayment, types.Complete}}})
-	return count > 0
-}
-
 // FindByID finds a order by its id
 func (o *orderRepository) FindByID(ctx context.Context, id string) (*domain.Order, error) {
 	var foundOrder domain.Order
@@ -46,6 +59,32 @@ func (o *orderRepository) FindByID(ctx context.Context, id string) (*domain.Orde
 	return &foundOrder, nil
 }
 
+// FindByBidID finds the order of the winning bid
+func (o *orderRepository) FindByBidID(ctx context.Context, bidID string) (*domain.Order, error) {
+	var foundOrder domain.Order
+	res := o.collection.FindOne(ctx, bson.M{"bid_id": bidID})
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&foundOrder); err != nil {
+		return nil, err
+	}
+	return &foundOrder, nil
+}
+
+// FindByOfferID finds the order of the agreed offer
+func (o *orderRepository) FindByOfferID(ctx context.Context, offerID string) (*domain.Order, error) {
+	var foundOrder domain.Order
+	res := o.collection.FindOne(ctx, bson.M{"offer_id": offerID})
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&foundOrder); err != nil {
+		return nil, err
+	}
+	return &foundOrder, nil
+}
+
 func (o *orderRepository) Delete(ctx context.Context, id string) error {
 	res, err := o.collection.DeleteOne(ctx, bson.M{"_id": id})
 	if err != nil {
@@ -93,3 +132,23 @@ func (o *orderRepository) UpdateStatus(ctx context.Context, id string, status ty
 	}
 	return &updatedOrder, nil
 }
+
+// Cancel marks the order cancelled as its next version,
+// cancelled and completed orders are left as they are
+func (o *orderRepository) Cancel(ctx context.Context, id string) (*domain.Order, error) {
+	var cancelledOrder domain.Order
+	res := o.collection.FindOneAndUpdate(ctx, bson.M{
+		"_id":    id,
+		"status": bson.M{"$nin": []types.OrderStatus{types.Cancelled, types.Complete}},
+	}, bson.M{
+		"$set": bson.M{"status": types.Cancelled},
+		"$inc": bson.M{"version": 1},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&cancelledOrder); err != nil {
+		return nil, err
+	}
+	return &cancelledOrder, nil
+}
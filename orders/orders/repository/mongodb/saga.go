@@ -0,0 +1,107 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/halilylm/secondhand/orders/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type sagaRepository struct {
+	collection *mongo.Collection
+}
+
+// NewSagaRepository returns a new mongo saga repository
+func NewSagaRepository(collection *mongo.Collection) domain.SagaRepository {
+	return &sagaRepository{collection: collection}
+}
+
+// CreateSagaIndexes creates the index the timeouts rely on
+func CreateSagaIndexes(ctx context.Context, collection *mongo.Collection) error {
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "step", Value: 1}, {Key: "deadline", Value: 1}},
+	})
+	return err
+}
+
+// Insert starts the saga of an order
+func (s *sagaRepository) Insert(ctx context.Context, saga *domain.Saga) (*domain.Saga, error) {
+	if _, err := s.collection.InsertOne(ctx, saga); err != nil {
+		return nil, err
+	}
+	return saga, nil
+}
+
+// FindByID finds the saga of the order
+func (s *sagaRepository) FindByID(ctx context.Context, id string) (*domain.Saga, error) {
+	var foundSaga domain.Saga
+	res := s.collection.FindOne(ctx, bson.M{"_id": id})
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&foundSaga); err != nil {
+		return nil, err
+	}
+	return &foundSaga, nil
+}
+
+// Update saves the step of the saga if nothing moved it meanwhile
+func (s *sagaRepository) Update(ctx context.Context, saga *domain.Saga) (*domain.Saga, error) {
+	var updatedSaga domain.Saga
+	res := s.collection.FindOneAndUpdate(ctx, bson.M{
+		"_id":     saga.ID,
+		"version": saga.Version,
+	}, bson.M{"$set": map[string]any{
+		"step":          saga.Step,
+		"order_version": saga.OrderVersion,
+		"payment_id":    saga.PaymentID,
+		"stripe_id":     saga.StripeID,
+		"reason":        saga.Reason,
+		"deadline":      saga.Deadline,
+		"history":       saga.History,
+		"updated_at":    saga.UpdatedAt,
+		"version":       saga.Version + 1,
+	}}, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&updatedSaga); err != nil {
+		return nil, err
+	}
+	return &updatedSaga, nil
+}
+
+// FindDue finds the active sagas whose step timed out
+func (s *sagaRepository) FindDue(ctx context.Context, now time.Time, limit int) ([]*domain.Saga, error) {
+	return s.find(ctx, bson.M{
+		"step":     bson.M{"$in": domain.ActiveSagaSteps},
+		"deadline": bson.M{"$lte": now},
+	}, limit)
+}
+
+// ListActive lists the sagas still waiting for an event,
+// the ones closest to their deadline first
+func (s *sagaRepository) ListActive(ctx context.Context, limit int) ([]*domain.Saga, error) {
+	return s.find(ctx, bson.M{
+		"step": bson.M{"$in": domain.ActiveSagaSteps},
+	}, limit)
+}
+
+func (s *sagaRepository) find(ctx context.Context, filter bson.M, limit int) ([]*domain.Saga, error) {
+	sagas := make([]*domain.Saga, 0)
+	opts := options.Find().
+		SetSort(bson.D{{Key: "deadline", Value: 1}}).
+		SetLimit(int64(limit))
+	cur, err := s.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &sagas); err != nil {
+		return nil, err
+	}
+	return sagas, nil
+}
@@ -0,0 +1,256 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/halilylm/gommon/events/common/messages"
+	"github.com/halilylm/gommon/events/common/types"
+	"github.com/halilylm/gommon/rest"
+	"github.com/halilylm/secondhand/messaging/outbox"
+	"github.com/halilylm/secondhand/orders/domain"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// dueSagasLimit caps the sagas compensated at once
+const dueSagasLimit = 100
+
+// startSaga tracks the purchase of the placed order
+func (o *order) startSaga(ctx context.Context, order *domain.Order) error {
+	if _, err := o.sagaRepo.Insert(ctx, domain.NewSaga(order, time.Now().UTC())); err != nil {
+		o.logger.Error(err)
+		return rest.NewInternalServerError()
+	}
+	return nil
+}
+
+// OrderReserved moves the saga on to the payment, orders
+// cancelled before products reserved them are released again
+func (o *order) OrderReserved(ctx context.Context, id string) error {
+	return o.outbox.Transaction(ctx, func(ctx context.Context) error {
+		saga, err := o.findSaga(ctx, id)
+		if err != nil || saga == nil {
+			return err
+		}
+		switch saga.Step {
+		case domain.SagaReserving:
+			saga.MoveTo(domain.SagaAwaitingPayment, "products reserved", time.Now().UTC())
+			return o.updateSaga(ctx, saga)
+		case domain.SagaCancelled, domain.SagaRefunding, domain.SagaRefunded:
+			// products may have handled the cancellation first
+			return o.publishCancelled(ctx, saga.ID, "", saga.Items, saga.OrderVersion)
+		}
+		return nil
+	})
+}
+
+// OrderRejected cancels the order products could not reserve
+func (o *order) OrderRejected(ctx context.Context, id, reason string) error {
+	return o.outbox.Transaction(ctx, func(ctx context.Context) error {
+		saga, err := o.findSaga(ctx, id)
+		if err != nil || saga == nil {
+			return err
+		}
+		if saga.Step != domain.SagaReserving {
+			return nil
+		}
+		return o.compensate(ctx, saga, "rejected by products: "+reason)
+	})
+}
+
+// OrderPaid completes the order awaiting the payment, orders
+// paid after they were cancelled are refunded
+func (o *order) OrderPaid(ctx context.Context, payment *messages.PaymentCreatedEvent) error {
+	return o.outbox.Transaction(ctx, func(ctx context.Context) error {
+		saga, err := o.findSaga(ctx, payment.OrderID)
+		if err != nil {
+			return err
+		}
+		if saga == nil {
+			// orders placed before sagas are completed right away
+			_, err := o.UpdateStatus(ctx, payment.OrderID, types.Complete)
+			return err
+		}
+
+		now := time.Now().UTC()
+		switch saga.Step {
+		case domain.SagaReserving:
+			// delivered again until products decide the reservation
+			// or the saga times out, cancelled orders are refunded
+			return domain.ErrNotReserved
+		case domain.SagaAwaitingPayment:
+			if _, err := o.UpdateStatus(ctx, saga.ID, types.Complete); err != nil {
+				return err
+			}
+			saga.PaymentID = payment.ID
+			saga.StripeID = payment.StripeID
+			saga.MoveTo(domain.SagaCompleted, "paid", now)
+			return o.updateSaga(ctx, saga)
+		case domain.SagaCancelled:
+			saga.PaymentID = payment.ID
+			saga.StripeID = payment.StripeID
+			saga.MoveTo(domain.SagaRefunding, "paid after the order was cancelled", now)
+			if err := o.requestRefund(ctx, saga); err != nil {
+				return err
+			}
+			return o.updateSaga(ctx, saga)
+		}
+		return nil
+	})
+}
+
+// OrderRefunded finishes the saga of the refunded order
+func (o *order) OrderRefunded(ctx context.Context, refund *domain.PaymentRefundedEvent) error {
+	return o.outbox.Transaction(ctx, func(ctx context.Context) error {
+		saga, err := o.findSaga(ctx, refund.OrderID)
+		if err != nil || saga == nil {
+			return err
+		}
+		if saga.Step != domain.SagaRefunding {
+			return nil
+		}
+		saga.MoveTo(domain.SagaRefunded, "refunded as "+refund.RefundID, time.Now().UTC())
+		return o.updateSaga(ctx, saga)
+	})
+}
+
+// ExpireDueSagas compensates the sagas whose step timed out,
+// refunds which are not confirmed in time are requested again
+func (o *order) ExpireDueSagas(ctx context.Context) error {
+	sagas, err := o.sagaRepo.FindDue(ctx, time.Now().UTC(), dueSagasLimit)
+	if err != nil {
+		o.logger.Error(err)
+		return rest.NewInternalServerError()
+	}
+	for _, saga := range sagas {
+		// a saga failing to expire is tried again next time
+		if err := o.expireSaga(ctx, saga); err != nil {
+			o.logger.Error(err)
+		}
+	}
+	return nil
+}
+
+func (o *order) expireSaga(ctx context.Context, saga *domain.Saga) error {
+	return o.outbox.Transaction(ctx, func(ctx context.Context) error {
+		switch saga.Step {
+		case domain.SagaReserving, domain.SagaAwaitingPayment:
+			return o.compensate(ctx, saga, "timed out while "+string(saga.Step))
+		case domain.SagaRefunding:
+			saga.MoveTo(domain.SagaRefunding, "refund requested again", time.Now().UTC())
+			if err := o.requestRefund(ctx, saga); err != nil {
+				return err
+			}
+			return o.updateSaga(ctx, saga)
+		}
+		return nil
+	})
+}
+
+// compensate cancels the order of the saga, the stock
+// held here and by products is given back
+func (o *order) compensate(ctx context.Context, saga *domain.Saga, reason string) error {
+	cancelledOrder, err := o.orderRepo.Cancel(ctx, saga.ID)
+	if err != nil && err != mongo.ErrNoDocuments {
+		o.logger.Error(err)
+		return rest.NewInternalServerError()
+	}
+
+	// deleted orders gave their stock back already
+	if cancelledOrder != nil {
+		for _, item := range saga.Items {
+			if err := o.release(ctx, item.ProductID, item.Quantity); err != nil {
+				return err
+			}
+		}
+		saga.OrderVersion = cancelledOrder.Version
+		if err := o.publishCancelled(ctx, cancelledOrder.ID, cancelledOrder.ProductID, saga.Items, cancelledOrder.Version); err != nil {
+			return err
+		}
+	}
+	saga.MoveTo(domain.SagaCancelled, reason, time.Now().UTC())
+	return o.updateSaga(ctx, saga)
+}
+
+// cancelSaga cancels the saga of the order the buyer deleted,
+// sagas which are paid or over are left as they are
+func (o *order) cancelSaga(ctx context.Context, id string, version int) error {
+	saga, err := o.findSaga(ctx, id)
+	if err != nil || saga == nil {
+		return err
+	}
+	if saga.Step != domain.SagaReserving && saga.Step != domain.SagaAwaitingPayment {
+		return nil
+	}
+	saga.OrderVersion = version
+	saga.MoveTo(domain.SagaCancelled, "cancelled by the buyer", time.Now().UTC())
+	return o.updateSaga(ctx, saga)
+}
+
+// requestRefund asks payments to give the charge of the saga back
+func (o *order) requestRefund(ctx context.Context, saga *domain.Saga) error {
+	msg := domain.RefundRequestedEvent{
+		OrderID:   saga.ID,
+		PaymentID: saga.PaymentID,
+		StripeID:  saga.StripeID,
+		Reason:    saga.Reason,
+	}
+	if err := o.outbox.Add(ctx, domain.RefundRequested, outbox.ForAggregate(msg.OrderID, msg)); err != nil {
+		o.logger.Error(err)
+		return rest.NewInternalServerError()
+	}
+	return nil
+}
+
+// findSaga finds the saga of the order,
+// orders placed before sagas have none
+func (o *order) findSaga(ctx context.Context, id string) (*domain.Saga, error) {
+	saga, err := o.sagaRepo.FindByID(ctx, id)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		o.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	return saga, nil
+}
+
+// updateSaga saves the saga, sagas another event moved
+// meanwhile are conflicts to be handled again
+func (o *order) updateSaga(ctx context.Context, saga *domain.Saga) error {
+	if _, err := o.sagaRepo.Update(ctx, saga); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return domain.ErrSagaConflict
+		}
+		o.logger.Error(err)
+		return rest.NewInternalServerError()
+	}
+	return nil
+}
+
+// ShowSaga shows the buyer where the purchase is
+func (o *order) ShowSaga(ctx context.Context, id, userID string) (*domain.Saga, error) {
+	saga, err := o.findSaga(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if saga == nil {
+		return nil, rest.NewNotFoundError()
+	}
+	if saga.UserID != userID {
+		return nil, rest.NewUnauthorizedError()
+	}
+	return saga, nil
+}
+
+// ActiveSagas lists the purchases still waiting for
+// an event, the ones closest to timing out first
+func (o *order) ActiveSagas(ctx context.Context, limit int) ([]*domain.Saga, error) {
+	sagas, err := o.sagaRepo.ListActive(ctx, limit)
+	if err != nil {
+		o.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	return sagas, nil
+}
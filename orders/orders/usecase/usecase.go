@@ -2,72 +2,319 @@ package usecase
 
 import (
 	"context"
-	"encoding/json"
-	"github.com/halilylm/gommon/events"
 	"github.com/halilylm/gommon/events/common/messages"
 	"github.com/halilylm/gommon/events/common/types"
 	"github.com/halilylm/gommon/logger"
 	"github.com/halilylm/gommon/rest"
+	"github.com/halilylm/secondhand/messaging/outbox"
 	"github.com/halilylm/secondhand/orders/domain"
 	"go.mongodb.org/mongo-driver/mongo"
-	"log"
 )
 
 type order struct {
 	productRepo domain.ProductRepository
 	orderRepo   domain.OrderRepository
+	cartRepo    domain.CartRepository
+	sagaRepo    domain.SagaRepository
 	logger      logger.Logger
-	stream      events.Streaming
+	outbox      outbox.Store
 }
 
-func NewOrder(productRepo domain.ProductRepository, orderRepo domain.OrderRepository, logger logger.Logger, stream events.Streaming) Order {
-	return &order{productRepo: productRepo, orderRepo: orderRepo, logger: logger, stream: stream}
+func NewOrder(productRepo domain.ProductRepository, orderRepo domain.OrderRepository, cartRepo domain.CartRepository, sagaRepo domain.SagaRepository, logger logger.Logger, outbox outbox.Store) Order {
+	return &order{productRepo: productRepo, orderRepo: orderRepo, cartRepo: cartRepo, sagaRepo: sagaRepo, logger: logger, outbox: outbox}
 }
 
-func (o *order) NewOrder(ctx context.Context, productID, userID string) (*domain.Order, error) {
+func (o *order) NewOrder(ctx context.Context, productID, userID string, quantity int) (*domain.Order, error) {
+	// a single item unless told otherwise
+	if quantity == 0 {
+		quantity = 1
+	}
+	if quantity < 0 {
+		return nil, rest.NewBadRequestError("quantity must be positive")
+	}
+
 	// find the ticket
-	product, err := o.productRepo.FindByID(ctx, productID)
+	product, err := o.findProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	// auctions are sold to the winning bidder only
+	if product.Listing == domain.ListingAuction {
+		return nil, rest.NewBadRequestError(domain.ErrAuctionProduct.Error())
+	}
+
+	createdOrder, err := o.placeOrder(ctx, &domain.Order{
+		UserID:   userID,
+		Quantity: quantity,
+		Charge:   product.Price * quantity,
+	}, product)
+	if err == domain.ErrOutOfStock {
+		return nil, rest.NewBadRequestError(err.Error())
+	}
+	return createdOrder, err
+}
+
+// NewAuctionOrder creates the order of the winning bidder at the
+// winning bid, redelivered auctions get the existing order
+func (o *order) NewAuctionOrder(ctx context.Context, auction *domain.AuctionWonEvent) (*domain.Order, error) {
+	// find the order of a previous delivery
+	foundOrder, err := o.orderRepo.FindByBidID(ctx, auction.BidID)
+	if err == nil {
+		return foundOrder, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		o.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+
+	// find the ticket
+	product, err := o.findProduct(ctx, auction.ProductID)
+	if err != nil {
+		return nil, err
+	}
+
+	createdOrder, err := o.placeOrder(ctx, &domain.Order{
+		UserID:   auction.UserID,
+		Quantity: 1,
+		Charge:   auction.Amount,
+		BidID:    auction.BidID,
+	}, product)
+	if err == domain.ErrOutOfStock {
+		return nil, rest.NewBadRequestError(err.Error())
+	}
+	return createdOrder, err
+}
+
+// NewOfferOrder creates the order of the buyer at the agreed
+// price, redelivered offers get the existing order. Offers of
+// products gone or out of stock are declined and get no order
+func (o *order) NewOfferOrder(ctx context.Context, offer *domain.OfferAgreedEvent) (*domain.Order, error) {
+	// find the order of a previous delivery
+	foundOrder, err := o.orderRepo.FindByOfferID(ctx, offer.OfferID)
+	if err == nil {
+		return foundOrder, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		o.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+
+	// find the ticket, products not replicated
+	// yet are retried by the consumer
+	product, err := o.productRepo.FindByID(ctx, offer.ProductID)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return nil, rest.NewNotFoundError()
 		}
 		return nil, rest.NewInternalServerError()
 	}
+	if product.Deleted || product.Hidden {
+		return nil, o.declineOffer(ctx, offer, domain.ErrProductUnavailable)
+	}
+
+	createdOrder, err := o.placeOrder(ctx, &domain.Order{
+		UserID:   offer.UserID,
+		Quantity: offer.Quantity,
+		Charge:   offer.Amount * offer.Quantity,
+		OfferID:  offer.OfferID,
+	}, product)
+	if err == domain.ErrOutOfStock {
+		return nil, o.declineOffer(ctx, offer, err)
+	}
+	return createdOrder, err
+}
 
-	// check if this ticket already is reserved
-	if isReserved := o.orderRepo.IsReserved(ctx, product.ID); isReserved {
-		return nil, rest.NewBadRequestError(rest.ErrTicketAlreadyReserved.Error())
+// declineOffer tells products the agreed offer has no order
+func (o *order) declineOffer(ctx context.Context, offer *domain.OfferAgreedEvent, reason error) error {
+	return o.outbox.Transaction(ctx, func(ctx context.Context) error {
+		msg := domain.OfferDeclinedEvent{
+			OfferID: offer.OfferID,
+			Version: offer.Version,
+			Reason:  reason.Error(),
+		}
+		if err := o.outbox.Add(ctx, domain.OfferDeclined, outbox.ForAggregate(msg.OfferID, msg)); err != nil {
+			o.logger.Error(err)
+			return rest.NewInternalServerError()
+		}
+		return nil
+	})
+}
+
+// findProduct finds the replica of the product,
+// deleted and moderated products cannot be ordered
+func (o *order) findProduct(ctx context.Context, productID string) (*domain.Product, error) {
+	product, err := o.productRepo.FindByID(ctx, productID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, rest.NewNotFoundError()
+		}
+		return nil, rest.NewInternalServerError()
 	}
+	if product.Deleted || product.Hidden {
+		return nil, rest.NewNotFoundError()
+	}
+	return product, nil
+}
 
+// placeOrder reserves the quantity of the order and tells
+// other services the order is created with its charge,
+// ErrOutOfStock is returned when the stock cannot cover it
+func (o *order) placeOrder(ctx context.Context, order *domain.Order, product *domain.Product) (*domain.Order, error) {
 	// generate the order
-	order := &domain.Order{
-		UserID:    userID,
-		Status:    types.Created,
-		ProductID: productID,
-		Version:   0,
+	order.Status = types.Created
+	order.ProductID = product.ID
+	order.Product = domain.ProductSnapshot{
+		Title:   product.Title,
+		Price:   product.Price,
+		Version: product.Version,
 	}
+	order.Version = 0
+
+	// the reservation, the order, its event and its
+	// saga are written together or not at all
+	var createdOrder *domain.Order
+	err := o.outbox.Transaction(ctx, func(ctx context.Context) error {
+		// reserve against the remaining stock
+		if _, err := o.productRepo.Reserve(ctx, product.ID, order.Quantity); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return domain.ErrOutOfStock
+			}
+			o.logger.Error(err)
+			return rest.NewInternalServerError()
+		}
 
-	createdOrder, err := o.orderRepo.Insert(ctx, order)
+		var err error
+		createdOrder, err = o.orderRepo.Insert(ctx, order)
+		if err != nil {
+			o.logger.Error(err)
+			return rest.NewInternalServerError()
+		}
+		if err := o.publishCreated(ctx, createdOrder); err != nil {
+			return err
+		}
+		return o.startSaga(ctx, createdOrder)
+	})
 	if err != nil {
+		return nil, err
+	}
+	return createdOrder, nil
+}
+
+// Checkout orders every product in the cart of the user at once,
+// either all products are reserved or none of them
+func (o *order) Checkout(ctx context.Context, userID string) (*domain.Order, error) {
+	// find the cart
+	cart, err := o.cartRepo.FindByUser(ctx, userID)
+	if err != nil && err != mongo.ErrNoDocuments {
+		o.logger.Error(err)
 		return nil, rest.NewInternalServerError()
 	}
-	msg := messages.OrderCreatedEvent{
-		ID:        createdOrder.ID,
-		Version:   createdOrder.Version,
-		Status:    createdOrder.Status,
-		UserID:    createdOrder.UserID,
-		ProductID: productID,
-		Charge:    product.Price,
+	if cart == nil || len(cart.Items) == 0 {
+		return nil, rest.NewBadRequestError(domain.ErrEmptyCart.Error())
 	}
-	encodedMsg, err := json.Marshal(msg)
+
+	// reserve the products in a single transaction,
+	// none of them is reserved if any of them fails
+	var createdOrder *domain.Order
+	err = o.outbox.Transaction(ctx, func(ctx context.Context) error {
+		items := make([]*domain.LineItem, 0, len(cart.Items))
+		charge := 0
+		for _, cartItem := range cart.Items {
+			item, err := o.reserveCartItem(ctx, cartItem)
+			if err != nil {
+				return err
+			}
+			items = append(items, item)
+			charge += item.Charge
+		}
+
+		// generate the order
+		var err error
+		createdOrder, err = o.orderRepo.Insert(ctx, &domain.Order{
+			UserID:      userID,
+			Status:      types.Created,
+			Charge:      charge,
+			Items:       items,
+			Fulfilments: domain.NewFulfilments(items),
+		})
+		if err != nil {
+			o.logger.Error(err)
+			return rest.NewInternalServerError()
+		}
+		if err := o.publishCreated(ctx, createdOrder); err != nil {
+			return err
+		}
+		return o.startSaga(ctx, createdOrder)
+	})
 	if err != nil {
+		return nil, err
+	}
+
+	// the order is placed, a cart left behind
+	// only shows the products again
+	if err := o.cartRepo.Delete(ctx, userID); err != nil {
 		o.logger.Error(err)
 	}
-	if err := o.stream.Publish(messages.OrderCreated, encodedMsg); err != nil {
+	return createdOrder, nil
+}
+
+// reserveCartItem reserves the product of the cart item
+// and returns its line item at the current price
+func (o *order) reserveCartItem(ctx context.Context, cartItem *domain.CartItem) (*domain.LineItem, error) {
+	product, err := o.productRepo.FindByID(ctx, cartItem.ProductID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, rest.NewBadRequestError(domain.ErrProductUnavailable.Error() + ": " + cartItem.ProductID)
+		}
+		o.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	if err := product.Orderable(); err != nil {
+		return nil, rest.NewBadRequestError(err.Error() + ": " + product.ID)
+	}
+	if _, err := o.productRepo.Reserve(ctx, product.ID, cartItem.Quantity); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, rest.NewBadRequestError(domain.ErrOutOfStock.Error() + ": " + product.ID)
+		}
+		o.logger.Error(err)
 		return nil, rest.NewInternalServerError()
 	}
+	return &domain.LineItem{
+		ProductID: product.ID,
+		SellerID:  product.SellerID,
+		Quantity:  cartItem.Quantity,
+		Charge:    product.Price * cartItem.Quantity,
+		Product: domain.ProductSnapshot{
+			Title:   product.Title,
+			Price:   product.Price,
+			Version: product.Version,
+		},
+	}, nil
+}
 
-	return createdOrder, nil
+// publishCreated tells other services the order is created,
+// products reserve and payments charge its items
+func (o *order) publishCreated(ctx context.Context, order *domain.Order) error {
+	msg := domain.OrderCreatedEvent{
+		OrderCreatedEvent: messages.OrderCreatedEvent{
+			ID:        order.ID,
+			Version:   order.Version,
+			Status:    order.Status,
+			UserID:    order.UserID,
+			ProductID: order.ProductID,
+			Charge:    order.Charge,
+		},
+		Quantity: order.Quantity,
+	}
+	if len(order.Items) > 0 {
+		msg.Items = order.OrderItems()
+	}
+	if err := o.outbox.Add(ctx, messages.OrderCreated, msg); err != nil {
+		o.logger.Error(err)
+		return rest.NewInternalServerError()
+	}
+	return nil
 }
 
 func (o *order) ShowOrder(ctx context.Context, id, userID string) (*domain.Order, error) {
@@ -100,20 +347,54 @@ func (o *order) DeleteOrder(ctx context.Context, id, userID string) error {
 	if err := o.havePermission(ctx, id, userID); err != nil {
 		return err
 	}
-	if err := o.orderRepo.Delete(ctx, id); err != nil {
-		if err == mongo.ErrNoDocuments {
-			return rest.NewNotFoundError()
+	// paid orders are sold, their stock is never given back
+	if foundOrder.Status == types.Complete {
+		return rest.NewBadRequestError(domain.ErrOrderComplete.Error())
+	}
+	// orders cancelled by their saga gave the stock back already
+	cancelled := foundOrder.Status == types.Cancelled
+	err = o.outbox.Transaction(ctx, func(ctx context.Context) error {
+		if err := o.orderRepo.Delete(ctx, id); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return rest.NewNotFoundError()
+			}
+			return rest.NewInternalServerError()
 		}
-		return rest.NewInternalServerError()
+		if cancelled {
+			return nil
+		}
+		for _, item := range foundOrder.OrderItems() {
+			if err := o.release(ctx, item.ProductID, item.Quantity); err != nil {
+				return err
+			}
+		}
+		// the cancellation is the next version of the order
+		version := foundOrder.Version + 1
+		if err := o.publishCancelled(ctx, foundOrder.ID, foundOrder.ProductID, foundOrder.OrderItems(), version); err != nil {
+			return err
+		}
+		return o.cancelSaga(ctx, foundOrder.ID, version)
+	})
+	if err == domain.ErrSagaConflict {
+		return rest.NewBadRequestError(err.Error())
 	}
-	msg := messages.OrderCancelledEvent{
-		ID:        foundOrder.ID,
-		Version:   foundOrder.Version,
-		ProductID: foundOrder.ProductID,
+	return err
+}
+
+// publishCancelled tells other services the order is cancelled
+// as the given version, products give the stock of the items back
+func (o *order) publishCancelled(ctx context.Context, id, productID string, items []domain.OrderItem, version int) error {
+	msg := domain.OrderCancelledEvent{
+		OrderCancelledEvent: messages.OrderCancelledEvent{
+			ID:        id,
+			Version:   version,
+			ProductID: productID,
+		},
+		Items: items,
 	}
-	b, _ := json.Marshal(msg)
-	if err := o.stream.Publish(messages.OrderCancelled, b); err != nil {
-		log.Println(err)
+	if err := o.outbox.Add(ctx, messages.OrderCancelled, msg); err != nil {
+		o.logger.Error(err)
+		return rest.NewInternalServerError()
 	}
 	return nil
 }
@@ -130,14 +411,75 @@ func (o *order) ListUserOrders(ctx context.Context, userID string) ([]*domain.Or
 }
 
 func (o *order) UpdateStatus(ctx context.Context, id string, status types.OrderStatus) (*domain.Order, error) {
-	updatedOrder, err := o.orderRepo.UpdateStatus(ctx, id, status)
+	var updatedOrder *domain.Order
+	err := o.outbox.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		updatedOrder, err = o.orderRepo.UpdateStatus(ctx, id, status)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return rest.NewNotFoundError()
+			}
+			return rest.NewInternalServerError()
+		}
+
+		// completed orders can be reviewed
+		if status == types.Complete {
+			return o.publishCompleted(ctx, updatedOrder)
+		}
+		return nil
+	})
 	if err != nil {
+		return nil, err
+	}
+	return updatedOrder, nil
+}
+
+// publishCompleted tells other services the order is complete,
+// cart orders are completed for each seller with their first product
+func (o *order) publishCompleted(ctx context.Context, order *domain.Order) error {
+	msgs := []domain.OrderCompletedEvent{{
+		ID:        order.ID,
+		Version:   order.Version,
+		UserID:    order.UserID,
+		ProductID: order.ProductID,
+	}}
+	if order.ProductID == "" {
+		msgs = msgs[:0]
+		for _, fulfilment := range order.Fulfilments {
+			msgs = append(msgs, domain.OrderCompletedEvent{
+				ID:        order.ID,
+				Version:   order.Version,
+				UserID:    order.UserID,
+				ProductID: fulfilment.ProductIDs[0],
+				SellerID:  fulfilment.SellerID,
+			})
+		}
+	}
+	for _, msg := range msgs {
+		if err := o.outbox.Add(ctx, domain.OrderCompleted, msg); err != nil {
+			o.logger.Error(err)
+			return rest.NewInternalServerError()
+		}
+	}
+	return nil
+}
+
+// release gives the stock of a cancelled order back,
+// orders placed before quantities held a single item.
+// Products gone or holding less than the quantity have
+// nothing to give back, other failures abort the caller
+func (o *order) release(ctx context.Context, productID string, quantity int) error {
+	if quantity == 0 {
+		quantity = 1
+	}
+	if _, err := o.productRepo.Release(ctx, productID, quantity); err != nil {
+		o.logger.Error(err)
 		if err == mongo.ErrNoDocuments {
-			return nil, rest.NewNotFoundError()
+			return nil
 		}
-		return nil, rest.NewInternalServerError()
+		return rest.NewInternalServerError()
 	}
-	return updatedOrder, nil
+	return nil
 }
 
 // havePermission check if user is authorized
@@ -157,9 +499,19 @@ func (o *order) havePermission(ctx context.Context, orderID, userID string) erro
 }
 
 type Order interface {
-	NewOrder(ctx context.Context, productID, userID string) (*domain.Order, error)
+	NewOrder(ctx context.Context, productID, userID string, quantity int) (*domain.Order, error)
+	NewAuctionOrder(ctx context.Context, auction *domain.AuctionWonEvent) (*domain.Order, error)
+	NewOfferOrder(ctx context.Context, offer *domain.OfferAgreedEvent) (*domain.Order, error)
+	Checkout(ctx context.Context, userID string) (*domain.Order, error)
 	ShowOrder(ctx context.Context, id, userID string) (*domain.Order, error)
 	DeleteOrder(ctx context.Context, id, userID string) error
 	ListUserOrders(ctx context.Context, userID string) ([]*domain.Order, error)
 	UpdateStatus(ctx context.Context, id string, status types.OrderStatus) (*domain.Order, error)
+	OrderReserved(ctx context.Context, id string) error
+	OrderRejected(ctx context.Context, id, reason string) error
+	OrderPaid(ctx context.Context, payment *messages.PaymentCreatedEvent) error
+	OrderRefunded(ctx context.Context, refund *domain.PaymentRefundedEvent) error
+	ExpireDueSagas(ctx context.Context) error
+	ShowSaga(ctx context.Context, id, userID string) (*domain.Saga, error)
+	ActiveSagas(ctx context.Context, limit int) ([]*domain.Saga, error)
 }
@@ -3,111 +3,95 @@ package natsream
 import (
 	"context"
 	"log"
-	"sync"
-	"time"
 
-	"github.com/halilylm/gommon/events"
 	"github.com/halilylm/gommon/events/common/messages"
+	"github.com/halilylm/secondhand/messaging/consumer"
+	"github.com/halilylm/secondhand/messaging/inbox"
+	"github.com/halilylm/secondhand/messaging/projection"
 	"github.com/halilylm/secondhand/orders/domain"
 	"github.com/halilylm/secondhand/orders/product/usecase"
 )
 
 type ProductConsumerGroup struct {
-	stream    events.Streaming
 	productUC usecase.Product
+	inbox     inbox.Store
+	projector *projection.Projector
 	groupID   string
 }
 
 func NewProductConsumerGroup(
-	stream events.Streaming,
 	productUC usecase.Product,
+	inbox inbox.Store,
+	projector *projection.Projector,
 	groupID string,
 ) *ProductConsumerGroup {
 	return &ProductConsumerGroup{
-		stream:    stream,
 		productUC: productUC,
+		inbox:     inbox,
+		projector: projector,
 		groupID:   groupID,
 	}
 }
 
-func (tcg *ProductConsumerGroup) consumeCreatedProducts(
-	workersNum int,
-	topic string,
-) {
-	wg := &sync.WaitGroup{}
-	for i := 0; i <= workersNum; i++ {
-		wg.Add(1)
-		go func(workerID int) {
-			log.Printf("%d started working\n", workerID)
-			deliveredEvents, err := tcg.stream.Consume(topic, tcg.groupID, true, time.Minute)
-			if err != nil {
-				log.Fatal(err)
-			}
-			for event := range deliveredEvents {
-				var deliveredEvent messages.ProductCreatedEvent
-				if err := event.Unmarshal(&deliveredEvent); err != nil {
-					log.Println(err)
-					continue
-				}
-				product := domain.Product{
-					ID:      deliveredEvent.ID,
-					Title:   deliveredEvent.Title,
-					Price:   deliveredEvent.Price,
-					Version: deliveredEvent.Version,
-				}
-				createdProduct, err := tcg.productUC.CreateProduct(context.TODO(), &product)
-				if err != nil {
-					log.Println(err)
-					continue
-				}
-				log.Println(createdProduct.ID)
-				if err := event.Ack(); err != nil {
-					log.Println(err)
-				}
-			}
-		}(i)
+func (tcg *ProductConsumerGroup) GroupID() string {
+	return tcg.groupID
+}
+
+func (tcg *ProductConsumerGroup) Subscriptions() []consumer.Subscription {
+	return []consumer.Subscription{
+		consumer.On(messages.ProductCreated, tcg.createdProduct),
+		consumer.On(messages.ProductUpdated, tcg.updatedProduct),
+		consumer.On(domain.ProductDeleted, tcg.deletedProduct),
 	}
 }
 
-func (tcg *ProductConsumerGroup) consumeUpdatedProducts(
-	workersNum int,
-	topic string,
-) {
-	wg := &sync.WaitGroup{}
-	for i := 0; i <= workersNum; i++ {
-		wg.Add(1)
-		go func(workerID int) {
-			log.Printf("%d started working\n", workerID)
-			deliveredEvents, err := tcg.stream.Consume(topic, tcg.groupID, true, time.Minute)
+func (tcg *ProductConsumerGroup) createdProduct(ctx context.Context, eventID string, deliveredEvent *domain.ProductCreatedEvent) error {
+	product := domain.Product{
+		ID:       deliveredEvent.ID,
+		Title:    deliveredEvent.Title,
+		Price:    deliveredEvent.Price,
+		Version:  deliveredEvent.Version,
+		Quantity: deliveredEvent.Quantity,
+		Listing:  deliveredEvent.Listing,
+		Hidden:   deliveredEvent.Hidden,
+		SellerID: deliveredEvent.UserID,
+	}
+	return tcg.projector.Handle(ctx, deliveredEvent.ID, deliveredEvent.Version, tcg.productUC.ProductVersion, func(ctx context.Context) error {
+		return tcg.inbox.Process(ctx, tcg.groupID, eventID, func(ctx context.Context) error {
+			createdProduct, err := tcg.productUC.CreateProduct(ctx, &product)
 			if err != nil {
-				log.Fatal(err)
-			}
-			for event := range deliveredEvents {
-				var deliveredEvent messages.ProductUpdatedEvent
-				if err := event.Unmarshal(&deliveredEvent); err != nil {
-					log.Println(err)
-					continue
-				}
-				product := domain.Product{
-					ID:      deliveredEvent.ID,
-					Title:   deliveredEvent.Title,
-					Price:   deliveredEvent.Price,
-					Version: deliveredEvent.Version,
-				}
-				_, err := tcg.productUC.UpdateProduct(context.TODO(), &product)
-				if err != nil {
-					log.Println(err)
-					continue
-				}
-				if err := event.Ack(); err != nil {
-					log.Println(err)
-				}
+				return err
 			}
-		}(i)
+			log.Println(createdProduct.ID)
+			return nil
+		})
+	})
+}
+
+func (tcg *ProductConsumerGroup) updatedProduct(ctx context.Context, eventID string, deliveredEvent *domain.ProductUpdatedEvent) error {
+	product := domain.Product{
+		ID:       deliveredEvent.ID,
+		Title:    deliveredEvent.Title,
+		Price:    deliveredEvent.Price,
+		Version:  deliveredEvent.Version,
+		Quantity: deliveredEvent.Quantity,
+		Listing:  deliveredEvent.Listing,
+		Hidden:   deliveredEvent.Hidden,
+		SellerID: deliveredEvent.UserID,
 	}
+	return tcg.projector.Handle(ctx, deliveredEvent.ID, deliveredEvent.Version, tcg.productUC.ProductVersion, func(ctx context.Context) error {
+		return tcg.inbox.Process(ctx, tcg.groupID, eventID, func(ctx context.Context) error {
+			_, err := tcg.productUC.UpdateProduct(ctx, &product)
+			return err
+		})
+	})
 }
 
-func (tcg *ProductConsumerGroup) RunConsumers() {
-	go tcg.consumeCreatedProducts(10, messages.ProductCreated)
-	go tcg.consumeUpdatedProducts(10, messages.ProductUpdated)
+func (tcg *ProductConsumerGroup) deletedProduct(ctx context.Context, eventID string, deliveredEvent *domain.ProductDeletedEvent) error {
+	return tcg.projector.Handle(ctx, deliveredEvent.ID, deliveredEvent.Version, tcg.productUC.ProductVersion, func(ctx context.Context) error {
+		return tcg.inbox.Process(ctx, tcg.groupID, eventID, func(ctx context.Context) error {
+			_, err := tcg.productUC.DeleteProduct(ctx, deliveredEvent.ID, deliveredEvent.Version)
+			return err
+		})
+	})
 }
@@ -0,0 +1,68 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/halilylm/gommon/events/common/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MigrateProducts backfills the stock of products replicated by
+// earlier versions, the orders not cancelled back then hold one
+// item each. It is safe to run on every start
+func MigrateProducts(ctx context.Context, collection, orders *mongo.Collection) error {
+	// products were single items before they had stock
+	if _, err := collection.UpdateMany(ctx, bson.M{
+		"quantity": bson.M{"$exists": false},
+	}, bson.M{"$set": bson.M{"quantity": 1}}); err != nil {
+		return err
+	}
+	if _, err := collection.UpdateMany(ctx, bson.M{
+		"deleted": bson.M{"$exists": false},
+	}, bson.M{"$set": bson.M{"deleted": false}}); err != nil {
+		return err
+	}
+
+	// the stock held by the orders of the products without any
+	legacy, err := collection.CountDocuments(ctx, bson.M{"reserved": bson.M{"$exists": false}})
+	if err != nil || legacy == 0 {
+		return err
+	}
+	cur, err := orders.Aggregate(ctx, bson.A{
+		bson.M{"$match": bson.M{
+			"product_id": bson.M{"$nin": bson.A{nil, ""}},
+			"status":     bson.M{"$ne": types.Cancelled},
+		}},
+		bson.M{"$group": bson.M{
+			"_id":      "$product_id",
+			"reserved": bson.M{"$sum": bson.M{"$ifNull": bson.A{"$quantity", 1}}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var held struct {
+			ProductID string `bson:"_id"`
+			Reserved  int    `bson:"reserved"`
+		}
+		if err := cur.Decode(&held); err != nil {
+			return err
+		}
+		if _, err := collection.UpdateOne(ctx, bson.M{
+			"_id":      held.ProductID,
+			"reserved": bson.M{"$exists": false},
+		}, bson.M{"$set": bson.M{"reserved": held.Reserved}}); err != nil {
+			return err
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return err
+	}
+	_, err = collection.UpdateMany(ctx, bson.M{
+		"reserved": bson.M{"$exists": false},
+	}, bson.M{"$set": bson.M{"reserved": 0}})
+	return err
+}
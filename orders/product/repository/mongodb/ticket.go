@@ -6,6 +6,7 @@ import (
 	"github.com/halilylm/secondhand/orders/domain"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type productRepository struct {
@@ -30,6 +31,27 @@ func (p *productRepository) FindByID(ctx context.Context, id string) (*domain.Pr
 	return &foundProduct, nil
 }
 
+// FindByIDs finds the tickets with the ids, missing ones are skipped
+func (p *productRepository) FindByIDs(ctx context.Context, ids []string) ([]*domain.Product, error) {
+	products := make([]*domain.Product, 0, len(ids))
+	cur, err := p.collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var product domain.Product
+		if err := cur.Decode(&product); err != nil {
+			return nil, err
+		}
+		products = append(products, &product)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
 // Insert creates a new ticket in mongodb
 func (p *productRepository) Insert(ctx context.Context, ticket *domain.Product) (*domain.Product, error) {
 	_, err := p.collection.InsertOne(ctx, ticket)
@@ -53,9 +75,13 @@ func (p *productRepository) Update(ctx context.Context, ticket *domain.Product)
 		"version": ticket.Version - 1,
 		"_id":     ticket.ID,
 	}, bson.M{"$set": map[string]any{
-		"title":   ticket.Title,
-		"version": ticket.Version,
-		"price":   ticket.Price,
+		"title":     ticket.Title,
+		"version":   ticket.Version,
+		"price":     ticket.Price,
+		"quantity":  ticket.Quantity,
+		"listing":   ticket.Listing,
+		"hidden":    ticket.Hidden,
+		"seller_id": ticket.SellerID,
 	}})
 	if res.Err() != nil {
 		return nil, res.Err()
@@ -65,3 +91,65 @@ func (p *productRepository) Update(ctx context.Context, ticket *domain.Product)
 	}
 	return &updatedTicket, nil
 }
+
+// MarkDeleted marks the ticket as deleted so it cannot be ordered
+func (p *productRepository) MarkDeleted(ctx context.Context, id string, version int) (*domain.Product, error) {
+	var deletedTicket domain.Product
+
+	// optimistic concurrency control same as update
+	res := p.collection.FindOneAndUpdate(ctx, bson.M{
+		"version": version - 1,
+		"_id":     id,
+	}, bson.M{"$set": map[string]any{
+		"version": version,
+		"deleted": true,
+	}})
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&deletedTicket); err != nil {
+		return nil, err
+	}
+	return &deletedTicket, nil
+}
+
+// Reserve takes the quantity from the remaining stock
+// in one step so concurrent orders cannot oversell
+func (p *productRepository) Reserve(ctx context.Context, id string, quantity int) (*domain.Product, error) {
+	var reservedTicket domain.Product
+	res := p.collection.FindOneAndUpdate(ctx, bson.M{
+		"_id":     id,
+		"deleted": false,
+		"$expr": bson.M{"$gte": bson.A{
+			bson.M{"$subtract": bson.A{"$quantity", "$reserved"}},
+			quantity,
+		}},
+	}, bson.M{"$inc": bson.M{
+		"reserved": quantity,
+	}}, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&reservedTicket); err != nil {
+		return nil, err
+	}
+	return &reservedTicket, nil
+}
+
+// Release gives the quantity back to the remaining stock
+func (p *productRepository) Release(ctx context.Context, id string, quantity int) (*domain.Product, error) {
+	var releasedTicket domain.Product
+	res := p.collection.FindOneAndUpdate(ctx, bson.M{
+		"_id":      id,
+		"reserved": bson.M{"$gte": quantity},
+	}, bson.M{"$inc": bson.M{
+		"reserved": -quantity,
+	}}, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&releasedTicket); err != nil {
+		return nil, err
+	}
+	return &releasedTicket, nil
+}
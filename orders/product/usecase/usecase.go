@@ -4,7 +4,9 @@ import (
 	"context"
 
 	"github.com/halilylm/gommon/logger"
+	"github.com/halilylm/secondhand/messaging/projection"
 	"github.com/halilylm/secondhand/orders/domain"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type product struct {
@@ -19,12 +21,15 @@ func NewProduct(ticketRepo domain.ProductRepository, logger logger.Logger) Produ
 type Product interface {
 	CreateProduct(ctx context.Context, ticket *domain.Product) (*domain.Product, error)
 	UpdateProduct(ctx context.Context, ticket *domain.Product) (*domain.Product, error)
+	DeleteProduct(ctx context.Context, id string, version int) (*domain.Product, error)
+	ProductVersion(ctx context.Context, id string) (int, error)
 }
 
 func (p *product) CreateProduct(ctx context.Context, ticket *domain.Product) (*domain.Product, error) {
 	createdTicket, err := p.productRepo.Insert(ctx, ticket)
 	if err != nil {
 		p.logger.Error(err)
+		return nil, err
 	}
 	return createdTicket, nil
 }
@@ -33,6 +38,30 @@ func (p *product) UpdateProduct(ctx context.Context, ticket *domain.Product) (*d
 	updatedTicket, err := p.productRepo.Update(ctx, ticket)
 	if err != nil {
 		p.logger.Error(err)
+		return nil, err
 	}
 	return updatedTicket, nil
 }
+
+func (p *product) DeleteProduct(ctx context.Context, id string, version int) (*domain.Product, error) {
+	deletedTicket, err := p.productRepo.MarkDeleted(ctx, id, version)
+	if err != nil {
+		p.logger.Error(err)
+		return nil, err
+	}
+	return deletedTicket, nil
+}
+
+// ProductVersion returns the version of the replica,
+// products not replicated yet have none
+func (p *product) ProductVersion(ctx context.Context, id string) (int, error) {
+	foundTicket, err := p.productRepo.FindByID(ctx, id)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return projection.NoVersion, nil
+		}
+		p.logger.Error(err)
+		return 0, err
+	}
+	return foundTicket.Version, nil
+}
@@ -3,15 +3,23 @@ package main
 import (
 	"context"
 	"errors"
+	"expvar"
 	"github.com/halilylm/gommon/db"
-	"github.com/halilylm/gommon/events/nats"
 	"github.com/halilylm/gommon/logger/sugared"
 	"github.com/halilylm/gommon/rest"
 	"github.com/halilylm/gommon/utils"
+	"github.com/halilylm/secondhand/messaging/bus"
+	"github.com/halilylm/secondhand/messaging/consumer"
+	"github.com/halilylm/secondhand/messaging/deadletter"
+	"github.com/halilylm/secondhand/messaging/inbox"
+	"github.com/halilylm/secondhand/messaging/outbox"
+	"github.com/halilylm/secondhand/messaging/projection"
+	"github.com/halilylm/secondhand/messaging/roles"
 	"github.com/halilylm/secondhand/payments/order/delivery/natstream"
 	"github.com/halilylm/secondhand/payments/order/repository/mongodb"
 	"github.com/halilylm/secondhand/payments/order/usecase"
 	http2 "github.com/halilylm/secondhand/payments/payment/delivery/http"
+	natstream2 "github.com/halilylm/secondhand/payments/payment/delivery/natstream"
 	mongodb2 "github.com/halilylm/secondhand/payments/payment/repository/mongodb"
 	usecase2 "github.com/halilylm/secondhand/payments/payment/usecase"
 	"github.com/joho/godotenv"
@@ -38,7 +46,7 @@ func main() {
 	appLogger.Init()
 
 	// env variables checkpoint
-	utils.RequireEnvVariables("MONGO_URI", "APP_PORT", "JWT_KEY", "NATS_URI", "NATS_CLUSTER_ID", "NATS_CLIENT_ID")
+	utils.RequireEnvVariables("MONGO_URI", "APP_PORT", "JWT_KEY")
 
 	// connect to mongodb
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -48,13 +56,19 @@ func main() {
 		appLogger.Fatal(err)
 	}
 
-	// connect to nats
-	streaming, err := nats.New(nats.Options{
-		nil,
-		appLogger,
-		[]string{os.Getenv("NATS_URI")},
-		os.Getenv("NATS_CLUSTER_ID"),
-		os.Getenv("NATS_CLIENT_ID"),
+	// connect to the event bus
+	// nats streaming unless another bus is configured,
+	// two joined with "+" while migrating between them,
+	// memory when every service runs in this process
+	streaming, err := bus.New(bus.Config{
+		Bus:           os.Getenv("EVENT_BUS"),
+		NATSURL:       os.Getenv("NATS_URI"),
+		ClusterID:     os.Getenv("NATS_CLUSTER_ID"),
+		ClientID:      os.Getenv("NATS_CLIENT_ID"),
+		Stream:        os.Getenv("JETSTREAM_STREAM"),
+		KafkaBrokers:  os.Getenv("KAFKA_BROKERS"),
+		SingleProcess: os.Getenv("SINGLE_PROCESS") == "true",
+		Logger:        appLogger,
 	})
 	if err != nil {
 		appLogger.Fatal(err)
@@ -63,14 +77,39 @@ func main() {
 	// init collections
 	orderCollection := client.Database("payments").Collection("orders")
 	paymentCollection := client.Database("payments").Collection("payments")
+	outboxCollection := client.Database("payments").Collection("outbox")
+	inboxCollection := client.Database("payments").Collection("inbox")
+	deadLetterCollection := client.Database("payments").Collection("dead_letter")
+
+	// create indexes
+	if err := outbox.CreateIndexes(ctx, outboxCollection); err != nil {
+		appLogger.Fatal(err)
+	}
+	if err := inbox.CreateIndexes(ctx, inboxCollection); err != nil {
+		appLogger.Fatal(err)
+	}
+	if err := deadletter.CreateIndexes(ctx, deadLetterCollection); err != nil {
+		appLogger.Fatal(err)
+	}
 
 	// init repositories
 	orderRepo := mongodb.NewOrderRepository(orderCollection)
 	paymentRepo := mongodb2.NewPaymentRepository(paymentCollection)
+	outboxStore := outbox.NewStore(outboxCollection, appLogger)
+	inboxStore := inbox.NewStore(inboxCollection, outboxStore)
+	deadLetterStore := deadletter.NewStore(deadLetterCollection)
+
+	// init the consumer processor
+	// failing events are retried then dead lettered
+	processor := consumer.NewProcessor(streaming, deadLetterStore, appLogger, consumer.DefaultPolicy)
+
+	// init projections
+	// early events wait at most 10 seconds for their predecessor
+	projector := projection.NewProjector("payments_order", 10*time.Second)
 
 	// init usecases
 	orderUC := usecase.NewOrder(orderRepo)
-	paymentUC := usecase2.NewPayment(paymentRepo, orderRepo, streaming)
+	paymentUC := usecase2.NewPayment(paymentRepo, orderRepo, outboxStore)
 
 	// set routes
 	e := echo.New()
@@ -83,10 +122,31 @@ func main() {
 		return c.JSON(rest.ErrorResponse(rest.NewNotFoundError()))
 	}
 
+	// projection metrics are served on the internal
+	// listener only, never on the public port
+	metrics := echo.New()
+	metrics.GET("/debug/vars", echo.WrapHandler(expvar.Handler()))
+
 	http2.NewPaymentHandler(v1, paymentUC)
+	appRoles := roles.New()
+	appRoles.Grant(roles.Admin, os.Getenv("ADMIN_USER_IDS"))
+	deadletter.NewAdminHandler(v1.Group("/dead-letters"), deadLetterStore, streaming, appLogger, appRoles.Require(roles.Admin))
+
+	// init consumer groups
+	// each group runs 10 workers per subject
+	runners := consumer.Runners{
+		consumer.NewRunner(streaming, processor, natstream.NewOrderConsumerGroup(orderUC, inboxStore, projector, "payments_order"), 10),
+		consumer.NewRunner(streaming, processor, natstream2.NewRefundConsumerGroup(paymentUC, inboxStore, "payments_refund"), 10),
+	}
+	if err := runners.Start(); err != nil {
+		appLogger.Fatal(err)
+	}
+	e.GET("/health", runners.HealthHandler())
 
-	orderConsumerGroup := natstream.NewOrderConsumerGroup(streaming, orderUC, "payments_order")
-	orderConsumerGroup.RunConsumers()
+	// publish the outbox until shutdown
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+	go outbox.NewRelay(outboxCollection, streaming, appLogger, time.Second).Run(relayCtx)
 
 	// start the application
 	go func() {
@@ -94,17 +154,36 @@ func main() {
 			appLogger.Fatal(err)
 		}
 	}()
+	if addr := os.Getenv("METRICS_ADDR"); addr != "" {
+		go func() {
+			if err := metrics.Start(addr); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				appLogger.Fatal(err)
+			}
+		}()
+	}
 
 	// block until program interrupted
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt)
 	<-quit
 
+	// stop publishing the outbox
+	stopRelay()
+
 	// graceful shutdown
 	// don't wait more than 30 seconds
 	// to gracefully shut down the server
 	ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
+	if err := runners.Shutdown(ctx); err != nil {
+		appLogger.Error(err)
+	}
+	if err := streaming.Close(); err != nil {
+		appLogger.Error(err)
+	}
+	if err := metrics.Shutdown(ctx); err != nil {
+		appLogger.Error(err)
+	}
 	if err := e.Shutdown(ctx); err != nil {
 		appLogger.Fatal(err)
 	}
@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"github.com/halilylm/gommon/events/common/messages"
 	"github.com/halilylm/gommon/events/common/types"
 )
 
@@ -11,6 +12,23 @@ type Order struct {
 	UserID  string            `json:"user_id" bson:"user_id"`
 	Charge  int               `json:"charge" bson:"charge"`
 	Status  types.OrderStatus `json:"status" bson:"status"`
+	// Items are the line items of orders checked
+	// out from the cart, the charge covers them all
+	Items []OrderItem `json:"items,omitempty" bson:"items,omitempty"`
+}
+
+// OrderItem is a product of an order with its share of the charge
+type OrderItem struct {
+	ProductID string `json:"product_id" bson:"product_id"`
+	Quantity  int    `json:"quantity" bson:"quantity"`
+	Charge    int    `json:"charge" bson:"charge"`
+}
+
+// OrderCreatedEvent is the shared event
+// extended with the line items of cart orders
+type OrderCreatedEvent struct {
+	messages.OrderCreatedEvent
+	Items []OrderItem `json:"items,omitempty"`
 }
 type OrderRepository interface {
 	Insert(ctx context.Context, order *Order) (*Order, error)
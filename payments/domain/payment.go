@@ -2,14 +2,44 @@ package domain
 
 import (
 	"context"
+	"errors"
 )
 
+// ErrOrderCancelled returned when paying an order which is cancelled
+var ErrOrderCancelled = errors.New("order is cancelled")
+
 type Payment struct {
 	ID       string `json:"id" bson:"_id,omitempty"`
 	OrderID  string `json:"order_id" bson:"order_id"`
 	StripeID string `json:"stripe_id" bson:"stripe_id"`
+	// RefundID is set once the charge is given back
+	RefundID string `json:"refund_id,omitempty" bson:"refund_id,omitempty"`
+}
+
+// RefundRequested is published by orders when
+// an order is paid after it was cancelled
+const RefundRequested = "payment:refund-requested"
+
+// RefundRequestedEvent asks to give the charge back
+type RefundRequestedEvent struct {
+	OrderID   string `json:"order_id"`
+	PaymentID string `json:"payment_id"`
+	StripeID  string `json:"stripe_id"`
+	Reason    string `json:"reason"`
+}
+
+// PaymentRefunded is published when a charge is given back
+const PaymentRefunded = "payment:refunded"
+
+// PaymentRefundedEvent tells orders the order is refunded
+type PaymentRefundedEvent struct {
+	ID       string `json:"id"`
+	OrderID  string `json:"order_id"`
+	RefundID string `json:"refund_id"`
 }
 
 type PaymentRepository interface {
 	Insert(ctx context.Context, payment *Payment) (*Payment, error)
+	FindByID(ctx context.Context, id string) (*Payment, error)
+	MarkRefunded(ctx context.Context, id, refundID string) (*Payment, error)
 }
@@ -3,6 +3,7 @@ package usecase
 import (
 	"context"
 	"github.com/halilylm/gommon/rest"
+	"github.com/halilylm/secondhand/messaging/projection"
 	"github.com/halilylm/secondhand/payments/domain"
 	"go.mongodb.org/mongo-driver/mongo"
 )
@@ -19,6 +20,7 @@ type Order interface {
 	CreateOrder(ctx context.Context, order *domain.Order) (*domain.Order, error)
 	UpdateOrder(ctx context.Context, order *domain.Order) (*domain.Order, error)
 	FindOrder(ctx context.Context, id string, version int) (*domain.Order, error)
+	OrderVersion(ctx context.Context, id string) (int, error)
 }
 
 func (o *order) CreateOrder(ctx context.Context, order *domain.Order) (*domain.Order, error) {
@@ -53,3 +55,16 @@ func (o *order) FindOrder(ctx context.Context, id string, version int) (*domain.
 	}
 	return foundOrder, nil
 }
+
+// OrderVersion returns the version of the replica,
+// orders not replicated yet have none
+func (o *order) OrderVersion(ctx context.Context, id string) (int, error) {
+	foundOrder, err := o.orderRepo.FindByID(ctx, id)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return projection.NoVersion, nil
+		}
+		return 0, err
+	}
+	return foundOrder.Version, nil
+}
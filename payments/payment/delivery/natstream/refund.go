@@ -0,0 +1,44 @@
+package natstream
+
+import (
+	"context"
+	"github.com/halilylm/secondhand/messaging/consumer"
+	"github.com/halilylm/secondhand/messaging/inbox"
+	"github.com/halilylm/secondhand/payments/domain"
+	"github.com/halilylm/secondhand/payments/payment/usecase"
+)
+
+// RefundConsumerGroup refunds orders paid after they were cancelled
+type RefundConsumerGroup struct {
+	paymentUC usecase.Payment
+	inbox     inbox.Store
+	groupID   string
+}
+
+func NewRefundConsumerGroup(
+	paymentUC usecase.Payment,
+	inbox inbox.Store,
+	groupID string,
+) *RefundConsumerGroup {
+	return &RefundConsumerGroup{
+		paymentUC: paymentUC,
+		inbox:     inbox,
+		groupID:   groupID,
+	}
+}
+
+func (rcg *RefundConsumerGroup) GroupID() string {
+	return rcg.groupID
+}
+
+func (rcg *RefundConsumerGroup) Subscriptions() []consumer.Subscription {
+	return []consumer.Subscription{
+		consumer.On(domain.RefundRequested, rcg.requestedRefund),
+	}
+}
+
+func (rcg *RefundConsumerGroup) requestedRefund(ctx context.Context, eventID string, deliveredEvent *domain.RefundRequestedEvent) error {
+	return rcg.inbox.Process(ctx, rcg.groupID, eventID, func(ctx context.Context) error {
+		return rcg.paymentUC.Refund(ctx, deliveredEvent)
+	})
+}
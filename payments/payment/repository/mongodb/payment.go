@@ -4,7 +4,9 @@ import (
 	"context"
 	"github.com/google/uuid"
 	"github.com/halilylm/secondhand/payments/domain"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type paymentRepository struct {
@@ -22,3 +24,33 @@ func (p *paymentRepository) Insert(ctx context.Context, payment *domain.Payment)
 	}
 	return payment, nil
 }
+
+// FindByID finds a payment by its id
+func (p *paymentRepository) FindByID(ctx context.Context, id string) (*domain.Payment, error) {
+	var foundPayment domain.Payment
+	res := p.collection.FindOne(ctx, bson.M{"_id": id})
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&foundPayment); err != nil {
+		return nil, err
+	}
+	return &foundPayment, nil
+}
+
+// MarkRefunded records the refund of the payment
+func (p *paymentRepository) MarkRefunded(ctx context.Context, id, refundID string) (*domain.Payment, error) {
+	var refundedPayment domain.Payment
+	res := p.collection.FindOneAndUpdate(ctx, bson.M{
+		"_id": id,
+	}, bson.M{"$set": bson.M{
+		"refund_id": refundID,
+	}}, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&refundedPayment); err != nil {
+		return nil, err
+	}
+	return &refundedPayment, nil
+}
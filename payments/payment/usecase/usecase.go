@@ -2,28 +2,33 @@ package usecase
 
 import (
 	"context"
-	"encoding/json"
-	"github.com/halilylm/gommon/events"
 	"github.com/halilylm/gommon/events/common/messages"
+	"github.com/halilylm/gommon/events/common/types"
 	"github.com/halilylm/gommon/rest"
+	"github.com/halilylm/secondhand/messaging/outbox"
 	"github.com/halilylm/secondhand/payments/domain"
 	"github.com/stripe/stripe-go"
 	"github.com/stripe/stripe-go/charge"
+	"github.com/stripe/stripe-go/refund"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// stripeKey is the secret key of the stripe test account
+const stripeKey = "sk_test_51FHv9PKXyE07Xs5iFGatWlPOKCxIx6e6mXP5IezkIuFDUdaIyzW95hKDWGabXbzCgF9OHS9rfn93NFEVPkdFOOze00jIw79wFW"
+
 type payment struct {
 	paymentRepo domain.PaymentRepository
 	orderRepo   domain.OrderRepository
-	stream      events.Streaming
+	outbox      outbox.Store
 }
 
-func NewPayment(paymentRepo domain.PaymentRepository, orderRepo domain.OrderRepository, stream events.Streaming) Payment {
-	return &payment{paymentRepo: paymentRepo, orderRepo: orderRepo, stream: stream}
+func NewPayment(paymentRepo domain.PaymentRepository, orderRepo domain.OrderRepository, outbox outbox.Store) Payment {
+	return &payment{paymentRepo: paymentRepo, orderRepo: orderRepo, outbox: outbox}
 }
 
 type Payment interface {
 	Pay(ctx context.Context, orderID string) (*domain.Payment, error)
+	Refund(ctx context.Context, refundRequest *domain.RefundRequestedEvent) error
 }
 
 func (p *payment) Pay(ctx context.Context, orderID string) (*domain.Payment, error) {
@@ -34,7 +39,11 @@ func (p *payment) Pay(ctx context.Context, orderID string) (*domain.Payment, err
 		}
 		return nil, rest.NewInternalServerError()
 	}
-	stripe.Key = "sk_test_51FHv9PKXyE07Xs5iFGatWlPOKCxIx6e6mXP5IezkIuFDUdaIyzW95hKDWGabXbzCgF9OHS9rfn93NFEVPkdFOOze00jIw79wFW"
+	// cancelled orders gave their products back
+	if order.Status == types.Cancelled {
+		return nil, rest.NewBadRequestError(domain.ErrOrderCancelled.Error())
+	}
+	stripe.Key = stripeKey
 	charged, err := charge.New(&stripe.ChargeParams{
 		Amount:      stripe.Int64(int64(order.Charge * 100)),
 		Currency:    stripe.String(string(stripe.CurrencyEUR)),
@@ -45,22 +54,68 @@ func (p *payment) Pay(ctx context.Context, orderID string) (*domain.Payment, err
 		OrderID:  order.ID,
 		StripeID: charged.ID,
 	}
-	createdPayment, err := p.paymentRepo.Insert(ctx, paid)
+	var createdPayment *domain.Payment
+	err = p.outbox.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		createdPayment, err = p.paymentRepo.Insert(ctx, paid)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return rest.NewNotFoundError()
+			}
+			return rest.NewInternalServerError()
+		}
+		msg := messages.PaymentCreatedEvent{
+			ID:       createdPayment.ID,
+			OrderID:  createdPayment.OrderID,
+			StripeID: createdPayment.StripeID,
+		}
+		if err := p.outbox.Add(ctx, messages.PaymentCreated, outbox.ForAggregate(msg.OrderID, &msg)); err != nil {
+			return rest.NewInternalServerError()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return createdPayment, nil
+}
+
+// Refund gives the charge of the payment back, a refund
+// retried after it went through is not charged back twice
+func (p *payment) Refund(ctx context.Context, refundRequest *domain.RefundRequestedEvent) error {
+	foundPayment, err := p.paymentRepo.FindByID(ctx, refundRequest.PaymentID)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, rest.NewNotFoundError()
+			return rest.NewNotFoundError()
 		}
-		return nil, rest.NewInternalServerError()
-	}
-	msg := messages.PaymentCreatedEvent{
-		ID:       createdPayment.ID,
-		OrderID:  createdPayment.OrderID,
-		StripeID: createdPayment.StripeID,
+		return rest.NewInternalServerError()
 	}
-	encodedMsg, err := json.Marshal(&msg)
-	if err != nil {
-		return nil, rest.NewInternalServerError()
+
+	refundID := foundPayment.RefundID
+	if refundID == "" {
+		stripe.Key = stripeKey
+		params := &stripe.RefundParams{Charge: stripe.String(foundPayment.StripeID)}
+		params.SetIdempotencyKey("refund-" + foundPayment.ID)
+		refunded, err := refund.New(params)
+		if err != nil {
+			return err
+		}
+		refundID = refunded.ID
 	}
-	p.stream.Publish(messages.PaymentCreated, encodedMsg)
-	return createdPayment, nil
+
+	return p.outbox.Transaction(ctx, func(ctx context.Context) error {
+		refundedPayment, err := p.paymentRepo.MarkRefunded(ctx, foundPayment.ID, refundID)
+		if err != nil {
+			return rest.NewInternalServerError()
+		}
+		msg := domain.PaymentRefundedEvent{
+			ID:       refundedPayment.ID,
+			OrderID:  refundedPayment.OrderID,
+			RefundID: refundedPayment.RefundID,
+		}
+		if err := p.outbox.Add(ctx, domain.PaymentRefunded, outbox.ForAggregate(msg.OrderID, &msg)); err != nil {
+			return rest.NewInternalServerError()
+		}
+		return nil
+	})
 }
@@ -4,20 +4,54 @@ import (
 	"context"
 	"errors"
 	"github.com/halilylm/gommon/db"
-	"github.com/halilylm/gommon/events/nats"
 	"github.com/halilylm/gommon/logger/sugared"
 	"github.com/halilylm/gommon/rest"
 	"github.com/halilylm/gommon/utils"
+	"github.com/halilylm/secondhand/messaging/bus"
+	"github.com/halilylm/secondhand/messaging/consumer"
+	"github.com/halilylm/secondhand/messaging/deadletter"
+	"github.com/halilylm/secondhand/messaging/inbox"
+	"github.com/halilylm/secondhand/messaging/outbox"
+	"github.com/halilylm/secondhand/messaging/roles"
+	_auctionHandler "github.com/halilylm/secondhand/product/auction/delivery/http"
+	"github.com/halilylm/secondhand/product/auction/delivery/ticker"
+	_bidRepo "github.com/halilylm/secondhand/product/auction/repository/mongodb"
+	_auctionUC "github.com/halilylm/secondhand/product/auction/usecase"
+	"github.com/halilylm/secondhand/product/blob/local"
+	"github.com/halilylm/secondhand/product/blob/s3"
+	_categoryHandler "github.com/halilylm/secondhand/product/category/delivery/http"
+	_categoryRepo "github.com/halilylm/secondhand/product/category/repository/mongodb"
+	_categoryUC "github.com/halilylm/secondhand/product/category/usecase"
+	"github.com/halilylm/secondhand/product/domain"
+	_moderationHandler "github.com/halilylm/secondhand/product/moderation/delivery/http"
+	_reportRepo "github.com/halilylm/secondhand/product/moderation/repository/mongodb"
+	_moderationUC "github.com/halilylm/secondhand/product/moderation/usecase"
+	_notificationHandler "github.com/halilylm/secondhand/product/notification/delivery/http"
+	_notificationStream "github.com/halilylm/secondhand/product/notification/delivery/natstream"
+	_notificationRepo "github.com/halilylm/secondhand/product/notification/repository/mongodb"
+	_notificationUC "github.com/halilylm/secondhand/product/notification/usecase"
+	_offerHandler "github.com/halilylm/secondhand/product/offer/delivery/http"
+	_offerStream "github.com/halilylm/secondhand/product/offer/delivery/natstream"
+	_offerRepo "github.com/halilylm/secondhand/product/offer/repository/mongodb"
+	_offerUC "github.com/halilylm/secondhand/product/offer/usecase"
 	_productHandler "github.com/halilylm/secondhand/product/product/delivery/http"
 	"github.com/halilylm/secondhand/product/product/delivery/natstream"
 	"github.com/halilylm/secondhand/product/product/repository/mongodb"
 	"github.com/halilylm/secondhand/product/product/usecase"
+	_reviewHandler "github.com/halilylm/secondhand/product/review/delivery/http"
+	_reviewStream "github.com/halilylm/secondhand/product/review/delivery/natstream"
+	_reviewRepo "github.com/halilylm/secondhand/product/review/repository/mongodb"
+	_reviewUC "github.com/halilylm/secondhand/product/review/usecase"
+	_watchlistHandler "github.com/halilylm/secondhand/product/watchlist/delivery/http"
+	_watchlistRepo "github.com/halilylm/secondhand/product/watchlist/repository/mongodb"
+	_watchlistUC "github.com/halilylm/secondhand/product/watchlist/usecase"
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"time"
 )
 
@@ -36,7 +70,7 @@ func main() {
 	appLogger.Init()
 
 	// env variables checkpoint
-	utils.RequireEnvVariables("MONGO_URI", "APP_PORT", "JWT_KEY", "NATS_URI", "NATS_CLUSTER_ID", "NATS_CLIENT_ID")
+	utils.RequireEnvVariables("MONGO_URI", "APP_PORT", "JWT_KEY")
 
 	// connect to mongodb
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -46,13 +80,19 @@ func main() {
 		appLogger.Fatal(err)
 	}
 
-	// connect to nats
-	streaming, err := nats.New(nats.Options{
-		nil,
-		appLogger,
-		[]string{os.Getenv("NATS_URI")},
-		os.Getenv("NATS_CLUSTER_ID"),
-		os.Getenv("NATS_CLIENT_ID"),
+	// connect to the event bus
+	// nats streaming unless another bus is configured,
+	// two joined with "+" while migrating between them,
+	// memory when every service runs in this process
+	streaming, err := bus.New(bus.Config{
+		Bus:           os.Getenv("EVENT_BUS"),
+		NATSURL:       os.Getenv("NATS_URI"),
+		ClusterID:     os.Getenv("NATS_CLUSTER_ID"),
+		ClientID:      os.Getenv("NATS_CLIENT_ID"),
+		Stream:        os.Getenv("JETSTREAM_STREAM"),
+		KafkaBrokers:  os.Getenv("KAFKA_BROKERS"),
+		SingleProcess: os.Getenv("SINGLE_PROCESS") == "true",
+		Logger:        appLogger,
 	})
 	if err != nil {
 		appLogger.Fatal(err)
@@ -60,12 +100,125 @@ func main() {
 
 	// init collections
 	productCollection := client.Database("products").Collection("product")
+	categoryCollection := client.Database("products").Collection("category")
+	bidCollection := client.Database("products").Collection("bid")
+	notificationCollection := client.Database("products").Collection("notification")
+	offerCollection := client.Database("products").Collection("offer")
+	watchlistCollection := client.Database("products").Collection("watchlist")
+	orderCollection := client.Database("products").Collection("order")
+	reviewCollection := client.Database("products").Collection("review")
+	sellerCollection := client.Database("products").Collection("seller")
+	historyCollection := client.Database("products").Collection("history")
+	reportCollection := client.Database("products").Collection("report")
+	outboxCollection := client.Database("products").Collection("outbox")
+	inboxCollection := client.Database("products").Collection("inbox")
+	deadLetterCollection := client.Database("products").Collection("dead_letter")
+
+	// create indexes
+	if err := mongodb.CreateProductIndexes(ctx, productCollection); err != nil {
+		appLogger.Fatal(err)
+	}
+	if err := mongodb.CreateHistoryIndexes(ctx, historyCollection); err != nil {
+		appLogger.Fatal(err)
+	}
+	if err := _bidRepo.CreateBidIndexes(ctx, bidCollection); err != nil {
+		appLogger.Fatal(err)
+	}
+	if err := _notificationRepo.CreateNotificationIndexes(ctx, notificationCollection); err != nil {
+		appLogger.Fatal(err)
+	}
+	if err := _offerRepo.CreateOfferIndexes(ctx, offerCollection); err != nil {
+		appLogger.Fatal(err)
+	}
+	if err := _watchlistRepo.CreateWatchlistIndexes(ctx, watchlistCollection); err != nil {
+		appLogger.Fatal(err)
+	}
+	if err := _reviewRepo.CreateReviewIndexes(ctx, reviewCollection); err != nil {
+		appLogger.Fatal(err)
+	}
+	if err := _reportRepo.CreateReportIndexes(ctx, reportCollection); err != nil {
+		appLogger.Fatal(err)
+	}
+	if err := outbox.CreateIndexes(ctx, outboxCollection); err != nil {
+		appLogger.Fatal(err)
+	}
+	if err := inbox.CreateIndexes(ctx, inboxCollection); err != nil {
+		appLogger.Fatal(err)
+	}
+	if err := deadletter.CreateIndexes(ctx, deadLetterCollection); err != nil {
+		appLogger.Fatal(err)
+	}
+
+	// backfill products listed by earlier versions
+	if err := mongodb.MigrateProducts(context.Background(), productCollection); err != nil {
+		appLogger.Fatal(err)
+	}
 
 	// init repositories
 	productRepo := mongodb.NewProductRepository(productCollection)
+	historyRepo := mongodb.NewHistoryRepository(historyCollection)
+	categoryRepo := _categoryRepo.NewCategoryRepository(categoryCollection)
+	bidRepo := _bidRepo.NewBidRepository(bidCollection)
+	notificationRepo := _notificationRepo.NewNotificationRepository(notificationCollection)
+	offerRepo := _offerRepo.NewOfferRepository(offerCollection)
+	watchlistRepo := _watchlistRepo.NewWatchlistRepository(watchlistCollection)
+	orderRepo := _reviewRepo.NewOrderRepository(orderCollection)
+	reviewRepo := _reviewRepo.NewReviewRepository(reviewCollection)
+	sellerRepo := _reviewRepo.NewSellerRepository(sellerCollection)
+	reportRepo := _reportRepo.NewReportRepository(reportCollection)
+	outboxStore := outbox.NewStore(outboxCollection, appLogger)
+	inboxStore := inbox.NewStore(inboxCollection, outboxStore)
+	deadLetterStore := deadletter.NewStore(deadLetterCollection)
+
+	// init the consumer processor
+	// failing events are retried then dead lettered
+	processor := consumer.NewProcessor(streaming, deadLetterStore, appLogger, consumer.DefaultPolicy)
+
+	// init blob store
+	// images are kept on the local disk unless s3 is configured
+	var blobStore domain.BlobStore
+	if os.Getenv("BLOB_STORE") == "s3" {
+		blobStore = s3.NewStore(s3.Options{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		})
+	} else {
+		utils.RequireEnvVariables("BLOB_DIR")
+		blobStore, err = local.NewStore(os.Getenv("BLOB_DIR"))
+		if err != nil {
+			appLogger.Fatal(err)
+		}
+	}
+
+	// init moderation rules
+	// every listing is published right away unless configured
+	newSellerThreshold := 0
+	if threshold := os.Getenv("MODERATION_NEW_SELLER_LISTINGS"); threshold != "" {
+		newSellerThreshold, err = strconv.Atoi(threshold)
+		if err != nil {
+			appLogger.Fatal(err)
+		}
+	}
+	moderationRules := domain.NewModerationRules(os.Getenv("MODERATION_BLOCKLIST"), newSellerThreshold)
 
 	// init usecases
-	productUC := usecase.NewProduct(productRepo, appLogger, streaming)
+	productUC := usecase.NewProduct(productRepo, categoryRepo, sellerRepo, historyRepo, blobStore, moderationRules, appLogger, outboxStore)
+	categoryUC := _categoryUC.NewCategory(categoryRepo, productRepo, appLogger)
+	notificationUC := _notificationUC.NewNotification(notificationRepo, watchlistRepo, appLogger)
+	watchlistUC := _watchlistUC.NewWatchlist(watchlistRepo, productRepo, appLogger)
+	reviewUC := _reviewUC.NewReview(reviewRepo, orderRepo, sellerRepo, productRepo, appLogger, outboxStore)
+	auctionUC := _auctionUC.NewAuction(productRepo, bidRepo, notificationUC, appLogger, outboxStore)
+	offerUC := _offerUC.NewOffer(offerRepo, productRepo, notificationUC, appLogger, outboxStore)
+	moderationUC := _moderationUC.NewModeration(productRepo, reportRepo, historyRepo, notificationUC, appLogger, outboxStore)
+
+	// init roles
+	appRoles := roles.New()
+	appRoles.Grant(roles.Admin, os.Getenv("ADMIN_USER_IDS"))
+	appRoles.Grant(roles.Moderator, os.Getenv("MODERATOR_USER_IDS"))
+	appRoles.Grant(roles.Moderator, os.Getenv("ADMIN_USER_IDS"))
 
 	// set routes
 	e := echo.New()
@@ -79,10 +232,39 @@ func main() {
 	}
 
 	// init handlers
-	_productHandler.NewProductHandler(v1, productUC)
+	_productHandler.NewProductHandler(v1, productUC, appRoles)
+	_categoryHandler.NewCategoryHandler(v1.Group("/categories"), categoryUC, appRoles)
+	_auctionHandler.NewAuctionHandler(v1, auctionUC)
+	_offerHandler.NewOfferHandler(v1, offerUC)
+	_notificationHandler.NewNotificationHandler(v1.Group("/notifications"), notificationUC)
+	_watchlistHandler.NewWatchlistHandler(v1.Group("/watchlist"), watchlistUC)
+	_reviewHandler.NewReviewHandler(v1, reviewUC)
+	_moderationHandler.NewModerationHandler(v1, moderationUC, appRoles)
+	deadletter.NewAdminHandler(v1.Group("/dead-letters"), deadLetterStore, streaming, appLogger, appRoles.Require(roles.Admin))
 
-	orderConsumerGroup := natstream.NewOrderConsumerGroup(streaming, productUC, "ticket_order_consumer")
-	orderConsumerGroup.RunConsumers()
+	// init consumer groups
+	// each group runs 10 workers per subject
+	runners := consumer.Runners{
+		consumer.NewRunner(streaming, processor, natstream.NewOrderConsumerGroup(productUC, inboxStore, "ticket_order_consumer"), 10),
+		consumer.NewRunner(streaming, processor, natstream.NewPaymentConsumerGroup(productUC, inboxStore, "ticket_payment_consumer"), 10),
+		consumer.NewRunner(streaming, processor, _notificationStream.NewProductConsumerGroup(notificationUC, inboxStore, "ticket_notification_consumer"), 10),
+		consumer.NewRunner(streaming, processor, _reviewStream.NewOrderConsumerGroup(reviewUC, inboxStore, "ticket_review_consumer"), 10),
+		consumer.NewRunner(streaming, processor, _offerStream.NewOrderConsumerGroup(offerUC, inboxStore, "ticket_offer_consumer"), 10),
+	}
+	if err := runners.Start(); err != nil {
+		appLogger.Fatal(err)
+	}
+	e.GET("/health", runners.HealthHandler())
+
+	// publish the outbox until shutdown
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+	go outbox.NewRelay(outboxCollection, streaming, appLogger, time.Second).Run(relayCtx)
+
+	// close ended auctions until shutdown
+	closerCtx, stopCloser := context.WithCancel(context.Background())
+	defer stopCloser()
+	go ticker.NewCloser(auctionUC, 10*time.Second).Run(closerCtx)
 
 	// start the application
 	go func() {
@@ -96,11 +278,21 @@ func main() {
 	signal.Notify(quit, os.Interrupt)
 	<-quit
 
+	// stop closing auctions and publishing the outbox
+	stopCloser()
+	stopRelay()
+
 	// graceful shutdown
 	// don't wait more than 30 seconds
 	// to gracefully shut down the server
 	ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
+	if err := runners.Shutdown(ctx); err != nil {
+		appLogger.Error(err)
+	}
+	if err := streaming.Close(); err != nil {
+		appLogger.Error(err)
+	}
 	if err := e.Shutdown(ctx); err != nil {
 		appLogger.Fatal(err)
 	}
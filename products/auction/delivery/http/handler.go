@@ -0,0 +1,60 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/halilylm/gommon/middlewares"
+	"github.com/halilylm/gommon/rest"
+	"github.com/halilylm/gommon/utils"
+	"github.com/halilylm/secondhand/product/auction/usecase"
+	"github.com/halilylm/secondhand/product/domain"
+	"github.com/labstack/echo/v4"
+)
+
+type auctionHandler struct {
+	auctionUC usecase.Auction
+}
+
+// NewAuctionHandler handler for bids on products,
+// the group must already use the jwt middleware
+func NewAuctionHandler(g *echo.Group, auctionUC usecase.Auction) {
+	handler := &auctionHandler{auctionUC: auctionUC}
+
+	g.POST("/:id/bids", handler.PlaceBid)
+	g.GET("/:id/bids", handler.ListBids)
+}
+
+func (h *auctionHandler) PlaceBid(c echo.Context) error {
+	var bid domain.Bid
+
+	// bind request body to bid
+	if err := c.Bind(&bid); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewBadRequestError(err.Error())))
+	}
+
+	// validate the struct
+	if err := utils.ValidateStruct(&bid); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewValidationErrors(err)))
+	}
+
+	// get user from the context
+	user := middlewares.UserFromContext(c)
+
+	// call the usecase
+	updatedProduct, err := h.auctionUC.PlaceBid(c.Request().Context(), c.Param("id"), user.ID, bid.Amount)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusCreated, updatedProduct)
+}
+
+func (h *auctionHandler) ListBids(c echo.Context) error {
+	// call the usecase
+	bids, err := h.auctionUC.ListBids(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, bids)
+}
@@ -0,0 +1,39 @@
+package ticker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/halilylm/secondhand/product/auction/usecase"
+)
+
+// Closer closes ended auctions periodically
+type Closer struct {
+	auctionUC usecase.Auction
+	interval  time.Duration
+}
+
+// NewCloser returns a closer checking auctions every interval
+func NewCloser(auctionUC usecase.Auction, interval time.Duration) *Closer {
+	return &Closer{
+		auctionUC: auctionUC,
+		interval:  interval,
+	}
+}
+
+// Run closes ended auctions until the context is done
+func (c *Closer) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.auctionUC.CloseDueAuctions(ctx); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
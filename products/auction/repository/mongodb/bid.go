@@ -0,0 +1,77 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/halilylm/secondhand/product/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type bidRepository struct {
+	collection *mongo.Collection
+}
+
+// NewBidRepository returns a new mongo bid repository
+func NewBidRepository(collection *mongo.Collection) domain.BidRepository {
+	return &bidRepository{collection: collection}
+}
+
+// CreateBidIndexes creates the indexes the bid history relies on
+func CreateBidIndexes(ctx context.Context, collection *mongo.Collection) error {
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "product_id", Value: 1}, {Key: "amount", Value: -1}},
+	})
+	return err
+}
+
+// Insert records the bid in the history
+func (b *bidRepository) Insert(ctx context.Context, bid *domain.Bid) (*domain.Bid, error) {
+	if bid.ID == "" {
+		bid.ID = uuid.NewString()
+	}
+	if _, err := b.collection.InsertOne(ctx, bid); err != nil {
+		return nil, err
+	}
+	return bid, nil
+}
+
+// ListByProduct returns the bids of the auction, highest first
+func (b *bidRepository) ListByProduct(ctx context.Context, productID string) ([]*domain.Bid, error) {
+	bids := make([]*domain.Bid, 0)
+	cur, err := b.collection.Find(ctx, bson.M{
+		"product_id": productID,
+	}, options.Find().SetSort(bson.D{{Key: "amount", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var bid domain.Bid
+		if err := cur.Decode(&bid); err != nil {
+			return nil, err
+		}
+		bids = append(bids, &bid)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return bids, nil
+}
+
+// Bidders returns the users who bid on the auction
+func (b *bidRepository) Bidders(ctx context.Context, productID string) ([]string, error) {
+	values, err := b.collection.Distinct(ctx, "user_id", bson.M{"product_id": productID})
+	if err != nil {
+		return nil, err
+	}
+	bidders := make([]string, 0, len(values))
+	for _, value := range values {
+		if userID, ok := value.(string); ok {
+			bidders = append(bidders, userID)
+		}
+	}
+	return bidders, nil
+}
@@ -0,0 +1,228 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/halilylm/gommon/logger"
+	"github.com/halilylm/gommon/rest"
+	"github.com/halilylm/secondhand/messaging/outbox"
+	"github.com/halilylm/secondhand/product/domain"
+	_notificationUC "github.com/halilylm/secondhand/product/notification/usecase"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// closeBatchSize caps the auctions closed in one run
+const closeBatchSize = 100
+
+type auction struct {
+	productRepo    domain.ProductRepository
+	bidRepo        domain.BidRepository
+	notificationUC _notificationUC.Notification
+	logger         logger.Logger
+	outbox         outbox.Store
+}
+
+// NewAuction returns auction usecase
+func NewAuction(
+	productRepo domain.ProductRepository,
+	bidRepo domain.BidRepository,
+	notificationUC _notificationUC.Notification,
+	logger logger.Logger,
+	outbox outbox.Store,
+) Auction {
+	return &auction{
+		productRepo:    productRepo,
+		bidRepo:        bidRepo,
+		notificationUC: notificationUC,
+		logger:         logger,
+		outbox:         outbox,
+	}
+}
+
+// PlaceBid makes the bid of the user the highest one,
+// bids close to the end extend the auction
+func (a *auction) PlaceBid(ctx context.Context, productID, userID string, amount int) (*domain.Product, error) {
+	// find the auction
+	foundProduct, err := a.findAuction(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if foundProduct.UserID == userID {
+		return nil, rest.NewBadRequestError(domain.ErrOwnAuction.Error())
+	}
+
+	// check the bid before trying
+	now := time.Now().UTC()
+	if err := checkBid(foundProduct.Auction, amount, now); err != nil {
+		return nil, err
+	}
+
+	bid := &domain.Bid{
+		ID:        uuid.NewString(),
+		ProductID: productID,
+		UserID:    userID,
+		Amount:    amount,
+		CreatedAt: now,
+	}
+	updatedProduct, err := a.productRepo.PlaceBid(ctx, productID, bid, now.Add(domain.AuctionExtension))
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			a.logger.Error(err)
+			return nil, rest.NewInternalServerError()
+		}
+
+		// outbid or closed meanwhile
+		foundProduct, err := a.findAuction(ctx, productID)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkBid(foundProduct.Auction, amount, now); err != nil {
+			return nil, err
+		}
+		return nil, rest.NewBadRequestError(domain.ErrBidTooLow.Error())
+	}
+
+	// the product holds the highest bid,
+	// the history is for display only
+	if _, err := a.bidRepo.Insert(ctx, bid); err != nil {
+		a.logger.Error(err)
+	}
+	return updatedProduct, nil
+}
+
+// checkBid returns why the amount cannot be bid on the auction
+func checkBid(auction *domain.Auction, amount int, now time.Time) error {
+	if !auction.IsOpen(now) {
+		return rest.NewBadRequestError(domain.ErrAuctionClosed.Error())
+	}
+	if amount < auction.MinimumBid() {
+		return rest.NewBadRequestError(fmt.Sprintf("%s, minimum bid is %d", domain.ErrBidTooLow, auction.MinimumBid()))
+	}
+	return nil
+}
+
+// ListBids returns the bids of the auction, highest first
+func (a *auction) ListBids(ctx context.Context, productID string) ([]*domain.Bid, error) {
+	// find the auction
+	if _, err := a.findAuction(ctx, productID); err != nil {
+		return nil, err
+	}
+
+	bids, err := a.bidRepo.ListByProduct(ctx, productID)
+	if err != nil {
+		a.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	return bids, nil
+}
+
+// findAuction finds the product, fixed price
+// products have no auction
+func (a *auction) findAuction(ctx context.Context, productID string) (*domain.Product, error) {
+	foundProduct, err := a.productRepo.FindByID(ctx, productID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, rest.NewNotFoundError()
+		}
+		a.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	if foundProduct.ArchivedAt != nil || foundProduct.Hidden() {
+		return nil, rest.NewNotFoundError()
+	}
+	if !foundProduct.IsAuction() {
+		return nil, rest.NewBadRequestError(domain.ErrNotAuction.Error())
+	}
+	return foundProduct, nil
+}
+
+// CloseDueAuctions closes the auctions that have ended, the winner
+// gets an order through the orders service and the others are notified
+func (a *auction) CloseDueAuctions(ctx context.Context) error {
+	now := time.Now().UTC()
+	dueProducts, err := a.productRepo.DueAuctions(ctx, now, closeBatchSize)
+	if err != nil {
+		a.logger.Error(err)
+		return err
+	}
+	for _, dueProduct := range dueProducts {
+		a.closeAuction(ctx, dueProduct, now)
+	}
+	return nil
+}
+
+func (a *auction) closeAuction(ctx context.Context, dueProduct *domain.Product, now time.Time) {
+	// the highest bid wins if it meets the reserve price
+	status := domain.AuctionUnsold
+	highestBid := dueProduct.Auction.HighestBid
+	if highestBid != nil && highestBid.Amount >= dueProduct.Auction.ReservePrice {
+		status = domain.AuctionSold
+	}
+
+	// the winner gets the order once the auction is closed
+	var closedProduct *domain.Product
+	err := a.outbox.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		closedProduct, err = a.productRepo.CloseAuction(ctx, dueProduct.ID, status, now)
+		if err != nil {
+			return err
+		}
+		if status != domain.AuctionSold {
+			return nil
+		}
+		return a.outbox.Add(ctx, domain.AuctionWon, outbox.ForAggregate(closedProduct.ID, domain.AuctionWonEvent{
+			ProductID: closedProduct.ID,
+			UserID:    closedProduct.Auction.HighestBid.UserID,
+			BidID:     closedProduct.Auction.HighestBid.ID,
+			Amount:    closedProduct.Auction.HighestBid.Amount,
+		}))
+	})
+	if err != nil {
+		// extended by a late bid or closed by another instance
+		if err != mongo.ErrNoDocuments {
+			a.logger.Error(err)
+		}
+		return
+	}
+
+	losers, err := a.bidRepo.Bidders(ctx, closedProduct.ID)
+	if err != nil {
+		a.logger.Error(err)
+	}
+	if status == domain.AuctionSold {
+		winnerID := closedProduct.Auction.HighestBid.UserID
+		a.notify(ctx, []string{winnerID}, domain.NotificationAuctionWon, closedProduct,
+			fmt.Sprintf("You won the auction of %s for %d", closedProduct.Title, closedProduct.Auction.HighestBid.Amount))
+		losers = without(losers, winnerID)
+	}
+	a.notify(ctx, losers, domain.NotificationAuctionLost, closedProduct,
+		fmt.Sprintf("The auction of %s ended without your bid winning", closedProduct.Title))
+}
+
+// notify logs failures, closing an auction
+// doesn't depend on its notifications
+func (a *auction) notify(ctx context.Context, userIDs []string, kind domain.NotificationKind, product *domain.Product, message string) {
+	if err := a.notificationUC.Notify(ctx, userIDs, kind, product.ID, message); err != nil {
+		a.logger.Error(err)
+	}
+}
+
+// without returns the ids except the given one
+func without(ids []string, id string) []string {
+	filtered := make([]string, 0, len(ids))
+	for _, other := range ids {
+		if other != id {
+			filtered = append(filtered, other)
+		}
+	}
+	return filtered
+}
+
+type Auction interface {
+	PlaceBid(ctx context.Context, productID, userID string, amount int) (*domain.Product, error)
+	ListBids(ctx context.Context, productID string) ([]*domain.Bid, error)
+	CloseDueAuctions(ctx context.Context) error
+}
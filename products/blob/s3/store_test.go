@@ -0,0 +1,158 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/halilylm/secondhand/product/domain"
+)
+
+// bucket is an s3 stand-in keeping the objects in memory
+type bucket struct {
+	t       *testing.T
+	name    string
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func (b *bucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=access/") ||
+		!strings.Contains(auth, "SignedHeaders=host;x-amz-content-sha256;x-amz-date") {
+		b.t.Errorf("unsigned request: %q", auth)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	sum := sha256.Sum256(body)
+	if r.Header.Get("x-amz-content-sha256") != hex.EncodeToString(sum[:]) {
+		b.t.Errorf("payload hash doesn't match the body")
+	}
+
+	prefix := "/" + b.name + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, prefix)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		b.objects[key] = body
+		b.types[key] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		data, found := b.objects[key]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", b.types[key])
+		w.Write(data)
+	case http.MethodDelete:
+		delete(b.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newTestStore(t *testing.T) (domain.BlobStore, *bucket) {
+	b := &bucket{
+		t:       t,
+		name:    "images",
+		objects: make(map[string][]byte),
+		types:   make(map[string]string),
+	}
+	server := httptest.NewServer(b)
+	t.Cleanup(server.Close)
+	return NewStore(Options{
+		Endpoint:  server.URL + "/",
+		Region:    "eu-central-1",
+		Bucket:    b.name,
+		AccessKey: "access",
+		SecretKey: "secret",
+		Client:    server.Client(),
+	}), b
+}
+
+func TestStorePutGetDelete(t *testing.T) {
+	store, b := newTestStore(t)
+	ctx := context.Background()
+	key := "products/abc/1.jpg"
+	data := []byte("jpeg bytes")
+
+	if err := store.Put(ctx, key, data, "image/jpeg"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if b.types[key] != "image/jpeg" {
+		t.Errorf("content type = %q, want image/jpeg", b.types[key])
+	}
+
+	body, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	got, _ := io.ReadAll(body)
+	body.Close()
+	if !bytes.Equal(got, data) {
+		t.Errorf("get = %q, want %q", got, data)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.Get(ctx, key); !errors.Is(err, domain.ErrBlobNotFound) {
+		t.Errorf("get after delete = %v, want ErrBlobNotFound", err)
+	}
+}
+
+func TestStoreGetMissing(t *testing.T) {
+	store, _ := newTestStore(t)
+	if _, err := store.Get(context.Background(), "missing"); !errors.Is(err, domain.ErrBlobNotFound) {
+		t.Errorf("get = %v, want ErrBlobNotFound", err)
+	}
+}
+
+func TestStoreEncodesKeys(t *testing.T) {
+	store, b := newTestStore(t)
+	key := "products/a b+c/1.jpg"
+	if err := store.Put(context.Background(), key, []byte("x"), "image/jpeg"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if _, found := b.objects[key]; !found {
+		t.Errorf("object stored under %v, want %q", keys(b.objects), key)
+	}
+}
+
+func TestStoreFailedPut(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, "<Error><Code>AccessDenied</Code></Error>")
+	}))
+	defer server.Close()
+	store := NewStore(Options{Endpoint: server.URL, Region: "us-east-1", Bucket: "images"})
+	err := store.Put(context.Background(), "key", []byte("x"), "image/jpeg")
+	if err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("put = %v, want the s3 error", err)
+	}
+}
+
+func keys(objects map[string][]byte) []string {
+	var names []string
+	for name := range objects {
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,107 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/halilylm/gommon/rest"
+	"github.com/halilylm/gommon/utils"
+	"github.com/halilylm/secondhand/messaging/roles"
+	"github.com/halilylm/secondhand/product/category/usecase"
+	"github.com/halilylm/secondhand/product/domain"
+	"github.com/labstack/echo/v4"
+)
+
+type categoryHandler struct {
+	categoryUC usecase.Category
+}
+
+// NewCategoryHandler handler for categories,
+// the group must already use the jwt middleware
+func NewCategoryHandler(g *echo.Group, categoryUC usecase.Category, appRoles *roles.Roles) {
+	handler := &categoryHandler{categoryUC: categoryUC}
+
+	g.GET("/", handler.ListCategories)
+	g.GET("/:id", handler.ShowCategory)
+
+	// categories are managed by admins
+	admin := appRoles.Require(roles.Admin)
+	g.POST("/", handler.NewCategory, admin)
+	g.PUT("/:id", handler.UpdateCategory, admin)
+	g.DELETE("/:id", handler.DeleteCategory, admin)
+}
+
+func (h *categoryHandler) NewCategory(c echo.Context) error {
+	var category domain.Category
+
+	// bind request body to category
+	if err := c.Bind(&category); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewBadRequestError(err.Error())))
+	}
+
+	// validate the struct
+	if err := utils.ValidateStruct(&category); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewValidationErrors(err)))
+	}
+
+	// call the usecase
+	createdCategory, err := h.categoryUC.CreateCategory(c.Request().Context(), &category)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusCreated, createdCategory)
+}
+
+func (h *categoryHandler) UpdateCategory(c echo.Context) error {
+	var category domain.Category
+
+	// bind request body to category
+	if err := c.Bind(&category); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewBadRequestError(err.Error())))
+	}
+
+	// validate the struct
+	if err := utils.ValidateStruct(&category); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewValidationErrors(err)))
+	}
+
+	// fill the category id
+	category.ID = c.Param("id")
+
+	// call the usecase
+	updatedCategory, err := h.categoryUC.UpdateCategory(c.Request().Context(), &category)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, updatedCategory)
+}
+
+func (h *categoryHandler) ShowCategory(c echo.Context) error {
+	// call the usecase
+	foundCategory, err := h.categoryUC.ShowCategory(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, foundCategory)
+}
+
+func (h *categoryHandler) ListCategories(c echo.Context) error {
+	// call the usecase
+	categories, err := h.categoryUC.ListCategories(c.Request().Context())
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, categories)
+}
+
+func (h *categoryHandler) DeleteCategory(c echo.Context) error {
+	// call the usecase
+	if err := h.categoryUC.DeleteCategory(c.Request().Context(), c.Param("id")); err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
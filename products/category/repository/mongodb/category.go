@@ -0,0 +1,124 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/halilylm/secondhand/product/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type categoryRepository struct {
+	collection *mongo.Collection
+}
+
+// NewCategoryRepository returns a new mongo category repository
+func NewCategoryRepository(collection *mongo.Collection) domain.CategoryRepository {
+	return &categoryRepository{collection: collection}
+}
+
+// Insert creates a new category in mongodb
+func (c *categoryRepository) Insert(ctx context.Context, category *domain.Category) (*domain.Category, error) {
+	category.ID = uuid.NewString()
+	if _, err := c.collection.InsertOne(ctx, category); err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// Update changes name and attributes of the category,
+// categories are not moved in the tree
+func (c *categoryRepository) Update(ctx context.Context, category *domain.Category) (*domain.Category, error) {
+	var updatedCategory domain.Category
+	res := c.collection.FindOneAndUpdate(ctx, bson.M{
+		"_id": category.ID,
+	}, bson.M{"$set": map[string]any{
+		"name":       category.Name,
+		"attributes": category.Attributes,
+	}}, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&updatedCategory); err != nil {
+		return nil, err
+	}
+	return &updatedCategory, nil
+}
+
+// FindByID finds a category by its id
+func (c *categoryRepository) FindByID(ctx context.Context, id string) (*domain.Category, error) {
+	var foundCategory domain.Category
+	res := c.collection.FindOne(ctx, bson.M{"_id": id})
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&foundCategory); err != nil {
+		return nil, err
+	}
+	return &foundCategory, nil
+}
+
+// List returns every category
+func (c *categoryRepository) List(ctx context.Context) ([]*domain.Category, error) {
+	categories := make([]*domain.Category, 0)
+	cur, err := c.collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var category domain.Category
+		if err := cur.Decode(&category); err != nil {
+			continue
+		}
+		categories = append(categories, &category)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+// SubtreeIDs returns the id of the category and its descendants
+func (c *categoryRepository) SubtreeIDs(ctx context.Context, id string) ([]string, error) {
+	ids := []string{id}
+	cur, err := c.collection.Find(ctx, bson.M{"ancestors": id}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var category domain.Category
+		if err := cur.Decode(&category); err != nil {
+			continue
+		}
+		ids = append(ids, category.ID)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// HasChildren checks if any category is under the category
+func (c *categoryRepository) HasChildren(ctx context.Context, id string) (bool, error) {
+	count, err := c.collection.CountDocuments(ctx, bson.M{"parent_id": id}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// Delete removes the category
+func (c *categoryRepository) Delete(ctx context.Context, id string) error {
+	res, err := c.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
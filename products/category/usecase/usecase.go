@@ -0,0 +1,115 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/halilylm/gommon/logger"
+	"github.com/halilylm/gommon/rest"
+	"github.com/halilylm/secondhand/product/domain"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type category struct {
+	categoryRepo domain.CategoryRepository
+	productRepo  domain.ProductRepository
+	logger       logger.Logger
+}
+
+// NewCategory returns category usecase
+func NewCategory(categoryRepo domain.CategoryRepository, productRepo domain.ProductRepository, logger logger.Logger) Category {
+	return &category{categoryRepo: categoryRepo, productRepo: productRepo, logger: logger}
+}
+
+// CreateCategory places the category under its parent
+func (c *category) CreateCategory(ctx context.Context, category *domain.Category) (*domain.Category, error) {
+	category.Ancestors = []string{}
+	if category.ParentID != nil {
+		parent, err := c.categoryRepo.FindByID(ctx, *category.ParentID)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return nil, rest.NewBadRequestError("parent category not found")
+			}
+			c.logger.Error(err)
+			return nil, rest.NewInternalServerError()
+		}
+		category.Ancestors = append(parent.Ancestors, parent.ID)
+	}
+
+	createdCategory, err := c.categoryRepo.Insert(ctx, category)
+	if err != nil {
+		c.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	return createdCategory, nil
+}
+
+// UpdateCategory changes name and attribute schemas
+func (c *category) UpdateCategory(ctx context.Context, category *domain.Category) (*domain.Category, error) {
+	updatedCategory, err := c.categoryRepo.Update(ctx, category)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, rest.NewNotFoundError()
+		}
+		c.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	return updatedCategory, nil
+}
+
+// ShowCategory finds the category
+func (c *category) ShowCategory(ctx context.Context, id string) (*domain.Category, error) {
+	foundCategory, err := c.categoryRepo.FindByID(ctx, id)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, rest.NewNotFoundError()
+		}
+		c.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	return foundCategory, nil
+}
+
+// ListCategories returns the whole tree
+func (c *category) ListCategories(ctx context.Context) ([]*domain.Category, error) {
+	categories, err := c.categoryRepo.List(ctx)
+	if err != nil {
+		c.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	return categories, nil
+}
+
+// DeleteCategory removes a category nothing belongs to
+func (c *category) DeleteCategory(ctx context.Context, id string) error {
+	hasChildren, err := c.categoryRepo.HasChildren(ctx, id)
+	if err != nil {
+		c.logger.Error(err)
+		return rest.NewInternalServerError()
+	}
+	productCount, err := c.productRepo.CountByCategory(ctx, id)
+	if err != nil {
+		c.logger.Error(err)
+		return rest.NewInternalServerError()
+	}
+	if hasChildren || productCount > 0 {
+		return rest.NewBadRequestError(domain.ErrCategoryInUse.Error())
+	}
+
+	if err := c.categoryRepo.Delete(ctx, id); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return rest.NewNotFoundError()
+		}
+		c.logger.Error(err)
+		return rest.NewInternalServerError()
+	}
+	return nil
+}
+
+// Category contract
+type Category interface {
+	CreateCategory(ctx context.Context, category *domain.Category) (*domain.Category, error)
+	UpdateCategory(ctx context.Context, category *domain.Category) (*domain.Category, error)
+	ShowCategory(ctx context.Context, id string) (*domain.Category, error)
+	ListCategories(ctx context.Context) ([]*domain.Category, error)
+	DeleteCategory(ctx context.Context, id string) error
+}
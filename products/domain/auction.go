@@ -0,0 +1,108 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotAuction returned when bidding on a fixed price product
+	ErrNotAuction = errors.New("product is not an auction")
+	// ErrAuctionClosed returned when bidding after the end
+	ErrAuctionClosed = errors.New("auction is closed")
+	// ErrBidTooLow returned when the bid doesn't beat
+	// the highest bid by the minimum increment
+	ErrBidTooLow = errors.New("bid is too low")
+	// ErrOwnAuction returned when the seller bids on the product
+	ErrOwnAuction = errors.New("cannot bid on your own auction")
+	// ErrAuctionHasBids returned when the seller changes
+	// an auction somebody already bid on
+	ErrAuctionHasBids = errors.New("auction already has bids")
+	// ErrInvalidAuction returned when the auction
+	// of a new product cannot run
+	ErrInvalidAuction = errors.New("invalid auction")
+)
+
+// ListingType tells how a product is sold
+type ListingType string
+
+const (
+	ListingFixed   ListingType = "fixed"
+	ListingAuction ListingType = "auction"
+)
+
+// AuctionStatus is the state of an auction
+type AuctionStatus string
+
+const (
+	AuctionOpen   AuctionStatus = "open"
+	AuctionSold   AuctionStatus = "sold"
+	AuctionUnsold AuctionStatus = "unsold"
+)
+
+const (
+	// AuctionExtension is the time a late bid leaves
+	// for others to answer, bids placed closer to
+	// the end push it back
+	AuctionExtension = 2 * time.Minute
+	// MaxAuctionDuration caps how long an auction runs
+	MaxAuctionDuration = 30 * 24 * time.Hour
+)
+
+// Auction of a product, a single item
+// sold to the highest bidder
+type Auction struct {
+	StartPrice int `json:"start_price" bson:"start_price" validate:"min=0"`
+	// ReservePrice is the lowest price the seller sells for,
+	// auctions closing below it end unsold
+	ReservePrice int           `json:"reserve_price" bson:"reserve_price" validate:"min=0"`
+	MinIncrement int           `json:"min_increment" bson:"min_increment" validate:"min=1"`
+	EndsAt       time.Time     `json:"ends_at" bson:"ends_at" validate:"required"`
+	Status       AuctionStatus `json:"status" bson:"status"`
+	HighestBid   *Bid          `json:"highest_bid,omitempty" bson:"highest_bid"`
+	BidCount     int           `json:"bid_count" bson:"bid_count"`
+	ClosedAt     *time.Time    `json:"closed_at,omitempty" bson:"closed_at"`
+}
+
+// MinimumBid returns the lowest amount the next bid can be
+func (a *Auction) MinimumBid() int {
+	if a.HighestBid == nil {
+		return a.StartPrice
+	}
+	return a.HighestBid.Amount + a.MinIncrement
+}
+
+// IsOpen reports whether bids are still accepted
+func (a *Auction) IsOpen(now time.Time) bool {
+	return a.Status == AuctionOpen && now.Before(a.EndsAt)
+}
+
+// Bid of a user on an auction
+type Bid struct {
+	ID        string    `json:"id" bson:"_id,omitempty"`
+	ProductID string    `json:"product_id" bson:"product_id"`
+	UserID    string    `json:"user_id" bson:"user_id"`
+	Amount    int       `json:"amount" bson:"amount" validate:"min=1"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+// BidRepository keeps the bid history of auctions
+type BidRepository interface {
+	Insert(ctx context.Context, bid *Bid) (*Bid, error)
+	ListByProduct(ctx context.Context, productID string) ([]*Bid, error)
+	Bidders(ctx context.Context, productID string) ([]string, error)
+}
+
+// AuctionWon is published when an auction closes
+// above its reserve price
+const AuctionWon = "auction:won"
+
+// AuctionWonEvent tells orders to create
+// the order of the winning bidder
+type AuctionWonEvent struct {
+	ProductID string `json:"product_id"`
+	UserID    string `json:"user_id"`
+	BidID     string `json:"bid_id"`
+	Amount    int    `json:"amount"`
+}
@@ -0,0 +1,141 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrCategoryInUse returned when a category still
+// has subcategories or products
+var ErrCategoryInUse = errors.New("category has subcategories or products")
+
+// AttributeType of a category attribute value
+type AttributeType string
+
+const (
+	AttributeString AttributeType = "string"
+	AttributeNumber AttributeType = "number"
+	AttributeBool   AttributeType = "bool"
+	AttributeEnum   AttributeType = "enum"
+)
+
+// AttributeSchema describes an attribute
+// products of a category can have
+type AttributeSchema struct {
+	Name     string        `json:"name" bson:"name" validate:"required"`
+	Type     AttributeType `json:"type" bson:"type" validate:"required,oneof=string number bool enum"`
+	Required bool          `json:"required" bson:"required"`
+	Options  []string      `json:"options,omitempty" bson:"options,omitempty" validate:"required_if=Type enum"`
+}
+
+// Category of products, categories form a tree
+// and ancestors hold the ids from root to parent
+type Category struct {
+	ID         string            `json:"id" bson:"_id,omitempty"`
+	Name       string            `json:"name" bson:"name" validate:"required"`
+	ParentID   *string           `json:"parent_id" bson:"parent_id"`
+	Ancestors  []string          `json:"ancestors" bson:"ancestors"`
+	Attributes []AttributeSchema `json:"attributes" bson:"attributes" validate:"dive"`
+}
+
+// ValidateAttributes checks product attributes against the schema
+// and returns them converted to the schema types
+func (c *Category) ValidateAttributes(attributes map[string]any) (map[string]any, error) {
+	validated := make(map[string]any, len(attributes))
+	known := make(map[string]struct{}, len(c.Attributes))
+	for _, schema := range c.Attributes {
+		known[schema.Name] = struct{}{}
+		value, found := attributes[schema.Name]
+		if !found || value == nil {
+			if schema.Required {
+				return nil, fmt.Errorf("attribute %s is required", schema.Name)
+			}
+			continue
+		}
+		converted, ok := schema.convert(value)
+		if !ok {
+			return nil, fmt.Errorf("attribute %s must be a valid %s", schema.Name, schema.Type)
+		}
+		validated[schema.Name] = converted
+	}
+	for name := range attributes {
+		if _, found := known[name]; !found {
+			return nil, fmt.Errorf("attribute %s is not allowed in category %s", name, c.Name)
+		}
+	}
+	return validated, nil
+}
+
+// ParseAttributeFilters converts attribute filters
+// coming from query strings to the schema types
+func (c *Category) ParseAttributeFilters(filters map[string]any) (map[string]any, error) {
+	parsed := make(map[string]any, len(filters))
+	for name, value := range filters {
+		var schema *AttributeSchema
+		for i := range c.Attributes {
+			if c.Attributes[i].Name == name {
+				schema = &c.Attributes[i]
+			}
+		}
+		if schema == nil {
+			return nil, fmt.Errorf("attribute %s is not allowed in category %s", name, c.Name)
+		}
+		converted, ok := schema.convert(value)
+		if !ok {
+			return nil, fmt.Errorf("attribute %s must be a valid %s", name, schema.Type)
+		}
+		parsed[name] = converted
+	}
+	return parsed, nil
+}
+
+// convert the value to the schema type, values coming from
+// query strings are converted from their string form
+func (a *AttributeSchema) convert(value any) (any, bool) {
+	switch a.Type {
+	case AttributeNumber:
+		switch v := value.(type) {
+		case float64:
+			return v, true
+		case string:
+			var f float64
+			if _, err := fmt.Sscan(v, &f); err == nil {
+				return f, true
+			}
+		}
+	case AttributeBool:
+		switch v := value.(type) {
+		case bool:
+			return v, true
+		case string:
+			if v == "true" || v == "false" {
+				return v == "true", true
+			}
+		}
+	case AttributeEnum:
+		if v, ok := value.(string); ok {
+			for _, option := range a.Options {
+				if v == option {
+					return v, true
+				}
+			}
+		}
+	default:
+		if v, ok := value.(string); ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// CategoryRepository to interact db
+type CategoryRepository interface {
+	Insert(ctx context.Context, category *Category) (*Category, error)
+	Update(ctx context.Context, category *Category) (*Category, error)
+	FindByID(ctx context.Context, id string) (*Category, error)
+	List(ctx context.Context) ([]*Category, error)
+	SubtreeIDs(ctx context.Context, id string) ([]string, error)
+	HasChildren(ctx context.Context, id string) (bool, error)
+	Delete(ctx context.Context, id string) error
+}
@@ -0,0 +1,154 @@
+package domain
+
+import "github.com/halilylm/gommon/events/common/messages"
+
+// ProductCreatedEvent is the shared event
+// extended with the product classification, stock and visibility
+type ProductCreatedEvent struct {
+	messages.ProductCreatedEvent
+	CategoryID string      `json:"category_id"`
+	Tags       []string    `json:"tags"`
+	Quantity   int         `json:"quantity"`
+	Listing    ListingType `json:"listing"`
+	Hidden     bool        `json:"hidden"`
+}
+
+// NewProductCreatedEvent returns the event of the product
+func NewProductCreatedEvent(product *Product) ProductCreatedEvent {
+	return ProductCreatedEvent{
+		ProductCreatedEvent: messages.ProductCreatedEvent{
+			ID:      product.ID,
+			Version: product.Version,
+			Title:   product.Title,
+			Price:   product.Price,
+			UserID:  product.UserID,
+		},
+		CategoryID: product.CategoryID,
+		Tags:       product.Tags,
+		Quantity:   product.Quantity,
+		Listing:    product.Listing,
+		Hidden:     product.Hidden(),
+	}
+}
+
+// ProductUpdatedEvent is the shared event
+// extended with the product classification, stock and visibility
+type ProductUpdatedEvent struct {
+	messages.ProductUpdatedEvent
+	CategoryID string      `json:"category_id"`
+	Tags       []string    `json:"tags"`
+	Quantity   int         `json:"quantity"`
+	Listing    ListingType `json:"listing"`
+	Hidden     bool        `json:"hidden"`
+}
+
+// NewProductUpdatedEvent returns the event of the product
+func NewProductUpdatedEvent(product *Product) ProductUpdatedEvent {
+	return ProductUpdatedEvent{
+		ProductUpdatedEvent: messages.ProductUpdatedEvent{
+			ID:      product.ID,
+			Version: product.Version,
+			Title:   product.Title,
+			Price:   product.Price,
+			UserID:  product.UserID,
+		},
+		CategoryID: product.CategoryID,
+		Tags:       product.Tags,
+		Quantity:   product.Quantity,
+		Listing:    product.Listing,
+		Hidden:     product.Hidden(),
+	}
+}
+
+// OrderCreatedEvent is the shared event extended with the
+// ordered quantity and the line items of cart orders
+type OrderCreatedEvent struct {
+	messages.OrderCreatedEvent
+	Quantity int         `json:"quantity"`
+	Items    []OrderItem `json:"items,omitempty"`
+}
+
+// OrderItems returns the products of the order,
+// orders of a single product have no line items
+func (e *OrderCreatedEvent) OrderItems() []OrderItem {
+	if len(e.Items) > 0 {
+		return e.Items
+	}
+	return []OrderItem{{ProductID: e.ProductID, Quantity: e.Quantity}}
+}
+
+// OrderCancelledEvent is the shared event
+// extended with the line items of cart orders
+type OrderCancelledEvent struct {
+	messages.OrderCancelledEvent
+	Items []OrderItem `json:"items,omitempty"`
+}
+
+// OrderItems returns the products of the order,
+// orders of a single product have no line items
+func (e *OrderCancelledEvent) OrderItems() []OrderItem {
+	if len(e.Items) > 0 {
+		return e.Items
+	}
+	return []OrderItem{{ProductID: e.ProductID}}
+}
+
+// OrderItem is a product and quantity of an order
+type OrderItem struct {
+	ProductID string `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+}
+
+// OrderReserved is published when the stock
+// of every item of the order is held
+const OrderReserved = "order:reserved"
+
+// OrderReservedEvent tells orders the order can be paid
+type OrderReservedEvent struct {
+	ID string `json:"id"`
+}
+
+// OrderRejected is published when the
+// stock of the order cannot be held
+const OrderRejected = "order:rejected"
+
+// OrderRejectedEvent tells orders why the order is cancelled
+type OrderRejectedEvent struct {
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// ProductDeleted is published when a seller takes down a listing
+const ProductDeleted = "product:deleted"
+
+// ProductDeletedEvent tells replicas the product
+// cannot be ordered anymore
+type ProductDeletedEvent struct {
+	ID      string `json:"id"`
+	Version int    `json:"version"`
+}
+
+// ProductPriceDropped is published when the
+// seller lowers the price of a product
+const ProductPriceDropped = "product:price-dropped"
+
+// ProductPriceDroppedEvent tells watchers the new price
+type ProductPriceDroppedEvent struct {
+	ID       string `json:"id"`
+	Version  int    `json:"version"`
+	Title    string `json:"title"`
+	OldPrice int    `json:"old_price"`
+	Price    int    `json:"price"`
+}
+
+// ProductSoldOut is published when a
+// reservation takes the last of the stock
+const ProductSoldOut = "product:sold-out"
+
+// ProductSoldOutEvent tells watchers the
+// product cannot be ordered anymore
+type ProductSoldOutEvent struct {
+	ID      string `json:"id"`
+	Version int    `json:"version"`
+	Title   string `json:"title"`
+}
@@ -0,0 +1,78 @@
+package domain
+
+import (
+	"context"
+	"reflect"
+	"time"
+)
+
+// ProductRevision records what changed in a version of a product,
+// revisions are only appended and never changed
+type ProductRevision struct {
+	ID        string        `json:"id" bson:"_id,omitempty"`
+	ProductID string        `json:"product_id" bson:"product_id"`
+	Version   int           `json:"version" bson:"version"`
+	ActorID   string        `json:"actor_id,omitempty" bson:"actor_id"`
+	Changes   []FieldChange `json:"changes" bson:"changes"`
+	CreatedAt time.Time     `json:"created_at" bson:"created_at"`
+}
+
+// FieldChange holds the value of a field before and after
+// the revision, From is empty for created products
+type FieldChange struct {
+	Field string `json:"field" bson:"field"`
+	From  any    `json:"from,omitempty" bson:"from,omitempty"`
+	To    any    `json:"to" bson:"to"`
+}
+
+// OrderActor is the actor of revisions made for the order
+func OrderActor(orderID string) string {
+	return "order:" + orderID
+}
+
+// NewProductRevision returns the revision that took the product
+// from previous to current, previous is nil for created products
+func NewProductRevision(previous, current *Product, actorID string) *ProductRevision {
+	if previous == nil {
+		previous = &Product{}
+	}
+	fields := []struct {
+		name     string
+		from, to any
+	}{
+		{"title", previous.Title, current.Title},
+		{"price", previous.Price, current.Price},
+		{"quantity", previous.Quantity, current.Quantity},
+		{"available", previous.Available, current.Available},
+		{"category_id", previous.CategoryID, current.CategoryID},
+		{"tags", previous.Tags, current.Tags},
+		{"attributes", previous.Attributes, current.Attributes},
+		{"archived_at", previous.ArchivedAt, current.ArchivedAt},
+		{"moderation", previous.Moderation.Status, current.Moderation.Status},
+	}
+
+	changes := make([]FieldChange, 0, len(fields))
+	for _, field := range fields {
+		if reflect.DeepEqual(field.from, field.to) {
+			continue
+		}
+		changes = append(changes, FieldChange{
+			Field: field.name,
+			From:  field.from,
+			To:    field.to,
+		})
+	}
+	return &ProductRevision{
+		ProductID: current.ID,
+		Version:   current.Version,
+		ActorID:   actorID,
+		Changes:   changes,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// HistoryRepository to interact db
+type HistoryRepository interface {
+	Insert(ctx context.Context, revision *ProductRevision) error
+	ListByProduct(ctx context.Context, productID string) ([]*ProductRevision, error)
+}
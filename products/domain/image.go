@@ -0,0 +1,48 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+const (
+	// MaxProductImages a product can have
+	MaxProductImages = 10
+
+	// MaxImageSize in bytes of an uploaded image
+	MaxImageSize = 10 << 20
+)
+
+// ErrBlobNotFound returned by blob stores for missing keys
+var ErrBlobNotFound = errors.New("blob not found")
+
+// ErrTooManyImages returned when a product
+// reached the maximum number of images
+var ErrTooManyImages = errors.New("product has too many images")
+
+// Image of a product, originals and thumbnails
+// are kept in the blob store under their keys
+type Image struct {
+	ID           string    `json:"id" bson:"id"`
+	Key          string    `json:"-" bson:"key"`
+	ThumbnailKey string    `json:"-" bson:"thumbnail_key"`
+	ContentType  string    `json:"content_type" bson:"content_type"`
+	Size         int       `json:"size" bson:"size"`
+	Width        int       `json:"width" bson:"width"`
+	Height       int       `json:"height" bson:"height"`
+	CreatedAt    time.Time `json:"created_at" bson:"created_at"`
+}
+
+// ImageOrder body of an image reorder request
+type ImageOrder struct {
+	ImageIDs []string `json:"image_ids" validate:"required"`
+}
+
+// BlobStore keeps binary objects like images
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,122 @@
+package domain
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultSearchRadius in km around the near point
+	DefaultSearchRadius = 25
+
+	// EarthRadius in km
+	EarthRadius = 6378.1
+)
+
+// ErrInvalidNear returned when the near point is not "lat,lon"
+var ErrInvalidNear = errors.New("near must be lat,lon")
+
+// ErrNearRequired returned when a distance search has no near point
+var ErrNearRequired = errors.New("radius and distance sort require near")
+
+// ErrDistanceSearch returned when text search is sorted by distance
+var ErrDistanceSearch = errors.New("search cannot be sorted by distance")
+
+// ErrPickupWithoutLocation returned when a listing
+// offers pickup without telling where
+var ErrPickupWithoutLocation = errors.New("pickup requires a location")
+
+// ErrNoDeliveryOption returned when a listing
+// offers neither pickup nor shipping
+var ErrNoDeliveryOption = errors.New("pickup or shipping is required")
+
+// Location of a product for local pickup,
+// Point is kept by the usecase for the 2dsphere index
+type Location struct {
+	Lat   float64  `json:"lat" bson:"lat" validate:"latitude"`
+	Lon   float64  `json:"lon" bson:"lon" validate:"longitude"`
+	City  string   `json:"city" bson:"city" validate:"max=100"`
+	Point GeoPoint `json:"-" bson:"point"`
+}
+
+// GeoPoint is a GeoJSON point, coordinates are lon then lat
+type GeoPoint struct {
+	Type        string    `json:"type" bson:"type"`
+	Coordinates []float64 `json:"coordinates" bson:"coordinates"`
+}
+
+// NewGeoPoint returns the GeoJSON point of the coordinates
+func NewGeoPoint(lat, lon float64) GeoPoint {
+	return GeoPoint{Type: "Point", Coordinates: []float64{lon, lat}}
+}
+
+// Lat returns the latitude of the point
+func (g GeoPoint) Lat() float64 {
+	return g.Coordinates[1]
+}
+
+// Lon returns the longitude of the point
+func (g GeoPoint) Lon() float64 {
+	return g.Coordinates[0]
+}
+
+// ParseNear parses a near point given as "lat,lon"
+func ParseNear(near string) (*GeoPoint, error) {
+	parts := strings.Split(near, ",")
+	if len(parts) != 2 {
+		return nil, ErrInvalidNear
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return nil, ErrInvalidNear
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return nil, ErrInvalidNear
+	}
+	point := NewGeoPoint(lat, lon)
+	return &point, nil
+}
+
+// DistanceKm returns the great circle distance between the points
+func DistanceKm(a, b GeoPoint) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+	dLat := toRadians(b.Lat() - a.Lat())
+	dLon := toRadians(b.Lon() - a.Lon())
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(a.Lat()))*math.Cos(toRadians(b.Lat()))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EarthRadius * math.Asin(math.Sqrt(h))
+}
+
+// Delivery tells how the buyer gets the product,
+// listings without one are shipped
+type Delivery struct {
+	Pickup        bool `json:"pickup" bson:"pickup"`
+	Shipping      bool `json:"shipping" bson:"shipping"`
+	ShippingPrice int  `json:"shipping_price" bson:"shipping_price" validate:"min=0"`
+}
+
+// PrepareLocation checks the delivery options against the location of
+// the product and sets the point of the location for the index
+func PrepareLocation(product *Product) error {
+	if product.Delivery == nil {
+		product.Delivery = &Delivery{Shipping: true}
+	}
+	if !product.Delivery.Pickup && !product.Delivery.Shipping {
+		return ErrNoDeliveryOption
+	}
+	if !product.Delivery.Shipping {
+		product.Delivery.ShippingPrice = 0
+	}
+	if product.Location == nil {
+		if product.Delivery.Pickup {
+			return ErrPickupWithoutLocation
+		}
+		return nil
+	}
+	product.Location.City = strings.TrimSpace(product.Location.City)
+	product.Location.Point = NewGeoPoint(product.Location.Lat, product.Location.Lon)
+	return nil
+}
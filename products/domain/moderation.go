@@ -0,0 +1,168 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrAlreadyReported returned when the user
+// reports the same listing again
+var ErrAlreadyReported = errors.New("listing is already reported")
+
+// ErrListingModerated returned when a rejected or
+// taken down listing is being changed by its seller
+var ErrListingModerated = errors.New("listing is rejected by moderators")
+
+// ErrOwnListing returned when the seller reports their own listing
+var ErrOwnListing = errors.New("cannot report your own listing")
+
+// ErrDecisionNotAllowed returned when the decision
+// does not apply to the moderation status of the listing
+var ErrDecisionNotAllowed = errors.New("decision does not apply to the listing")
+
+// ModerationStatus tells whether buyers can see a listing
+type ModerationStatus string
+
+const (
+	// ModerationPublished listings are shown, listings
+	// created before moderation have no status and count as published
+	ModerationPublished ModerationStatus = "published"
+	ModerationPending   ModerationStatus = "pending"
+	ModerationRejected  ModerationStatus = "rejected"
+	ModerationTakenDown ModerationStatus = "taken_down"
+)
+
+// Moderation is the moderation state of a product
+type Moderation struct {
+	Status      ModerationStatus `json:"status" bson:"status"`
+	Reason      string           `json:"reason,omitempty" bson:"reason,omitempty"`
+	ModeratorID string           `json:"moderator_id,omitempty" bson:"moderator_id,omitempty"`
+	DecidedAt   *time.Time       `json:"decided_at,omitempty" bson:"decided_at,omitempty"`
+	// OpenReports counts the reports waiting for a moderator
+	OpenReports int `json:"-" bson:"open_reports"`
+}
+
+// Hidden reports whether buyers cannot see or order the product
+func (p *Product) Hidden() bool {
+	switch p.Moderation.Status {
+	case ModerationPending, ModerationRejected, ModerationTakenDown:
+		return true
+	}
+	return false
+}
+
+// Moderated reports whether moderators rejected or took down the product
+func (p *Product) Moderated() bool {
+	return p.Moderation.Status == ModerationRejected || p.Moderation.Status == ModerationTakenDown
+}
+
+// ModerationRules decide whether new listings wait for a moderator
+type ModerationRules struct {
+	// Blocklist holds lowercase keywords that hold
+	// back listings with them in the title or tags
+	Blocklist []string
+	// NewSellerThreshold is the number of published listings a
+	// seller needs before their listings are published right away
+	NewSellerThreshold int
+}
+
+// NewModerationRules returns the rules, keywords is a comma
+// separated list as it comes from env variables
+func NewModerationRules(keywords string, newSellerThreshold int) ModerationRules {
+	rules := ModerationRules{NewSellerThreshold: newSellerThreshold}
+	for _, keyword := range strings.Split(keywords, ",") {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+			rules.Blocklist = append(rules.Blocklist, keyword)
+		}
+	}
+	return rules
+}
+
+// BlockedKeyword returns the first blocked keyword found in the
+// title or tags of the product, tags must be normalized already
+func (r ModerationRules) BlockedKeyword(product *Product) (string, bool) {
+	title := strings.ToLower(product.Title)
+	for _, keyword := range r.Blocklist {
+		if strings.Contains(title, keyword) {
+			return keyword, true
+		}
+		for _, tag := range product.Tags {
+			if strings.Contains(tag, keyword) {
+				return keyword, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Evaluate returns the status of a new listing of a seller
+// with the given number of published listings
+func (r ModerationRules) Evaluate(product *Product, publishedListings int64) Moderation {
+	if keyword, found := r.BlockedKeyword(product); found {
+		return Moderation{Status: ModerationPending, Reason: "blocked keyword: " + keyword}
+	}
+	if publishedListings < int64(r.NewSellerThreshold) {
+		return Moderation{Status: ModerationPending, Reason: "new seller"}
+	}
+	return Moderation{Status: ModerationPublished}
+}
+
+// DecisionAction is what a moderator does with a listing
+type DecisionAction string
+
+const (
+	DecisionApprove  DecisionAction = "approve"
+	DecisionReject   DecisionAction = "reject"
+	DecisionTakeDown DecisionAction = "take_down"
+)
+
+// ModerationDecision holds what the moderator sends,
+// listings are only rejected or taken down with a reason
+type ModerationDecision struct {
+	Action DecisionAction `json:"action" validate:"required,oneof=approve reject take_down"`
+	Reason string         `json:"reason" validate:"required_unless=Action approve,max=500"`
+}
+
+// Apply returns the statuses the listing may have for the decision
+// and the status it gets, pending listings are rejected and
+// published ones are taken down
+func (d *ModerationDecision) Apply() (from []ModerationStatus, to ModerationStatus) {
+	switch d.Action {
+	case DecisionReject:
+		return []ModerationStatus{ModerationPending}, ModerationRejected
+	case DecisionTakeDown:
+		return []ModerationStatus{ModerationPublished, ""}, ModerationTakenDown
+	default:
+		return []ModerationStatus{ModerationPending, ModerationPublished, ModerationRejected, ModerationTakenDown, ""}, ModerationPublished
+	}
+}
+
+// Report of a listing by a user
+type Report struct {
+	ID         string     `json:"id" bson:"_id,omitempty"`
+	ProductID  string     `json:"product_id" bson:"product_id"`
+	ReporterID string     `json:"reporter_id" bson:"reporter_id"`
+	Reason     string     `json:"reason" bson:"reason"`
+	CreatedAt  time.Time  `json:"created_at" bson:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty" bson:"resolved_at"`
+}
+
+// ReportRequest holds what the user sends to report a listing
+type ReportRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// ModerationItem is a listing in the review queue with its open reports
+type ModerationItem struct {
+	Product *Product  `json:"product"`
+	Reports []*Report `json:"reports"`
+}
+
+// ReportRepository to interact db
+type ReportRepository interface {
+	Insert(ctx context.Context, report *Report) (*Report, error)
+	ListOpenByProducts(ctx context.Context, productIDs []string) ([]*Report, error)
+	ResolveByProduct(ctx context.Context, productID string, now time.Time) error
+}
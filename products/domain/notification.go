@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// NotificationKind tells what a notification is about
+type NotificationKind string
+
+const (
+	NotificationAuctionWon  NotificationKind = "auction_won"
+	NotificationAuctionLost NotificationKind = "auction_lost"
+	NotificationOffer       NotificationKind = "offer"
+	NotificationPriceDrop   NotificationKind = "price_drop"
+	NotificationSoldOut     NotificationKind = "sold_out"
+	NotificationModeration  NotificationKind = "moderation"
+)
+
+// Notification shown to a user in the app
+type Notification struct {
+	ID        string           `json:"id" bson:"_id,omitempty"`
+	UserID    string           `json:"user_id" bson:"user_id"`
+	Kind      NotificationKind `json:"kind" bson:"kind"`
+	ProductID string           `json:"product_id" bson:"product_id"`
+	Message   string           `json:"message" bson:"message"`
+	CreatedAt time.Time        `json:"created_at" bson:"created_at"`
+	ReadAt    *time.Time       `json:"read_at,omitempty" bson:"read_at"`
+}
+
+// NotificationRepository to interact db
+type NotificationRepository interface {
+	InsertMany(ctx context.Context, notifications []*Notification) error
+	ListByUser(ctx context.Context, userID string, limit int) ([]*Notification, error)
+	MarkRead(ctx context.Context, id, userID string) (*Notification, error)
+}
@@ -0,0 +1,158 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrOfferNotBelowPrice returned when the offer
+	// is not below the listed price
+	ErrOfferNotBelowPrice = errors.New("offer must be below the price")
+	// ErrOwnProduct returned when the seller makes
+	// an offer on the product
+	ErrOwnProduct = errors.New("cannot make an offer on your own product")
+	// ErrOfferExists returned when the buyer already
+	// negotiates on the product
+	ErrOfferExists = errors.New("an open offer already exists")
+	// ErrOfferClosed returned when answering an
+	// accepted, rejected or expired offer
+	ErrOfferClosed = errors.New("offer is closed")
+	// ErrNotYourTurn returned when the party who made the
+	// last proposal answers it
+	ErrNotYourTurn = errors.New("waiting for the other party")
+	// ErrOfferChanged returned when the other party
+	// answered the offer meanwhile
+	ErrOfferChanged = errors.New("offer changed meanwhile")
+	// ErrOffersNotAccepted returned when making
+	// an offer on an auction
+	ErrOffersNotAccepted = errors.New("product does not accept offers")
+)
+
+// OfferExpiry is how long a proposal waits for an answer
+const OfferExpiry = 48 * time.Hour
+
+// OfferStatus is the state of a negotiation
+type OfferStatus string
+
+const (
+	// OfferPending waits for the seller
+	OfferPending OfferStatus = "pending"
+	// OfferCountered waits for the buyer
+	OfferCountered OfferStatus = "countered"
+	OfferAccepted  OfferStatus = "accepted"
+	OfferRejected  OfferStatus = "rejected"
+	OfferExpired   OfferStatus = "expired"
+)
+
+// Proposal is an amount one party proposed
+type Proposal struct {
+	UserID    string    `json:"user_id" bson:"user_id"`
+	Amount    int       `json:"amount" bson:"amount"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+// Offer is the negotiation of a buyer with the seller of
+// a product, the amount is the price of a single item
+type Offer struct {
+	ID        string      `json:"id" bson:"_id,omitempty"`
+	ProductID string      `json:"product_id" bson:"product_id"`
+	BuyerID   string      `json:"buyer_id" bson:"buyer_id"`
+	SellerID  string      `json:"seller_id" bson:"seller_id"`
+	Amount    int         `json:"amount" bson:"amount"`
+	Quantity  int         `json:"quantity" bson:"quantity"`
+	Status    OfferStatus `json:"status" bson:"status"`
+	Proposals []Proposal  `json:"proposals" bson:"proposals"`
+	ExpiresAt time.Time   `json:"expires_at" bson:"expires_at"`
+	CreatedAt time.Time   `json:"created_at" bson:"created_at"`
+	Version   int         `json:"version" bson:"version"`
+}
+
+// IsOpen reports whether the offer waits for an answer
+func (o *Offer) IsOpen(now time.Time) bool {
+	return (o.Status == OfferPending || o.Status == OfferCountered) && now.Before(o.ExpiresAt)
+}
+
+// Expire marks open offers past their expiry as expired
+// and reports whether the status changed
+func (o *Offer) Expire(now time.Time) bool {
+	if (o.Status == OfferPending || o.Status == OfferCountered) && !now.Before(o.ExpiresAt) {
+		o.Status = OfferExpired
+		return true
+	}
+	return false
+}
+
+// AwaitingUserID returns the party who answers the last proposal
+func (o *Offer) AwaitingUserID() string {
+	if o.Status == OfferCountered {
+		return o.BuyerID
+	}
+	return o.SellerID
+}
+
+// Reopen opens the accepted offer again for the party who
+// answers the last proposal, it reports whether it was accepted
+func (o *Offer) Reopen(now time.Time) bool {
+	if o.Status != OfferAccepted {
+		return false
+	}
+	o.Status = OfferPending
+	if last := len(o.Proposals) - 1; last >= 0 && o.Proposals[last].UserID == o.SellerID {
+		o.Status = OfferCountered
+	}
+	o.ExpiresAt = now.Add(OfferExpiry)
+	return true
+}
+
+// OtherPartyID returns the buyer for the seller and the other way around
+func (o *Offer) OtherPartyID(userID string) string {
+	if userID == o.BuyerID {
+		return o.SellerID
+	}
+	return o.BuyerID
+}
+
+// OfferRequest holds a proposal of the buyer or the seller
+type OfferRequest struct {
+	Amount   int `json:"amount" validate:"min=1"`
+	Quantity int `json:"quantity" validate:"min=0"`
+}
+
+// OfferRepository to interact db
+type OfferRepository interface {
+	Insert(ctx context.Context, offer *Offer) (*Offer, error)
+	Update(ctx context.Context, offer *Offer) (*Offer, error)
+	FindByID(ctx context.Context, id string) (*Offer, error)
+	ListByProduct(ctx context.Context, productID string) ([]*Offer, error)
+	ListByUser(ctx context.Context, userID string) ([]*Offer, error)
+	HasOpenOffer(ctx context.Context, productID, buyerID string, now time.Time) (bool, error)
+}
+
+// OfferAgreed is published when the seller and
+// the buyer agree on the price
+const OfferAgreed = "offer:agreed"
+
+// OfferAgreedEvent tells orders to create
+// the order of the buyer at the agreed price
+type OfferAgreedEvent struct {
+	OfferID   string `json:"offer_id"`
+	Version   int    `json:"version"`
+	ProductID string `json:"product_id"`
+	UserID    string `json:"user_id"`
+	Amount    int    `json:"amount"`
+	Quantity  int    `json:"quantity"`
+}
+
+// OfferDeclined is published by orders when the
+// order of an agreed offer cannot be placed
+const OfferDeclined = "offer:declined"
+
+// OfferDeclinedEvent consumed to open the agreed
+// offer as of the version again
+type OfferDeclinedEvent struct {
+	OfferID string `json:"offer_id"`
+	Version int    `json:"version"`
+	Reason  string `json:"reason"`
+}
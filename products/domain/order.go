@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"context"
+
+	"github.com/halilylm/gommon/events/common/types"
+)
+
+// Order is the replica of a completed order,
+// kept to verify reviews of buyers
+type Order struct {
+	ID      string `json:"id" bson:"_id,omitempty"`
+	BuyerID string `json:"buyer_id" bson:"buyer_id"`
+	// SellerID and ProductID are the seller and the product of
+	// orders replicated before cart orders had several sellers
+	SellerID  string            `json:"seller_id" bson:"seller_id"`
+	ProductID string            `json:"product_id" bson:"product_id"`
+	Sellers   []OrderSeller     `json:"sellers" bson:"sellers"`
+	Status    types.OrderStatus `json:"status" bson:"status"`
+	Version   int               `json:"version" bson:"version"`
+}
+
+// OrderSeller is a seller of the order with its first product
+type OrderSeller struct {
+	SellerID  string `json:"seller_id" bson:"seller_id"`
+	ProductID string `json:"product_id" bson:"product_id"`
+}
+
+// FindSeller returns the seller of the order, the only
+// seller of the order when sellerID is empty
+func (o *Order) FindSeller(sellerID string) (*OrderSeller, bool) {
+	sellers := o.Sellers
+	if len(sellers) == 0 && o.SellerID != "" {
+		sellers = []OrderSeller{{SellerID: o.SellerID, ProductID: o.ProductID}}
+	}
+	if sellerID == "" {
+		if len(sellers) != 1 {
+			return nil, false
+		}
+		return &sellers[0], true
+	}
+	for i := range sellers {
+		if sellers[i].SellerID == sellerID {
+			return &sellers[i], true
+		}
+	}
+	return nil, false
+}
+
+// OrderCompleted is published by orders when the order is paid
+const OrderCompleted = "order:completed"
+
+// OrderCompletedEvent consumed to keep the order replica,
+// cart orders are completed for each of their sellers
+type OrderCompletedEvent struct {
+	ID        string `json:"id"`
+	Version   int    `json:"version"`
+	UserID    string `json:"user_id"`
+	ProductID string `json:"product_id"`
+	// SellerID is set for the sellers of cart orders
+	SellerID string `json:"seller_id,omitempty"`
+}
+
+// OrderRepository keeps the order replica
+type OrderRepository interface {
+	Upsert(ctx context.Context, order *Order) (*Order, error)
+	FindByID(ctx context.Context, id string) (*Order, error)
+}
@@ -2,16 +2,189 @@ package domain
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"time"
 )
 
+// ErrInvalidCursor returned when a listing cursor
+// is malformed or belongs to another sort order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrInvalidPriceRange returned when the
+// minimum price is above the maximum
+var ErrInvalidPriceRange = errors.New("min_price is greater than max_price")
+
+// ErrProductReserved returned when a reserved
+// or sold product is being changed by its seller
+var ErrProductReserved = errors.New("product is reserved")
+
+// ErrOutOfStock returned when the remaining
+// stock cannot cover a reservation
+var ErrOutOfStock = errors.New("not enough stock")
+
+// ErrProductUnavailable returned when an
+// ordered product does not exist
+var ErrProductUnavailable = errors.New("product is not available")
+
+// ErrQuantityBelowReserved returned when the seller
+// lowers the stock below what is already reserved
+var ErrQuantityBelowReserved = errors.New("quantity is lower than the reserved stock")
+
 // Product domain
 type Product struct {
-	ID      string  `json:"id" bson:"_id,omitempty"`
-	Title   string  `json:"title" bson:"title" validate:"required"`
-	Price   int     `json:"price" bson:"price" validate:"number"`
-	UserID  string  `json:"user_id" bson:"user_id"`
-	Version int     `json:"version,omitempty" bson:"version" validate:"number"`
-	OrderID *string `json:"order_id" bson:"order_id"`
+	ID       string `json:"id" bson:"_id,omitempty"`
+	Title    string `json:"title" bson:"title" validate:"required"`
+	Price    int    `json:"price" bson:"price" validate:"number"`
+	UserID   string `json:"user_id" bson:"user_id"`
+	Version  int    `json:"version,omitempty" bson:"version" validate:"number"`
+	Quantity int    `json:"quantity" bson:"quantity" validate:"min=0"`
+	// Available is the stock left to order,
+	// kept by the repository on reservations
+	Available    int            `json:"available" bson:"available"`
+	Reservations []Reservation  `json:"-" bson:"reservations"`
+	CreatedAt    time.Time      `json:"created_at" bson:"created_at"`
+	ArchivedAt   *time.Time     `json:"archived_at,omitempty" bson:"archived_at"`
+	CategoryID   string         `json:"category_id" bson:"category_id" validate:"required"`
+	Tags         []string       `json:"tags" bson:"tags" validate:"max=10,dive,max=30"`
+	Attributes   map[string]any `json:"attributes" bson:"attributes"`
+	Images       []Image        `json:"images" bson:"images"`
+	Listing      ListingType    `json:"listing" bson:"listing" validate:"omitempty,oneof=fixed auction"`
+	Auction      *Auction       `json:"auction,omitempty" bson:"auction,omitempty"`
+	Moderation   Moderation     `json:"moderation" bson:"moderation"`
+	Location     *Location      `json:"location,omitempty" bson:"location,omitempty"`
+	Delivery     *Delivery      `json:"delivery,omitempty" bson:"delivery,omitempty"`
+	// Distance in km from the near point of
+	// the listing, set for responses only
+	Distance *float64 `json:"distance,omitempty" bson:"distance,omitempty"`
+	// Seller is the score of the seller, set for responses only
+	Seller *SellerScore `json:"seller,omitempty" bson:"-"`
+}
+
+// IsAuction reports whether the product is sold by auction
+func (p *Product) IsAuction() bool {
+	return p.Listing == ListingAuction && p.Auction != nil
+}
+
+// Reservation holds stock for an order until it is
+// cancelled, sold reservations are kept for good
+type Reservation struct {
+	OrderID  string `json:"order_id" bson:"order_id"`
+	Quantity int    `json:"quantity" bson:"quantity"`
+	Sold     bool   `json:"sold" bson:"sold"`
+}
+
+// SoldOut reports whether no stock is left to order
+func (p *Product) SoldOut() bool {
+	return p.Available <= 0
+}
+
+// Reserved returns the stock held by orders
+func (p *Product) Reserved() int {
+	return p.Quantity - p.Available
+}
+
+// FindReservation returns the reservation of the order
+func (p *Product) FindReservation(orderID string) (*Reservation, bool) {
+	for i := range p.Reservations {
+		if p.Reservations[i].OrderID == orderID {
+			return &p.Reservations[i], true
+		}
+	}
+	return nil, false
+}
+
+// HasPendingReservations reports whether
+// any order is still waiting for payment
+func (p *Product) HasPendingReservations() bool {
+	for _, reservation := range p.Reservations {
+		if !reservation.Sold {
+			return true
+		}
+	}
+	return false
+}
+
+// FindImage returns the image of the product with the id
+func (p *Product) FindImage(id string) (*Image, bool) {
+	for i := range p.Images {
+		if p.Images[i].ID == id {
+			return &p.Images[i], true
+		}
+	}
+	return nil, false
+}
+
+// NormalizeTags lowercases tags and drops empty and repeated ones
+func NormalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if _, found := seen[tag]; tag == "" || found {
+			continue
+		}
+		seen[tag] = struct{}{}
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// ProductUpdate holds the fields a seller can change
+type ProductUpdate struct {
+	Title string `json:"title" validate:"required"`
+	Price int    `json:"price" validate:"number"`
+	// Quantity is left as it is when zero
+	Quantity   int            `json:"quantity" validate:"omitempty,min=1"`
+	CategoryID string         `json:"category_id" validate:"required"`
+	Tags       []string       `json:"tags" validate:"max=10,dive,max=30"`
+	Attributes map[string]any `json:"attributes"`
+	// Location and Delivery are left as they are when nil
+	Location *Location `json:"location"`
+	Delivery *Delivery `json:"delivery"`
+}
+
+// ProductSort orders product listings
+type ProductSort string
+
+const (
+	SortNewest    ProductSort = "newest"
+	SortPriceAsc  ProductSort = "price_asc"
+	SortPriceDesc ProductSort = "price_desc"
+	SortDistance  ProductSort = "distance"
+)
+
+// ProductQuery filters, sorts and paginates product listings
+type ProductQuery struct {
+	Search   string      `query:"q"`
+	MinPrice *int        `query:"min_price" validate:"omitempty,min=0"`
+	MaxPrice *int        `query:"max_price" validate:"omitempty,min=0"`
+	SellerID string      `query:"seller"`
+	Sort     ProductSort `query:"sort" validate:"omitempty,oneof=newest price_asc price_desc distance"`
+	Cursor   string      `query:"cursor"`
+	Limit    int         `query:"limit" validate:"omitempty,min=1,max=100"`
+	Category string      `query:"category"`
+	Tag      string      `query:"tag"`
+	// Near is the "lat,lon" to search around,
+	// Radius is in km and defaults to DefaultSearchRadius
+	Near   string  `query:"near"`
+	Radius float64 `query:"radius" validate:"omitempty,gt=0,max=500"`
+	// Attributes filters by attribute values,
+	// given as attr.<name>=<value> query parameters
+	Attributes map[string]any
+	// CategoryIDs holds the category and its
+	// descendants, resolved by the usecase
+	CategoryIDs []string
+	// Origin is the parsed near point, resolved by the usecase
+	Origin *GeoPoint
+}
+
+// ProductPage is one page of product listings,
+// NextCursor is empty on the last page
+type ProductPage struct {
+	Items         []*Product `json:"items"`
+	NextCursor    string     `json:"next_cursor,omitempty"`
+	TotalEstimate int64      `json:"total_estimate"`
 }
 
 // ProductRepository to interact db
@@ -19,5 +192,21 @@ type ProductRepository interface {
 	Insert(ctx context.Context, product *Product) (*Product, error)
 	Update(ctx context.Context, product *Product) (*Product, error)
 	FindByID(ctx context.Context, id string) (*Product, error)
-	AvailableProducts(ctx context.Context) ([]*Product, error)
+	FindByIDs(ctx context.Context, ids []string) ([]*Product, error)
+	Archive(ctx context.Context, product *Product) (*Product, error)
+	AvailableProducts(ctx context.Context, query *ProductQuery) (*ProductPage, error)
+	CountByCategory(ctx context.Context, categoryID string) (int64, error)
+	AddImage(ctx context.Context, productID string, image *Image, maxImages int) (*Product, error)
+	RemoveImage(ctx context.Context, productID, imageID string) (*Product, error)
+	ReorderImages(ctx context.Context, productID string, imageIDs []string) (*Product, error)
+	Reserve(ctx context.Context, productID string, reservation *Reservation) (*Product, error)
+	Release(ctx context.Context, productID string, reservation *Reservation) (*Product, error)
+	MarkSold(ctx context.Context, orderID string) error
+	CountPublishedBySeller(ctx context.Context, userID string) (int64, error)
+	Moderate(ctx context.Context, productID string, from []ModerationStatus, moderation *Moderation) (*Product, error)
+	AddReport(ctx context.Context, productID string) (*Product, error)
+	ModerationQueue(ctx context.Context, limit int) ([]*Product, error)
+	PlaceBid(ctx context.Context, productID string, bid *Bid, extendTo time.Time) (*Product, error)
+	DueAuctions(ctx context.Context, now time.Time, limit int) ([]*Product, error)
+	CloseAuction(ctx context.Context, productID string, status AuctionStatus, now time.Time) (*Product, error)
 }
@@ -0,0 +1,83 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrOrderNotComplete returned when reviewing
+	// an order that is not completed
+	ErrOrderNotComplete = errors.New("order is not completed")
+	// ErrAlreadyReviewed returned when the seller
+	// of the order has a review already
+	ErrAlreadyReviewed = errors.New("order is already reviewed")
+	// ErrUnknownOrderSeller returned when the seller of the review
+	// is not a seller of the order or is missing for several sellers
+	ErrUnknownOrderSeller = errors.New("seller_id is not a seller of the order")
+)
+
+// Review of a buyer on the seller of a completed order
+type Review struct {
+	ID        string          `json:"id" bson:"_id,omitempty"`
+	OrderID   string          `json:"order_id" bson:"order_id"`
+	ProductID string          `json:"product_id" bson:"product_id"`
+	SellerID  string          `json:"seller_id" bson:"seller_id"`
+	BuyerID   string          `json:"buyer_id" bson:"buyer_id"`
+	Rating    int             `json:"rating" bson:"rating"`
+	Comment   string          `json:"comment" bson:"comment"`
+	Response  *ReviewResponse `json:"response,omitempty" bson:"response"`
+	CreatedAt time.Time       `json:"created_at" bson:"created_at"`
+}
+
+// ReviewResponse is the answer of the seller to a review
+type ReviewResponse struct {
+	Comment   string    `json:"comment" bson:"comment" validate:"required,max=1000"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+// ReviewRequest holds the review of the buyer
+type ReviewRequest struct {
+	OrderID string `json:"order_id" validate:"required"`
+	// SellerID is required for orders of several sellers
+	SellerID string `json:"seller_id"`
+	Rating   int    `json:"rating" validate:"min=1,max=5"`
+	Comment  string `json:"comment" validate:"max=1000"`
+}
+
+// SellerScore aggregates the ratings of a seller
+type SellerScore struct {
+	SellerID    string  `json:"seller_id" bson:"_id"`
+	Average     float64 `json:"average" bson:"-"`
+	ReviewCount int     `json:"review_count" bson:"review_count"`
+	RatingTotal int     `json:"-" bson:"rating_total"`
+}
+
+// Compute sets the average from the totals
+func (s *SellerScore) Compute() {
+	if s.ReviewCount > 0 {
+		s.Average = float64(s.RatingTotal) / float64(s.ReviewCount)
+	}
+}
+
+// SellerProfile is the public reputation of a seller
+type SellerProfile struct {
+	SellerScore
+	Reviews []*Review `json:"reviews"`
+}
+
+// ReviewRepository to interact db
+type ReviewRepository interface {
+	Insert(ctx context.Context, review *Review) (*Review, error)
+	FindByID(ctx context.Context, id string) (*Review, error)
+	Respond(ctx context.Context, id string, response *ReviewResponse) (*Review, error)
+	ListBySeller(ctx context.Context, sellerID string, limit int) ([]*Review, error)
+}
+
+// SellerRepository keeps the aggregated scores of sellers
+type SellerRepository interface {
+	AddRating(ctx context.Context, sellerID string, rating int) error
+	FindScore(ctx context.Context, sellerID string) (*SellerScore, error)
+	FindScores(ctx context.Context, sellerIDs []string) (map[string]*SellerScore, error)
+}
@@ -0,0 +1,29 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// WatchlistItem is a product a user saved for later
+type WatchlistItem struct {
+	ID        string    `json:"id" bson:"_id,omitempty"`
+	UserID    string    `json:"user_id" bson:"user_id"`
+	ProductID string    `json:"product_id" bson:"product_id"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+// WatchedProduct is a watchlist item with its product,
+// products taken down meanwhile have no product
+type WatchedProduct struct {
+	WatchlistItem `bson:",inline"`
+	Product       *Product `json:"product,omitempty"`
+}
+
+// WatchlistRepository to interact db
+type WatchlistRepository interface {
+	Add(ctx context.Context, item *WatchlistItem) (*WatchlistItem, error)
+	Remove(ctx context.Context, userID, productID string) error
+	ListByUser(ctx context.Context, userID string) ([]*WatchlistItem, error)
+	WatcherIDs(ctx context.Context, productID string) ([]string, error)
+}
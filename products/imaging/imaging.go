@@ -0,0 +1,141 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+)
+
+// ErrUnsupported returned for anything but jpeg, png and gif
+var ErrUnsupported = errors.New("unsupported image type")
+
+// ErrTooLarge returned for images with too many pixels,
+// decoding them would use too much memory
+var ErrTooLarge = errors.New("image dimensions are too large")
+
+const (
+	// ThumbnailSize is the longest side of thumbnails
+	ThumbnailSize = 320
+
+	// maxPixels limits decoded image size
+	maxPixels = 40_000_000
+
+	// ThumbnailContentType thumbnails are always jpeg
+	ThumbnailContentType = "image/jpeg"
+)
+
+var allowedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// Processed image ready to be stored
+type Processed struct {
+	ContentType string
+	Width       int
+	Height      int
+	Thumbnail   []byte
+}
+
+// Process sniffs the content type of the uploaded data,
+// ignoring whatever the client claims, and generates a thumbnail
+func Process(data []byte) (*Processed, error) {
+	contentType := http.DetectContentType(data)
+	if !allowedTypes[contentType] {
+		return nil, ErrUnsupported
+	}
+
+	// check dimensions before decoding the whole image
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+
+	thumbnail, err := Thumbnail(img, ThumbnailSize)
+	if err != nil {
+		return nil, err
+	}
+	return &Processed{
+		ContentType: contentType,
+		Width:       config.Width,
+		Height:      config.Height,
+		Thumbnail:   thumbnail,
+	}, nil
+}
+
+// Thumbnail scales the image to fit in size x size keeping the
+// aspect ratio and encodes it as jpeg on a white background
+func Thumbnail(img image.Image, size int) ([]byte, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > size || height > size {
+		if width >= height {
+			height = maxInt(1, height*size/width)
+			width = size
+		} else {
+			width = maxInt(1, width*size/height)
+			height = size
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scale(img, width, height), &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// scale averages every source pixel falling into a destination
+// pixel, which is good enough for downscaling photos,
+// transparent areas are blended onto white
+func scale(src image.Image, width, height int) *image.RGBA {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := maxInt(y0+1, bounds.Min.Y+(y+1)*bounds.Dy()/height)
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := maxInt(x0+1, bounds.Min.X+(x+1)*bounds.Dx()/width)
+			var r, g, b, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					// premultiplied, so adding the missing
+					// alpha as white blends onto white
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r += uint64(cr + 0xffff - ca)
+					g += uint64(cg + 0xffff - ca)
+					b += uint64(cb + 0xffff - ca)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(b / n >> 8),
+				A: 0xff,
+			})
+		}
+	}
+	return dst
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,90 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/halilylm/gommon/middlewares"
+	"github.com/halilylm/gommon/rest"
+	"github.com/halilylm/gommon/utils"
+	"github.com/halilylm/secondhand/messaging/roles"
+	"github.com/halilylm/secondhand/product/domain"
+	"github.com/halilylm/secondhand/product/moderation/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+type moderationHandler struct {
+	moderationUC usecase.Moderation
+}
+
+// NewModerationHandler handler for reports and the review queue,
+// the group must already use the jwt middleware
+func NewModerationHandler(g *echo.Group, moderationUC usecase.Moderation, appRoles *roles.Roles) {
+	handler := &moderationHandler{moderationUC: moderationUC}
+
+	g.POST("/:id/reports", handler.ReportProduct)
+
+	// the review queue is worked by moderators
+	moderator := appRoles.Require(roles.Moderator)
+	g.GET("/moderation/queue", handler.ReviewQueue, moderator)
+	g.POST("/moderation/:id/decision", handler.Decide, moderator)
+}
+
+func (h *moderationHandler) ReportProduct(c echo.Context) error {
+	var request domain.ReportRequest
+
+	// bind request body to report request
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewBadRequestError(err.Error())))
+	}
+
+	// validate the struct
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewValidationErrors(err)))
+	}
+
+	// get user from the context
+	user := middlewares.UserFromContext(c)
+
+	// call the usecase
+	createdReport, err := h.moderationUC.ReportProduct(c.Request().Context(), c.Param("id"), user.ID, &request)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusCreated, createdReport)
+}
+
+func (h *moderationHandler) ReviewQueue(c echo.Context) error {
+	// call the usecase
+	items, err := h.moderationUC.ReviewQueue(c.Request().Context())
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, items)
+}
+
+func (h *moderationHandler) Decide(c echo.Context) error {
+	var decision domain.ModerationDecision
+
+	// bind request body to decision
+	if err := c.Bind(&decision); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewBadRequestError(err.Error())))
+	}
+
+	// validate the struct
+	if err := utils.ValidateStruct(&decision); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewValidationErrors(err)))
+	}
+
+	// get user from the context
+	user := middlewares.UserFromContext(c)
+
+	// call the usecase
+	moderatedProduct, err := h.moderationUC.Decide(c.Request().Context(), c.Param("id"), user.ID, &decision)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, moderatedProduct)
+}
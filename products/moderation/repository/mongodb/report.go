@@ -0,0 +1,79 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/halilylm/secondhand/product/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type reportRepository struct {
+	collection *mongo.Collection
+}
+
+// NewReportRepository returns a new mongo report repository
+func NewReportRepository(collection *mongo.Collection) domain.ReportRepository {
+	return &reportRepository{collection: collection}
+}
+
+// CreateReportIndexes creates the indexes reports rely on,
+// a user reports a listing once
+func CreateReportIndexes(ctx context.Context, collection *mongo.Collection) error {
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "product_id", Value: 1}, {Key: "reporter_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "product_id", Value: 1}, {Key: "resolved_at", Value: 1}}},
+	})
+	return err
+}
+
+// Insert creates a new report in mongodb
+func (r *reportRepository) Insert(ctx context.Context, report *domain.Report) (*domain.Report, error) {
+	report.ID = uuid.NewString()
+	if _, err := r.collection.InsertOne(ctx, report); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, domain.ErrAlreadyReported
+		}
+		return nil, err
+	}
+	return report, nil
+}
+
+// ListOpenByProducts returns the unresolved reports of the products
+func (r *reportRepository) ListOpenByProducts(ctx context.Context, productIDs []string) ([]*domain.Report, error) {
+	reports := make([]*domain.Report, 0)
+	cur, err := r.collection.Find(ctx, bson.M{
+		"product_id":  bson.M{"$in": productIDs},
+		"resolved_at": nil,
+	}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var report domain.Report
+		if err := cur.Decode(&report); err != nil {
+			return nil, err
+		}
+		reports = append(reports, &report)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// ResolveByProduct resolves the open reports of the product
+func (r *reportRepository) ResolveByProduct(ctx context.Context, productID string, now time.Time) error {
+	_, err := r.collection.UpdateMany(ctx, bson.M{
+		"product_id":  productID,
+		"resolved_at": nil,
+	}, bson.M{"$set": bson.M{"resolved_at": now}})
+	return err
+}
@@ -0,0 +1,223 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/halilylm/gommon/events/common/messages"
+	"github.com/halilylm/gommon/logger"
+	"github.com/halilylm/gommon/rest"
+	"github.com/halilylm/secondhand/messaging/outbox"
+	"github.com/halilylm/secondhand/product/domain"
+	_notificationUC "github.com/halilylm/secondhand/product/notification/usecase"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// queueLimit caps the listings returned in the review queue
+const queueLimit = 50
+
+type moderation struct {
+	productRepo    domain.ProductRepository
+	reportRepo     domain.ReportRepository
+	historyRepo    domain.HistoryRepository
+	notificationUC _notificationUC.Notification
+	logger         logger.Logger
+	outbox         outbox.Store
+}
+
+// NewModeration returns moderation usecase
+func NewModeration(
+	productRepo domain.ProductRepository,
+	reportRepo domain.ReportRepository,
+	historyRepo domain.HistoryRepository,
+	notificationUC _notificationUC.Notification,
+	logger logger.Logger,
+	outbox outbox.Store,
+) Moderation {
+	return &moderation{
+		productRepo:    productRepo,
+		reportRepo:     reportRepo,
+		historyRepo:    historyRepo,
+		notificationUC: notificationUC,
+		logger:         logger,
+		outbox:         outbox,
+	}
+}
+
+// ReportProduct puts the published product in the review queue
+func (m *moderation) ReportProduct(ctx context.Context, productID, reporterID string, request *domain.ReportRequest) (*domain.Report, error) {
+	// find the product
+	foundProduct, err := m.findProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if foundProduct.ArchivedAt != nil || foundProduct.Hidden() {
+		return nil, rest.NewNotFoundError()
+	}
+	if foundProduct.UserID == reporterID {
+		return nil, rest.NewBadRequestError(domain.ErrOwnListing.Error())
+	}
+
+	createdReport, err := m.reportRepo.Insert(ctx, &domain.Report{
+		ProductID:  productID,
+		ReporterID: reporterID,
+		Reason:     request.Reason,
+		CreatedAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		if err == domain.ErrAlreadyReported {
+			return nil, rest.NewBadRequestError(err.Error())
+		}
+		m.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+
+	// the report is stored, a product hidden meanwhile
+	// has been taken care of by a moderator
+	if _, err := m.productRepo.AddReport(ctx, productID); err != nil && err != mongo.ErrNoDocuments {
+		m.logger.Error(err)
+	}
+	return createdReport, nil
+}
+
+// ReviewQueue returns the pending and reported listings
+// with their open reports, oldest first
+func (m *moderation) ReviewQueue(ctx context.Context) ([]*domain.ModerationItem, error) {
+	products, err := m.productRepo.ModerationQueue(ctx, queueLimit)
+	if err != nil {
+		m.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	if len(products) == 0 {
+		return []*domain.ModerationItem{}, nil
+	}
+
+	// attach the reports
+	productIDs := make([]string, 0, len(products))
+	for _, product := range products {
+		productIDs = append(productIDs, product.ID)
+	}
+	reports, err := m.reportRepo.ListOpenByProducts(ctx, productIDs)
+	if err != nil {
+		m.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	reportsByProduct := make(map[string][]*domain.Report, len(products))
+	for _, report := range reports {
+		reportsByProduct[report.ProductID] = append(reportsByProduct[report.ProductID], report)
+	}
+
+	items := make([]*domain.ModerationItem, 0, len(products))
+	for _, product := range products {
+		productReports := reportsByProduct[product.ID]
+		if productReports == nil {
+			productReports = []*domain.Report{}
+		}
+		items = append(items, &domain.ModerationItem{
+			Product: product,
+			Reports: productReports,
+		})
+	}
+	return items, nil
+}
+
+// Decide applies the decision of the moderator to the listing,
+// the seller is notified and replicas learn whether it can be ordered
+func (m *moderation) Decide(ctx context.Context, productID, moderatorID string, decision *domain.ModerationDecision) (*domain.Product, error) {
+	// find the product
+	foundProduct, err := m.findProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if foundProduct.ArchivedAt != nil {
+		return nil, rest.NewNotFoundError()
+	}
+
+	now := time.Now().UTC()
+	from, to := decision.Apply()
+	var moderatedProduct *domain.Product
+	err = m.outbox.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		moderatedProduct, err = m.productRepo.Moderate(ctx, productID, from, &domain.Moderation{
+			Status:      to,
+			Reason:      decision.Reason,
+			ModeratorID: moderatorID,
+			DecidedAt:   &now,
+		})
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return rest.NewBadRequestError(domain.ErrDecisionNotAllowed.Error())
+			}
+			m.logger.Error(err)
+			return rest.NewInternalServerError()
+		}
+		return m.publishUpdated(ctx, moderatedProduct)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the decision covers the open reports
+	if err := m.reportRepo.ResolveByProduct(ctx, productID, now); err != nil {
+		m.logger.Error(err)
+	}
+
+	// moderation only changes its own state so the
+	// previous version is derived from the product
+	previous := *moderatedProduct
+	previous.Moderation = foundProduct.Moderation
+	previous.Version--
+	revision := domain.NewProductRevision(&previous, moderatedProduct, moderatorID)
+	if err := m.historyRepo.Insert(ctx, revision); err != nil {
+		m.logger.Error(err)
+	}
+
+	m.notifySeller(ctx, moderatedProduct, decision)
+	return moderatedProduct, nil
+}
+
+func (m *moderation) findProduct(ctx context.Context, id string) (*domain.Product, error) {
+	foundProduct, err := m.productRepo.FindByID(ctx, id)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, rest.NewNotFoundError()
+		}
+		m.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	return foundProduct, nil
+}
+
+// publishUpdated tells replicas about the new version of the product,
+// it must be called in the transaction of the decision
+func (m *moderation) publishUpdated(ctx context.Context, product *domain.Product) error {
+	if err := m.outbox.Add(ctx, messages.ProductUpdated, domain.NewProductUpdatedEvent(product)); err != nil {
+		m.logger.Error(err)
+		return rest.NewInternalServerError()
+	}
+	return nil
+}
+
+// notifySeller tells the seller about the decision,
+// approvals of reported listings need no notice
+func (m *moderation) notifySeller(ctx context.Context, product *domain.Product, decision *domain.ModerationDecision) {
+	var message string
+	switch decision.Action {
+	case domain.DecisionReject:
+		message = fmt.Sprintf("%s was rejected: %s", product.Title, decision.Reason)
+	case domain.DecisionTakeDown:
+		message = fmt.Sprintf("%s was taken down: %s", product.Title, decision.Reason)
+	default:
+		message = fmt.Sprintf("%s is published", product.Title)
+	}
+	if err := m.notificationUC.Notify(ctx, []string{product.UserID}, domain.NotificationModeration, product.ID, message); err != nil {
+		m.logger.Error(err)
+	}
+}
+
+type Moderation interface {
+	ReportProduct(ctx context.Context, productID, reporterID string, request *domain.ReportRequest) (*domain.Report, error)
+	ReviewQueue(ctx context.Context) ([]*domain.ModerationItem, error)
+	Decide(ctx context.Context, productID, moderatorID string, decision *domain.ModerationDecision) (*domain.Product, error)
+}
@@ -0,0 +1,49 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/halilylm/gommon/middlewares"
+	"github.com/halilylm/gommon/rest"
+	"github.com/halilylm/secondhand/product/notification/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+type notificationHandler struct {
+	notificationUC usecase.Notification
+}
+
+// NewNotificationHandler handler for notifications,
+// the group must already use the jwt middleware
+func NewNotificationHandler(g *echo.Group, notificationUC usecase.Notification) {
+	handler := &notificationHandler{notificationUC: notificationUC}
+
+	g.GET("/", handler.ListNotifications)
+	g.PUT("/:id/read", handler.ReadNotification)
+}
+
+func (h *notificationHandler) ListNotifications(c echo.Context) error {
+	// get user from the context
+	user := middlewares.UserFromContext(c)
+
+	// call the usecase
+	notifications, err := h.notificationUC.ListNotifications(c.Request().Context(), user.ID)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, notifications)
+}
+
+func (h *notificationHandler) ReadNotification(c echo.Context) error {
+	// get user from the context
+	user := middlewares.UserFromContext(c)
+
+	// call the usecase
+	readNotification, err := h.notificationUC.ReadNotification(c.Request().Context(), c.Param("id"), user.ID)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, readNotification)
+}
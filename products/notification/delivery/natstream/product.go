@@ -0,0 +1,54 @@
+package natstream
+
+import (
+	"context"
+	"fmt"
+	"github.com/halilylm/secondhand/messaging/consumer"
+	"github.com/halilylm/secondhand/messaging/inbox"
+	"github.com/halilylm/secondhand/product/domain"
+	"github.com/halilylm/secondhand/product/notification/usecase"
+)
+
+// ProductConsumerGroup fans product events out to watchers
+type ProductConsumerGroup struct {
+	notificationUC usecase.Notification
+	inbox          inbox.Store
+	groupID        string
+}
+
+func NewProductConsumerGroup(
+	notificationUC usecase.Notification,
+	inbox inbox.Store,
+	groupID string,
+) *ProductConsumerGroup {
+	return &ProductConsumerGroup{
+		notificationUC: notificationUC,
+		inbox:          inbox,
+		groupID:        groupID,
+	}
+}
+
+func (pcg *ProductConsumerGroup) GroupID() string {
+	return pcg.groupID
+}
+
+func (pcg *ProductConsumerGroup) Subscriptions() []consumer.Subscription {
+	return []consumer.Subscription{
+		consumer.On(domain.ProductPriceDropped, pcg.priceDrop),
+		consumer.On(domain.ProductSoldOut, pcg.soldOutProduct),
+	}
+}
+
+func (pcg *ProductConsumerGroup) priceDrop(ctx context.Context, eventID string, deliveredEvent *domain.ProductPriceDroppedEvent) error {
+	message := fmt.Sprintf("%s dropped from %d to %d", deliveredEvent.Title, deliveredEvent.OldPrice, deliveredEvent.Price)
+	return pcg.inbox.Process(ctx, pcg.groupID, eventID, func(ctx context.Context) error {
+		return pcg.notificationUC.NotifyWatchers(ctx, deliveredEvent.ID, domain.NotificationPriceDrop, message)
+	})
+}
+
+func (pcg *ProductConsumerGroup) soldOutProduct(ctx context.Context, eventID string, deliveredEvent *domain.ProductSoldOutEvent) error {
+	message := fmt.Sprintf("%s was sold", deliveredEvent.Title)
+	return pcg.inbox.Process(ctx, pcg.groupID, eventID, func(ctx context.Context) error {
+		return pcg.notificationUC.NotifyWatchers(ctx, deliveredEvent.ID, domain.NotificationSoldOut, message)
+	})
+}
@@ -0,0 +1,84 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/halilylm/secondhand/product/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type notificationRepository struct {
+	collection *mongo.Collection
+}
+
+// NewNotificationRepository returns a new mongo notification repository
+func NewNotificationRepository(collection *mongo.Collection) domain.NotificationRepository {
+	return &notificationRepository{collection: collection}
+}
+
+// CreateNotificationIndexes creates the indexes notification lists rely on
+func CreateNotificationIndexes(ctx context.Context, collection *mongo.Collection) error {
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+	})
+	return err
+}
+
+// InsertMany creates the notifications in mongodb
+func (n *notificationRepository) InsertMany(ctx context.Context, notifications []*domain.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	documents := make([]any, 0, len(notifications))
+	for _, notification := range notifications {
+		notification.ID = uuid.NewString()
+		documents = append(documents, notification)
+	}
+	_, err := n.collection.InsertMany(ctx, documents)
+	return err
+}
+
+// ListByUser returns the latest notifications of the user
+func (n *notificationRepository) ListByUser(ctx context.Context, userID string, limit int) ([]*domain.Notification, error) {
+	notifications := make([]*domain.Notification, 0)
+	cur, err := n.collection.Find(ctx, bson.M{
+		"user_id": userID,
+	}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var notification domain.Notification
+		if err := cur.Decode(&notification); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, &notification)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// MarkRead marks the notification of the user as read
+func (n *notificationRepository) MarkRead(ctx context.Context, id, userID string) (*domain.Notification, error) {
+	var readNotification domain.Notification
+	res := n.collection.FindOneAndUpdate(ctx, bson.M{
+		"_id":     id,
+		"user_id": userID,
+	}, bson.M{"$set": bson.M{
+		"read_at": time.Now().UTC(),
+	}}, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&readNotification); err != nil {
+		return nil, err
+	}
+	return &readNotification, nil
+}
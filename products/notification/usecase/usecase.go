@@ -0,0 +1,86 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/halilylm/gommon/logger"
+	"github.com/halilylm/gommon/rest"
+	"github.com/halilylm/secondhand/product/domain"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// listLimit caps the notifications returned to a user
+const listLimit = 50
+
+type notification struct {
+	notificationRepo domain.NotificationRepository
+	watchlistRepo    domain.WatchlistRepository
+	logger           logger.Logger
+}
+
+// NewNotification returns notification usecase
+func NewNotification(notificationRepo domain.NotificationRepository, watchlistRepo domain.WatchlistRepository, logger logger.Logger) Notification {
+	return &notification{notificationRepo: notificationRepo, watchlistRepo: watchlistRepo, logger: logger}
+}
+
+// Notify sends the same notification to each of the users
+func (n *notification) Notify(ctx context.Context, userIDs []string, kind domain.NotificationKind, productID, message string) error {
+	now := time.Now().UTC()
+	notifications := make([]*domain.Notification, 0, len(userIDs))
+	for _, userID := range userIDs {
+		notifications = append(notifications, &domain.Notification{
+			UserID:    userID,
+			Kind:      kind,
+			ProductID: productID,
+			Message:   message,
+			CreatedAt: now,
+		})
+	}
+	if err := n.notificationRepo.InsertMany(ctx, notifications); err != nil {
+		n.logger.Error(err)
+		return rest.NewInternalServerError()
+	}
+	return nil
+}
+
+// NotifyWatchers sends the notification to
+// everyone watching the product
+func (n *notification) NotifyWatchers(ctx context.Context, productID string, kind domain.NotificationKind, message string) error {
+	watcherIDs, err := n.watchlistRepo.WatcherIDs(ctx, productID)
+	if err != nil {
+		n.logger.Error(err)
+		return rest.NewInternalServerError()
+	}
+	return n.Notify(ctx, watcherIDs, kind, productID, message)
+}
+
+// ListNotifications returns the latest notifications of the user
+func (n *notification) ListNotifications(ctx context.Context, userID string) ([]*domain.Notification, error) {
+	notifications, err := n.notificationRepo.ListByUser(ctx, userID, listLimit)
+	if err != nil {
+		n.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	return notifications, nil
+}
+
+// ReadNotification marks the notification of the user as read
+func (n *notification) ReadNotification(ctx context.Context, id, userID string) (*domain.Notification, error) {
+	readNotification, err := n.notificationRepo.MarkRead(ctx, id, userID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, rest.NewNotFoundError()
+		}
+		n.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	return readNotification, nil
+}
+
+type Notification interface {
+	Notify(ctx context.Context, userIDs []string, kind domain.NotificationKind, productID, message string) error
+	NotifyWatchers(ctx context.Context, productID string, kind domain.NotificationKind, message string) error
+	ListNotifications(ctx context.Context, userID string) ([]*domain.Notification, error)
+	ReadNotification(ctx context.Context, id, userID string) (*domain.Notification, error)
+}
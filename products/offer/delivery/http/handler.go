@@ -0,0 +1,145 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/halilylm/gommon/middlewares"
+	"github.com/halilylm/gommon/rest"
+	"github.com/halilylm/gommon/utils"
+	"github.com/halilylm/secondhand/product/domain"
+	"github.com/halilylm/secondhand/product/offer/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+type offerHandler struct {
+	offerUC usecase.Offer
+}
+
+// NewOfferHandler handler for offers on products,
+// the group must already use the jwt middleware
+func NewOfferHandler(g *echo.Group, offerUC usecase.Offer) {
+	handler := &offerHandler{offerUC: offerUC}
+
+	g.POST("/:id/offers", handler.MakeOffer)
+	g.GET("/:id/offers", handler.ListProductOffers)
+	g.GET("/offers", handler.ListUserOffers)
+	g.GET("/offers/:offer_id", handler.ShowOffer)
+	g.POST("/offers/:offer_id/counter", handler.CounterOffer)
+	g.POST("/offers/:offer_id/accept", handler.AcceptOffer)
+	g.POST("/offers/:offer_id/reject", handler.RejectOffer)
+}
+
+func (h *offerHandler) MakeOffer(c echo.Context) error {
+	var request domain.OfferRequest
+
+	// bind request body to offer request
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewBadRequestError(err.Error())))
+	}
+
+	// validate the struct
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewValidationErrors(err)))
+	}
+
+	// get user from the context
+	user := middlewares.UserFromContext(c)
+
+	// call the usecase
+	createdOffer, err := h.offerUC.MakeOffer(c.Request().Context(), c.Param("id"), user.ID, &request)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusCreated, createdOffer)
+}
+
+func (h *offerHandler) CounterOffer(c echo.Context) error {
+	var request domain.OfferRequest
+
+	// bind request body to offer request
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewBadRequestError(err.Error())))
+	}
+
+	// validate the struct
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewValidationErrors(err)))
+	}
+
+	// get user from the context
+	user := middlewares.UserFromContext(c)
+
+	// call the usecase
+	updatedOffer, err := h.offerUC.CounterOffer(c.Request().Context(), c.Param("offer_id"), user.ID, request.Amount)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, updatedOffer)
+}
+
+func (h *offerHandler) AcceptOffer(c echo.Context) error {
+	// get user from the context
+	user := middlewares.UserFromContext(c)
+
+	// call the usecase
+	updatedOffer, err := h.offerUC.AcceptOffer(c.Request().Context(), c.Param("offer_id"), user.ID)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, updatedOffer)
+}
+
+func (h *offerHandler) RejectOffer(c echo.Context) error {
+	// get user from the context
+	user := middlewares.UserFromContext(c)
+
+	// call the usecase
+	updatedOffer, err := h.offerUC.RejectOffer(c.Request().Context(), c.Param("offer_id"), user.ID)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, updatedOffer)
+}
+
+func (h *offerHandler) ShowOffer(c echo.Context) error {
+	// get user from the context
+	user := middlewares.UserFromContext(c)
+
+	// call the usecase
+	foundOffer, err := h.offerUC.ShowOffer(c.Request().Context(), c.Param("offer_id"), user.ID)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, foundOffer)
+}
+
+func (h *offerHandler) ListProductOffers(c echo.Context) error {
+	// get user from the context
+	user := middlewares.UserFromContext(c)
+
+	// call the usecase
+	offers, err := h.offerUC.ListProductOffers(c.Request().Context(), c.Param("id"), user.ID)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, offers)
+}
+
+func (h *offerHandler) ListUserOffers(c echo.Context) error {
+	// get user from the context
+	user := middlewares.UserFromContext(c)
+
+	// call the usecase
+	offers, err := h.offerUC.ListUserOffers(c.Request().Context(), user.ID)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, offers)
+}
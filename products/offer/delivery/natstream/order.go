@@ -0,0 +1,44 @@
+package natstream
+
+import (
+	"context"
+	"github.com/halilylm/secondhand/messaging/consumer"
+	"github.com/halilylm/secondhand/messaging/inbox"
+	"github.com/halilylm/secondhand/product/domain"
+	"github.com/halilylm/secondhand/product/offer/usecase"
+)
+
+// OrderConsumerGroup opens the offers orders declined again
+type OrderConsumerGroup struct {
+	offerUC usecase.Offer
+	inbox   inbox.Store
+	groupID string
+}
+
+func NewOrderConsumerGroup(
+	offerUC usecase.Offer,
+	inbox inbox.Store,
+	groupID string,
+) *OrderConsumerGroup {
+	return &OrderConsumerGroup{
+		offerUC: offerUC,
+		inbox:   inbox,
+		groupID: groupID,
+	}
+}
+
+func (ocg *OrderConsumerGroup) GroupID() string {
+	return ocg.groupID
+}
+
+func (ocg *OrderConsumerGroup) Subscriptions() []consumer.Subscription {
+	return []consumer.Subscription{
+		consumer.On(domain.OfferDeclined, ocg.declinedOffer),
+	}
+}
+
+func (ocg *OrderConsumerGroup) declinedOffer(ctx context.Context, eventID string, deliveredEvent *domain.OfferDeclinedEvent) error {
+	return ocg.inbox.Process(ctx, ocg.groupID, eventID, func(ctx context.Context) error {
+		return ocg.offerUC.ReopenOffer(ctx, deliveredEvent.OfferID, deliveredEvent.Version, deliveredEvent.Reason)
+	})
+}
@@ -0,0 +1,122 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/halilylm/secondhand/product/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type offerRepository struct {
+	collection *mongo.Collection
+}
+
+// NewOfferRepository returns a new mongo offer repository
+func NewOfferRepository(collection *mongo.Collection) domain.OfferRepository {
+	return &offerRepository{collection: collection}
+}
+
+// CreateOfferIndexes creates the indexes offer lists rely on
+func CreateOfferIndexes(ctx context.Context, collection *mongo.Collection) error {
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "product_id", Value: 1}, {Key: "buyer_id", Value: 1}, {Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "buyer_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "seller_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+	return err
+}
+
+// Insert creates a new offer in mongodb
+func (o *offerRepository) Insert(ctx context.Context, offer *domain.Offer) (*domain.Offer, error) {
+	offer.ID = uuid.NewString()
+	if _, err := o.collection.InsertOne(ctx, offer); err != nil {
+		return nil, err
+	}
+	return offer, nil
+}
+
+// Update saves the negotiation if nobody answered meanwhile
+func (o *offerRepository) Update(ctx context.Context, offer *domain.Offer) (*domain.Offer, error) {
+	var updatedOffer domain.Offer
+	res := o.collection.FindOneAndUpdate(ctx, bson.M{
+		"_id":     offer.ID,
+		"version": offer.Version,
+	}, bson.M{"$set": map[string]any{
+		"amount":     offer.Amount,
+		"status":     offer.Status,
+		"proposals":  offer.Proposals,
+		"expires_at": offer.ExpiresAt,
+		"version":    offer.Version + 1,
+	}}, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&updatedOffer); err != nil {
+		return nil, err
+	}
+	return &updatedOffer, nil
+}
+
+// FindByID finds an offer by its id
+func (o *offerRepository) FindByID(ctx context.Context, id string) (*domain.Offer, error) {
+	var foundOffer domain.Offer
+	res := o.collection.FindOne(ctx, bson.M{"_id": id})
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&foundOffer); err != nil {
+		return nil, err
+	}
+	return &foundOffer, nil
+}
+
+// ListByProduct returns the offers on the product, newest first
+func (o *offerRepository) ListByProduct(ctx context.Context, productID string) ([]*domain.Offer, error) {
+	return o.find(ctx, bson.M{"product_id": productID})
+}
+
+// ListByUser returns the offers the user made or received, newest first
+func (o *offerRepository) ListByUser(ctx context.Context, userID string) ([]*domain.Offer, error) {
+	return o.find(ctx, bson.M{"$or": bson.A{
+		bson.M{"buyer_id": userID},
+		bson.M{"seller_id": userID},
+	}})
+}
+
+// HasOpenOffer reports whether the buyer still negotiates on the product
+func (o *offerRepository) HasOpenOffer(ctx context.Context, productID, buyerID string, now time.Time) (bool, error) {
+	count, err := o.collection.CountDocuments(ctx, bson.M{
+		"product_id": productID,
+		"buyer_id":   buyerID,
+		"status":     bson.M{"$in": bson.A{domain.OfferPending, domain.OfferCountered}},
+		"expires_at": bson.M{"$gt": now},
+	})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (o *offerRepository) find(ctx context.Context, filter bson.M) ([]*domain.Offer, error) {
+	offers := make([]*domain.Offer, 0)
+	cur, err := o.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var offer domain.Offer
+		if err := cur.Decode(&offer); err != nil {
+			return nil, err
+		}
+		offers = append(offers, &offer)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return offers, nil
+}
@@ -0,0 +1,353 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/halilylm/gommon/logger"
+	"github.com/halilylm/gommon/rest"
+	"github.com/halilylm/secondhand/messaging/outbox"
+	"github.com/halilylm/secondhand/product/domain"
+	_notificationUC "github.com/halilylm/secondhand/product/notification/usecase"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type offer struct {
+	offerRepo      domain.OfferRepository
+	productRepo    domain.ProductRepository
+	notificationUC _notificationUC.Notification
+	logger         logger.Logger
+	outbox         outbox.Store
+}
+
+// NewOffer returns offer usecase
+func NewOffer(
+	offerRepo domain.OfferRepository,
+	productRepo domain.ProductRepository,
+	notificationUC _notificationUC.Notification,
+	logger logger.Logger,
+	outbox outbox.Store,
+) Offer {
+	return &offer{
+		offerRepo:      offerRepo,
+		productRepo:    productRepo,
+		notificationUC: notificationUC,
+		logger:         logger,
+		outbox:         outbox,
+	}
+}
+
+// MakeOffer starts a negotiation of the buyer on the product
+func (o *offer) MakeOffer(ctx context.Context, productID, buyerID string, request *domain.OfferRequest) (*domain.Offer, error) {
+	// a single item unless told otherwise
+	quantity := request.Quantity
+	if quantity == 0 {
+		quantity = 1
+	}
+
+	// find the product
+	product, err := o.findProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if product.IsAuction() {
+		return nil, rest.NewBadRequestError(domain.ErrOffersNotAccepted.Error())
+	}
+	if product.UserID == buyerID {
+		return nil, rest.NewBadRequestError(domain.ErrOwnProduct.Error())
+	}
+	if request.Amount >= product.Price {
+		return nil, rest.NewBadRequestError(domain.ErrOfferNotBelowPrice.Error())
+	}
+	if quantity > product.Available {
+		return nil, rest.NewBadRequestError(domain.ErrOutOfStock.Error())
+	}
+
+	// one negotiation at a time per product
+	now := time.Now().UTC()
+	hasOpenOffer, err := o.offerRepo.HasOpenOffer(ctx, productID, buyerID, now)
+	if err != nil {
+		o.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	if hasOpenOffer {
+		return nil, rest.NewBadRequestError(domain.ErrOfferExists.Error())
+	}
+
+	createdOffer, err := o.offerRepo.Insert(ctx, &domain.Offer{
+		ProductID: productID,
+		BuyerID:   buyerID,
+		SellerID:  product.UserID,
+		Amount:    request.Amount,
+		Quantity:  quantity,
+		Status:    domain.OfferPending,
+		Proposals: []domain.Proposal{{UserID: buyerID, Amount: request.Amount, CreatedAt: now}},
+		ExpiresAt: now.Add(domain.OfferExpiry),
+		CreatedAt: now,
+	})
+	if err != nil {
+		o.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	o.notify(ctx, createdOffer.SellerID, createdOffer,
+		fmt.Sprintf("You received an offer of %d on %s", createdOffer.Amount, product.Title))
+	return createdOffer, nil
+}
+
+// CounterOffer answers the last proposal with another amount
+func (o *offer) CounterOffer(ctx context.Context, offerID, userID string, amount int) (*domain.Offer, error) {
+	// find the offer waiting for the user
+	foundOffer, err := o.awaitingOffer(ctx, offerID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// counters stay below the listed price
+	product, err := o.findProduct(ctx, foundOffer.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	if amount >= product.Price {
+		return nil, rest.NewBadRequestError(domain.ErrOfferNotBelowPrice.Error())
+	}
+
+	now := time.Now().UTC()
+	foundOffer.Status = domain.OfferPending
+	if userID == foundOffer.SellerID {
+		foundOffer.Status = domain.OfferCountered
+	}
+	foundOffer.Amount = amount
+	foundOffer.Proposals = append(foundOffer.Proposals, domain.Proposal{UserID: userID, Amount: amount, CreatedAt: now})
+	foundOffer.ExpiresAt = now.Add(domain.OfferExpiry)
+
+	updatedOffer, err := o.update(ctx, foundOffer)
+	if err != nil {
+		return nil, err
+	}
+	o.notify(ctx, updatedOffer.OtherPartyID(userID), updatedOffer,
+		fmt.Sprintf("Your offer was countered with %d", updatedOffer.Amount))
+	return updatedOffer, nil
+}
+
+// AcceptOffer agrees on the last proposal, orders creates the
+// order of the buyer at the agreed price or declines the offer
+func (o *offer) AcceptOffer(ctx context.Context, offerID, userID string) (*domain.Offer, error) {
+	// find the offer waiting for the user
+	foundOffer, err := o.awaitingOffer(ctx, offerID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the stock may be sold meanwhile
+	product, err := o.findProduct(ctx, foundOffer.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	if foundOffer.Quantity > product.Available {
+		return nil, rest.NewBadRequestError(domain.ErrOutOfStock.Error())
+	}
+
+	foundOffer.Status = domain.OfferAccepted
+	var updatedOffer *domain.Offer
+	err = o.outbox.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		updatedOffer, err = o.update(ctx, foundOffer)
+		if err != nil {
+			return err
+		}
+		msg := domain.OfferAgreedEvent{
+			OfferID:   updatedOffer.ID,
+			Version:   updatedOffer.Version,
+			ProductID: updatedOffer.ProductID,
+			UserID:    updatedOffer.BuyerID,
+			Amount:    updatedOffer.Amount,
+			Quantity:  updatedOffer.Quantity,
+		}
+		if err := o.outbox.Add(ctx, domain.OfferAgreed, outbox.ForAggregate(msg.OfferID, msg)); err != nil {
+			o.logger.Error(err)
+			return rest.NewInternalServerError()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	o.notify(ctx, updatedOffer.OtherPartyID(userID), updatedOffer,
+		fmt.Sprintf("Your offer of %d on %s was accepted", updatedOffer.Amount, product.Title))
+	return updatedOffer, nil
+}
+
+// RejectOffer ends the negotiation, the buyer
+// can withdraw the offer at any time
+func (o *offer) RejectOffer(ctx context.Context, offerID, userID string) (*domain.Offer, error) {
+	// find the offer
+	foundOffer, err := o.ShowOffer(ctx, offerID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !foundOffer.IsOpen(time.Now().UTC()) {
+		return nil, rest.NewBadRequestError(domain.ErrOfferClosed.Error())
+	}
+	if userID != foundOffer.BuyerID && userID != foundOffer.AwaitingUserID() {
+		return nil, rest.NewBadRequestError(domain.ErrNotYourTurn.Error())
+	}
+
+	foundOffer.Status = domain.OfferRejected
+	updatedOffer, err := o.update(ctx, foundOffer)
+	if err != nil {
+		return nil, err
+	}
+	o.notify(ctx, updatedOffer.OtherPartyID(userID), updatedOffer,
+		fmt.Sprintf("The offer of %d was rejected", updatedOffer.Amount))
+	return updatedOffer, nil
+}
+
+// ReopenOffer opens the offer orders declined again, so the parties
+// can agree once the stock is back, later versions are left as they are
+func (o *offer) ReopenOffer(ctx context.Context, offerID string, version int, reason string) error {
+	foundOffer, err := o.offerRepo.FindByID(ctx, offerID)
+	if err != nil {
+		o.logger.Error(err)
+		if err == mongo.ErrNoDocuments {
+			return rest.NewNotFoundError()
+		}
+		return rest.NewInternalServerError()
+	}
+	if foundOffer.Version != version || !foundOffer.Reopen(time.Now().UTC()) {
+		return nil
+	}
+	reopenedOffer, err := o.offerRepo.Update(ctx, foundOffer)
+	if err != nil {
+		// answered meanwhile
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		o.logger.Error(err)
+		return rest.NewInternalServerError()
+	}
+	message := fmt.Sprintf("The accepted offer of %d has no order: %s", reopenedOffer.Amount, reason)
+	o.notify(ctx, reopenedOffer.BuyerID, reopenedOffer, message)
+	o.notify(ctx, reopenedOffer.SellerID, reopenedOffer, message)
+	return nil
+}
+
+// ShowOffer returns the offer to the buyer or the seller
+func (o *offer) ShowOffer(ctx context.Context, offerID, userID string) (*domain.Offer, error) {
+	foundOffer, err := o.offerRepo.FindByID(ctx, offerID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, rest.NewNotFoundError()
+		}
+		o.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+
+	// only the parties see the negotiation
+	if userID != foundOffer.BuyerID && userID != foundOffer.SellerID {
+		return nil, rest.NewUnauthorizedError()
+	}
+
+	// expired offers are closed when they are read
+	if foundOffer.Expire(time.Now().UTC()) {
+		if _, err := o.offerRepo.Update(ctx, foundOffer); err != nil && err != mongo.ErrNoDocuments {
+			o.logger.Error(err)
+		}
+	}
+	return foundOffer, nil
+}
+
+// ListProductOffers returns every offer on the product to
+// the seller and only their own offers to buyers
+func (o *offer) ListProductOffers(ctx context.Context, productID, userID string) ([]*domain.Offer, error) {
+	offers, err := o.offerRepo.ListByProduct(ctx, productID)
+	if err != nil {
+		o.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	visible := make([]*domain.Offer, 0, len(offers))
+	now := time.Now().UTC()
+	for _, offer := range offers {
+		if userID == offer.SellerID || userID == offer.BuyerID {
+			offer.Expire(now)
+			visible = append(visible, offer)
+		}
+	}
+	return visible, nil
+}
+
+// ListUserOffers returns the offers the user made or received
+func (o *offer) ListUserOffers(ctx context.Context, userID string) ([]*domain.Offer, error) {
+	offers, err := o.offerRepo.ListByUser(ctx, userID)
+	if err != nil {
+		o.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	now := time.Now().UTC()
+	for _, offer := range offers {
+		offer.Expire(now)
+	}
+	return offers, nil
+}
+
+// awaitingOffer finds the open offer waiting for the answer of the user
+func (o *offer) awaitingOffer(ctx context.Context, offerID, userID string) (*domain.Offer, error) {
+	foundOffer, err := o.ShowOffer(ctx, offerID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !foundOffer.IsOpen(time.Now().UTC()) {
+		return nil, rest.NewBadRequestError(domain.ErrOfferClosed.Error())
+	}
+	if foundOffer.AwaitingUserID() != userID {
+		return nil, rest.NewBadRequestError(domain.ErrNotYourTurn.Error())
+	}
+	return foundOffer, nil
+}
+
+// findProduct finds a product still on sale and shown to buyers
+func (o *offer) findProduct(ctx context.Context, productID string) (*domain.Product, error) {
+	product, err := o.productRepo.FindByID(ctx, productID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, rest.NewNotFoundError()
+		}
+		o.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	if product.ArchivedAt != nil || product.Hidden() {
+		return nil, rest.NewNotFoundError()
+	}
+	return product, nil
+}
+
+func (o *offer) update(ctx context.Context, offer *domain.Offer) (*domain.Offer, error) {
+	updatedOffer, err := o.offerRepo.Update(ctx, offer)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, rest.NewBadRequestError(domain.ErrOfferChanged.Error())
+		}
+		o.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	return updatedOffer, nil
+}
+
+// notify logs failures, negotiations
+// don't depend on their notifications
+func (o *offer) notify(ctx context.Context, userID string, offer *domain.Offer, message string) {
+	if err := o.notificationUC.Notify(ctx, []string{userID}, domain.NotificationOffer, offer.ProductID, message); err != nil {
+		o.logger.Error(err)
+	}
+}
+
+type Offer interface {
+	MakeOffer(ctx context.Context, productID, buyerID string, request *domain.OfferRequest) (*domain.Offer, error)
+	CounterOffer(ctx context.Context, offerID, userID string, amount int) (*domain.Offer, error)
+	AcceptOffer(ctx context.Context, offerID, userID string) (*domain.Offer, error)
+	RejectOffer(ctx context.Context, offerID, userID string) (*domain.Offer, error)
+	ReopenOffer(ctx context.Context, offerID string, version int, reason string) error
+	ShowOffer(ctx context.Context, offerID, userID string) (*domain.Offer, error)
+	ListProductOffers(ctx context.Context, productID, userID string) ([]*domain.Offer, error)
+	ListUserOffers(ctx context.Context, userID string) ([]*domain.Offer, error)
+}
@@ -4,27 +4,39 @@ import (
 	"github.com/halilylm/gommon/middlewares"
 	"github.com/halilylm/gommon/rest"
 	"github.com/halilylm/gommon/utils"
+	"github.com/halilylm/secondhand/messaging/roles"
 	"github.com/halilylm/secondhand/product/domain"
 	"github.com/halilylm/secondhand/product/product/usecase"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 	"net/http"
+	"strings"
 )
 
 type productHandler struct {
 	productUC usecase.Product
+	roles     *roles.Roles
 }
 
 // NewProductHandler handler for products
-func NewProductHandler(g *echo.Group, productUC usecase.Product) {
-	handler := &productHandler{productUC: productUC}
+func NewProductHandler(g *echo.Group, productUC usecase.Product, appRoles *roles.Roles) {
+	handler := &productHandler{productUC: productUC, roles: appRoles}
 
 	// jwt middleware
 	g.Use(middlewares.CurrentUser("jwt"))
 
 	g.POST("/", handler.NewProduct)
 	g.PUT("/:id", handler.UpdateProduct)
+	g.DELETE("/:id", handler.DeleteProduct)
 	g.GET("/:id", handler.ShowProduct)
 	g.GET("/", handler.AvailableProducts)
+	g.GET("/:id/history", handler.ProductHistory)
+
+	// images
+	g.POST("/:id/images", handler.AddImage, middleware.BodyLimit(imageBodyLimit))
+	g.PUT("/:id/images/order", handler.ReorderImages)
+	g.GET("/:id/images/:image_id", handler.ShowImage)
+	g.DELETE("/:id/images/:image_id", handler.RemoveImage)
 }
 
 func (p *productHandler) NewProduct(c echo.Context) error {
@@ -56,23 +68,23 @@ func (p *productHandler) NewProduct(c echo.Context) error {
 }
 
 func (p *productHandler) UpdateProduct(c echo.Context) error {
-	var product domain.Product
+	var update domain.ProductUpdate
 
-	// bind request body to ticket
-	if err := c.Bind(&product); err != nil {
+	// bind request body to product update
+	if err := c.Bind(&update); err != nil {
 		return c.JSON(rest.ErrorResponse(rest.NewBadRequestError(err.Error())))
 	}
 
 	// validate the struct
-	if err := utils.ValidateStruct(&product); err != nil {
+	if err := utils.ValidateStruct(&update); err != nil {
 		return c.JSON(rest.ErrorResponse(rest.NewValidationErrors(err)))
 	}
 
-	// fill the ticket id
-	product.ID = c.Param("id")
+	// get user from the context
+	user := middlewares.UserFromContext(c)
 
 	// call the usecase
-	updatedProduct, err := p.productUC.UpdateProduct(c.Request().Context(), &product)
+	updatedProduct, err := p.productUC.EditProduct(c.Request().Context(), c.Param("id"), user.ID, &update)
 	if err != nil {
 		// errors returning from usecase layer will be rest errors
 		// so err can be used directly
@@ -81,12 +93,30 @@ func (p *productHandler) UpdateProduct(c echo.Context) error {
 	return c.JSON(http.StatusOK, updatedProduct)
 }
 
+func (p *productHandler) DeleteProduct(c echo.Context) error {
+	// get user from the context
+	user := middlewares.UserFromContext(c)
+
+	// call the usecase
+	if err := p.productUC.DeleteProduct(c.Request().Context(), c.Param("id"), user.ID); err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
 func (p *productHandler) ShowProduct(c echo.Context) error {
 	// id of wanted product
 	id := c.Param("id")
 
+	// visitors may not be signed in
+	var userID string
+	if user := middlewares.UserFromContext(c); user != nil {
+		userID = user.ID
+	}
+
 	// call the usecase
-	foundProduct, err := p.productUC.ShowProduct(c.Request().Context(), id)
+	foundProduct, err := p.productUC.ShowProduct(c.Request().Context(), id, userID)
 	if err != nil {
 		return c.JSON(rest.ErrorResponse(err))
 	}
@@ -94,9 +124,48 @@ func (p *productHandler) ShowProduct(c echo.Context) error {
 	return c.JSON(http.StatusOK, foundProduct)
 }
 
+func (p *productHandler) ProductHistory(c echo.Context) error {
+	// visitors may not be signed in
+	var userID string
+	if user := middlewares.UserFromContext(c); user != nil {
+		userID = user.ID
+	}
+	admin := userID != "" && p.roles.Has(userID, roles.Admin)
+
+	// call the usecase
+	revisions, err := p.productUC.ProductHistory(c.Request().Context(), c.Param("id"), userID, admin)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, revisions)
+}
+
 func (p *productHandler) AvailableProducts(c echo.Context) error {
+	var query domain.ProductQuery
+
+	// bind query parameters to product query
+	if err := c.Bind(&query); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewBadRequestError(err.Error())))
+	}
+
+	// validate the struct
+	if err := utils.ValidateStruct(&query); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewValidationErrors(err)))
+	}
+
+	// attribute filters are given as attr.<name>=<value>
+	for key, values := range c.QueryParams() {
+		if strings.HasPrefix(key, "attr.") && len(values) > 0 {
+			if query.Attributes == nil {
+				query.Attributes = make(map[string]any)
+			}
+			query.Attributes[strings.TrimPrefix(key, "attr.")] = values[0]
+		}
+	}
+
 	// call the usecase
-	products, err := p.productUC.AvailableProducts(c.Request().Context())
+	products, err := p.productUC.AvailableProducts(c.Request().Context(), &query)
 	if err != nil {
 		return c.JSON(rest.ErrorResponse(err))
 	}
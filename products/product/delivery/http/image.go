@@ -0,0 +1,110 @@
+package http
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/halilylm/gommon/middlewares"
+	"github.com/halilylm/gommon/rest"
+	"github.com/halilylm/gommon/utils"
+	"github.com/halilylm/secondhand/product/domain"
+	"github.com/labstack/echo/v4"
+)
+
+// imageBodyLimit caps the upload request, the image
+// along with the multipart overhead, before it is read
+const imageBodyLimit = "11M"
+
+func (p *productHandler) AddImage(c echo.Context) error {
+	// get the uploaded file
+	file, err := c.FormFile("image")
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewBadRequestError(err.Error())))
+	}
+	if file.Size > domain.MaxImageSize {
+		return c.JSON(rest.ErrorResponse(rest.NewBadRequestError("image is too large")))
+	}
+	src, err := file.Open()
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewBadRequestError(err.Error())))
+	}
+	defer src.Close()
+	data, err := io.ReadAll(io.LimitReader(src, domain.MaxImageSize+1))
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewBadRequestError(err.Error())))
+	}
+	if len(data) > domain.MaxImageSize {
+		return c.JSON(rest.ErrorResponse(rest.NewBadRequestError("image is too large")))
+	}
+
+	// get user from the context
+	user := middlewares.UserFromContext(c)
+
+	// call the usecase
+	updatedProduct, err := p.productUC.AddImage(c.Request().Context(), c.Param("id"), user.ID, data)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusCreated, updatedProduct)
+}
+
+func (p *productHandler) RemoveImage(c echo.Context) error {
+	// get user from the context
+	user := middlewares.UserFromContext(c)
+
+	// call the usecase
+	updatedProduct, err := p.productUC.RemoveImage(c.Request().Context(), c.Param("id"), user.ID, c.Param("image_id"))
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, updatedProduct)
+}
+
+func (p *productHandler) ReorderImages(c echo.Context) error {
+	var order domain.ImageOrder
+
+	// bind request body to image order
+	if err := c.Bind(&order); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewBadRequestError(err.Error())))
+	}
+
+	// validate the struct
+	if err := utils.ValidateStruct(&order); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewValidationErrors(err)))
+	}
+
+	// get user from the context
+	user := middlewares.UserFromContext(c)
+
+	// call the usecase
+	updatedProduct, err := p.productUC.ReorderImages(c.Request().Context(), c.Param("id"), user.ID, order.ImageIDs)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, updatedProduct)
+}
+
+func (p *productHandler) ShowImage(c echo.Context) error {
+	// thumbnails are served with ?size=thumb
+	thumbnail := c.QueryParam("size") == "thumb"
+
+	// visitors may not be signed in
+	var userID string
+	if user := middlewares.UserFromContext(c); user != nil {
+		userID = user.ID
+	}
+
+	// call the usecase
+	content, contentType, err := p.productUC.ImageContent(c.Request().Context(), c.Param("id"), c.Param("image_id"), userID, thumbnail)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+	defer content.Close()
+
+	// content type was sniffed on upload
+	c.Response().Header().Set("X-Content-Type-Options", "nosniff")
+	return c.Stream(http.StatusOK, contentType, content)
+}
@@ -0,0 +1,43 @@
+package natstream
+
+import (
+	"context"
+	"github.com/halilylm/gommon/events/common/messages"
+	"github.com/halilylm/secondhand/messaging/consumer"
+	"github.com/halilylm/secondhand/messaging/inbox"
+	"github.com/halilylm/secondhand/product/product/usecase"
+)
+
+type PaymentConsumerGroup struct {
+	productUC usecase.Product
+	inbox     inbox.Store
+	groupID   string
+}
+
+func NewPaymentConsumerGroup(
+	productUC usecase.Product,
+	inbox inbox.Store,
+	groupID string,
+) *PaymentConsumerGroup {
+	return &PaymentConsumerGroup{
+		productUC: productUC,
+		inbox:     inbox,
+		groupID:   groupID,
+	}
+}
+
+func (pcg *PaymentConsumerGroup) GroupID() string {
+	return pcg.groupID
+}
+
+func (pcg *PaymentConsumerGroup) Subscriptions() []consumer.Subscription {
+	return []consumer.Subscription{
+		consumer.On(messages.PaymentCreated, pcg.createdPayment),
+	}
+}
+
+func (pcg *PaymentConsumerGroup) createdPayment(ctx context.Context, eventID string, deliveredEvent *messages.PaymentCreatedEvent) error {
+	return pcg.inbox.Process(ctx, pcg.groupID, eventID, func(ctx context.Context) error {
+		return pcg.productUC.SellProduct(ctx, deliveredEvent.OrderID)
+	})
+}
@@ -0,0 +1,94 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"github.com/halilylm/secondhand/product/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PlaceBid makes the bid the highest one if the auction is open
+// and the bid beats the current one by the minimum increment,
+// the end is pushed back to extendTo for late bids.
+// The version is kept since bids are not replicated
+func (p *productRepository) PlaceBid(ctx context.Context, productID string, bid *domain.Bid, extendTo time.Time) (*domain.Product, error) {
+	var updatedProduct domain.Product
+	res := p.collection.FindOneAndUpdate(ctx, bson.M{
+		"_id":             productID,
+		"listing":         domain.ListingAuction,
+		"archived_at":     nil,
+		"auction.status":  domain.AuctionOpen,
+		"auction.ends_at": bson.M{"$gt": bid.CreatedAt},
+		"$or": bson.A{
+			bson.M{
+				"auction.highest_bid": nil,
+				"auction.start_price": bson.M{"$lte": bid.Amount},
+			},
+			bson.M{"$expr": bson.M{"$gte": bson.A{
+				bid.Amount,
+				bson.M{"$add": bson.A{"$auction.highest_bid.amount", "$auction.min_increment"}},
+			}}},
+		},
+	}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"auction.highest_bid": bson.M{"$literal": bid},
+			"auction.bid_count":   bson.M{"$add": bson.A{"$auction.bid_count", 1}},
+			"auction.ends_at":     bson.M{"$max": bson.A{"$auction.ends_at", extendTo}},
+		}}},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&updatedProduct); err != nil {
+		return nil, err
+	}
+	return &updatedProduct, nil
+}
+
+// DueAuctions returns open auctions that have ended
+func (p *productRepository) DueAuctions(ctx context.Context, now time.Time, limit int) ([]*domain.Product, error) {
+	products := make([]*domain.Product, 0)
+	cur, err := p.collection.Find(ctx, bson.M{
+		"auction.status":  domain.AuctionOpen,
+		"auction.ends_at": bson.M{"$lte": now},
+	}, options.Find().SetSort(bson.D{{Key: "auction.ends_at", Value: 1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var product domain.Product
+		if err := cur.Decode(&product); err != nil {
+			return nil, err
+		}
+		products = append(products, &product)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// CloseAuction ends an open auction that has ended,
+// auctions extended by a late bid are left open
+func (p *productRepository) CloseAuction(ctx context.Context, productID string, status domain.AuctionStatus, now time.Time) (*domain.Product, error) {
+	var closedProduct domain.Product
+	res := p.collection.FindOneAndUpdate(ctx, bson.M{
+		"_id":             productID,
+		"auction.status":  domain.AuctionOpen,
+		"auction.ends_at": bson.M{"$lte": now},
+	}, bson.M{"$set": bson.M{
+		"auction.status":    status,
+		"auction.closed_at": now,
+	}}, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&closedProduct); err != nil {
+		return nil, err
+	}
+	return &closedProduct, nil
+}
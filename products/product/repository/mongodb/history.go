@@ -0,0 +1,62 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/halilylm/secondhand/product/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type historyRepository struct {
+	collection *mongo.Collection
+}
+
+// NewHistoryRepository returns a new mongo product history repository
+func NewHistoryRepository(collection *mongo.Collection) domain.HistoryRepository {
+	return &historyRepository{collection: collection}
+}
+
+// CreateHistoryIndexes creates the indexes the history relies on,
+// a version of a product is recorded once
+func CreateHistoryIndexes(ctx context.Context, collection *mongo.Collection) error {
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "product_id", Value: 1}, {Key: "version", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// Insert appends the revision to the history,
+// recording a version again keeps the first one
+func (h *historyRepository) Insert(ctx context.Context, revision *domain.ProductRevision) error {
+	revision.ID = uuid.NewString()
+	if _, err := h.collection.InsertOne(ctx, revision); err != nil && !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	return nil
+}
+
+// ListByProduct returns the revisions of the product, oldest first
+func (h *historyRepository) ListByProduct(ctx context.Context, productID string) ([]*domain.ProductRevision, error) {
+	revisions := make([]*domain.ProductRevision, 0)
+	cur, err := h.collection.Find(ctx, bson.M{"product_id": productID},
+		options.Find().SetSort(bson.D{{Key: "version", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var revision domain.ProductRevision
+		if err := cur.Decode(&revision); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, &revision)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
@@ -0,0 +1,54 @@
+package mongodb
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// legacyCreatedAt is given to products listed before they had a
+// creation time, they are listed after every dated product
+var legacyCreatedAt = time.Unix(0, 0).UTC()
+
+// MigrateProducts backfills the fields products listed by
+// earlier versions lack, it is safe to run on every start
+func MigrateProducts(ctx context.Context, collection *mongo.Collection) error {
+	// keyset paging orders by creation time
+	if _, err := collection.UpdateMany(ctx, bson.M{
+		"created_at": nil,
+	}, bson.M{"$set": bson.M{"created_at": legacyCreatedAt}}); err != nil {
+		return err
+	}
+
+	// products were single items before they had stock
+	if _, err := collection.UpdateMany(ctx, bson.M{
+		"quantity": bson.M{"$exists": false},
+	}, bson.M{"$set": bson.M{"quantity": 1}}); err != nil {
+		return err
+	}
+
+	// a product ordered back then is held by its order until
+	// the order is cancelled or paid like any reservation
+	if _, err := collection.UpdateMany(ctx, bson.M{
+		"available": bson.M{"$exists": false},
+		"order_id":  bson.M{"$type": "string"},
+	}, bson.A{bson.M{"$set": bson.M{
+		"available": 0,
+		"reservations": bson.A{bson.M{
+			"order_id": "$order_id",
+			"quantity": "$quantity",
+			"sold":     false,
+		}},
+	}}}); err != nil {
+		return err
+	}
+	_, err := collection.UpdateMany(ctx, bson.M{
+		"available": bson.M{"$exists": false},
+	}, bson.A{bson.M{"$set": bson.M{
+		"available":    "$quantity",
+		"reservations": bson.A{},
+	}}})
+	return err
+}
@@ -0,0 +1,101 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/halilylm/secondhand/product/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// hiddenStatuses are kept from buyers, products
+// without a status were published before moderation
+var hiddenStatuses = bson.A{domain.ModerationPending, domain.ModerationRejected, domain.ModerationTakenDown}
+
+// CountPublishedBySeller counts the published products of the seller
+func (p *productRepository) CountPublishedBySeller(ctx context.Context, userID string) (int64, error) {
+	return p.collection.CountDocuments(ctx, bson.M{
+		"user_id":           userID,
+		"moderation.status": bson.M{"$nin": hiddenStatuses},
+	})
+}
+
+// Moderate sets the decision of the moderator if the product still
+// has one of the from statuses, open reports are resolved with it.
+// The version is raised so replicas learn whether it can be ordered
+func (p *productRepository) Moderate(ctx context.Context, productID string, from []domain.ModerationStatus, moderation *domain.Moderation) (*domain.Product, error) {
+	var moderatedProduct domain.Product
+	statuses := make(bson.A, 0, len(from))
+	for _, status := range from {
+		if status == "" {
+			// products without a status
+			statuses = append(statuses, nil)
+		}
+		statuses = append(statuses, status)
+	}
+	res := p.collection.FindOneAndUpdate(ctx, bson.M{
+		"_id":               productID,
+		"moderation.status": bson.M{"$in": statuses},
+	}, bson.M{
+		"$set": bson.M{"moderation": moderation},
+		"$inc": bson.M{"version": 1},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&moderatedProduct); err != nil {
+		return nil, err
+	}
+	return &moderatedProduct, nil
+}
+
+// AddReport counts a report of a published product,
+// the version is kept since reports are not replicated
+func (p *productRepository) AddReport(ctx context.Context, productID string) (*domain.Product, error) {
+	var reportedProduct domain.Product
+	res := p.collection.FindOneAndUpdate(ctx, bson.M{
+		"_id":               productID,
+		"archived_at":       nil,
+		"moderation.status": bson.M{"$nin": hiddenStatuses},
+	}, bson.M{"$inc": bson.M{
+		"moderation.open_reports": 1,
+	}}, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&reportedProduct); err != nil {
+		return nil, err
+	}
+	return &reportedProduct, nil
+}
+
+// ModerationQueue returns the pending and the reported
+// published products, oldest first
+func (p *productRepository) ModerationQueue(ctx context.Context, limit int) ([]*domain.Product, error) {
+	products := make([]*domain.Product, 0)
+	cur, err := p.collection.Find(ctx, bson.M{
+		"archived_at": nil,
+		"$or": bson.A{
+			bson.M{"moderation.status": domain.ModerationPending},
+			bson.M{
+				"moderation.status":       bson.M{"$nin": hiddenStatuses},
+				"moderation.open_reports": bson.M{"$gt": 0},
+			},
+		},
+	}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var product domain.Product
+		if err := cur.Decode(&product); err != nil {
+			return nil, err
+		}
+		products = append(products, &product)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
@@ -2,6 +2,11 @@ package mongodb
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/halilylm/secondhand/product/domain"
 	"go.mongodb.org/mongo-driver/bson"
@@ -19,6 +24,9 @@ func NewProductRepository(collection *mongo.Collection) domain.ProductRepository
 
 func (p *productRepository) Insert(ctx context.Context, product *domain.Product) (*domain.Product, error) {
 	product.ID = uuid.NewString()
+	product.CreatedAt = time.Now().UTC()
+	product.Available = product.Quantity
+	product.Reservations = []domain.Reservation{}
 	_, err := p.collection.InsertOne(ctx, product)
 	if err != nil {
 		return nil, err
@@ -32,10 +40,20 @@ func (p *productRepository) Update(ctx context.Context, product *domain.Product)
 		"version": product.Version,
 		"_id":     product.ID,
 	}, bson.M{"$set": map[string]any{
-		"title":    product.Title,
-		"version":  product.Version + 1,
-		"price":    product.Price,
-		"order_id": product.OrderID,
+		"title":       product.Title,
+		"version":     product.Version + 1,
+		"price":       product.Price,
+		"quantity":    product.Quantity,
+		"available":   product.Available,
+		"category_id": product.CategoryID,
+		"tags":        product.Tags,
+		"attributes":  product.Attributes,
+		"location":    product.Location,
+		"delivery":    product.Delivery,
+		// reports are counted without a new version,
+		// so only the status and reason are written
+		"moderation.status": product.Moderation.Status,
+		"moderation.reason": product.Moderation.Reason,
 	}}, options.FindOneAndUpdate().SetReturnDocument(options.After))
 	if res.Err() != nil {
 		return nil, res.Err()
@@ -46,6 +64,27 @@ func (p *productRepository) Update(ctx context.Context, product *domain.Product)
 	return &updatedProduct, nil
 }
 
+// Archive takes down a product without pending reservations,
+// archived products are kept for existing orders
+func (p *productRepository) Archive(ctx context.Context, product *domain.Product) (*domain.Product, error) {
+	var archivedProduct domain.Product
+	res := p.collection.FindOneAndUpdate(ctx, bson.M{
+		"version":      product.Version,
+		"_id":          product.ID,
+		"reservations": bson.M{"$not": bson.M{"$elemMatch": bson.M{"sold": false}}},
+	}, bson.M{"$set": map[string]any{
+		"archived_at": time.Now().UTC(),
+		"version":     product.Version + 1,
+	}}, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&archivedProduct); err != nil {
+		return nil, err
+	}
+	return &archivedProduct, nil
+}
+
 func (p *productRepository) FindByID(ctx context.Context, id string) (*domain.Product, error) {
 	var foundProduct domain.Product
 	res := p.collection.FindOne(ctx, bson.M{"_id": id})
@@ -58,18 +97,411 @@ func (p *productRepository) FindByID(ctx context.Context, id string) (*domain.Pr
 	return &foundProduct, nil
 }
 
-func (p *productRepository) AvailableProducts(ctx context.Context) ([]*domain.Product, error) {
-	products := make([]*domain.Product, 0)
-	cur, err := p.collection.Find(ctx, bson.M{"order_id": nil})
+// FindByIDs finds the products with the ids, missing ones are skipped
+func (p *productRepository) FindByIDs(ctx context.Context, ids []string) ([]*domain.Product, error) {
+	products := make([]*domain.Product, 0, len(ids))
+	cur, err := p.collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
 	if err != nil {
 		return nil, err
 	}
+	defer cur.Close(ctx)
 	for cur.Next(ctx) {
 		var product domain.Product
 		if err := cur.Decode(&product); err != nil {
-			continue
+			return nil, err
 		}
 		products = append(products, &product)
 	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
 	return products, nil
 }
+
+const (
+	defaultPageSize = 20
+
+	// totalEstimateLimit caps counting so
+	// broad queries stay cheap
+	totalEstimateLimit = 10000
+)
+
+// CreateProductIndexes creates the indexes product listings rely on
+func CreateProductIndexes(ctx context.Context, collection *mongo.Collection) error {
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "title", Value: "text"}}},
+		{Keys: bson.D{{Key: "archived_at", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}, {Key: "available", Value: 1}}},
+		{Keys: bson.D{{Key: "archived_at", Value: 1}, {Key: "price", Value: 1}, {Key: "_id", Value: 1}, {Key: "available", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "archived_at", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "category_id", Value: 1}, {Key: "archived_at", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "tags", Value: 1}}},
+		{Keys: bson.D{{Key: "reservations.order_id", Value: 1}}},
+		{Keys: bson.D{{Key: "auction.status", Value: 1}, {Key: "auction.ends_at", Value: 1}}},
+		{Keys: bson.D{{Key: "moderation.status", Value: 1}, {Key: "created_at", Value: 1}}},
+		{Keys: bson.D{{Key: "moderation.open_reports", Value: 1}}},
+		{Keys: bson.D{{Key: "location.point", Value: "2dsphere"}}},
+	})
+	return err
+}
+
+// productCursor points after the last product of a page,
+// _id breaks ties so pages are stable
+type productCursor struct {
+	Sort      domain.ProductSort `json:"s"`
+	Price     int                `json:"p,omitempty"`
+	CreatedAt time.Time          `json:"c,omitempty"`
+	Distance  float64            `json:"d,omitempty"`
+	ID        string             `json:"i"`
+}
+
+func encodeCursor(sort domain.ProductSort, product *domain.Product) string {
+	cursor := productCursor{
+		Sort:      sort,
+		Price:     product.Price,
+		CreatedAt: product.CreatedAt,
+		ID:        product.ID,
+	}
+	if product.Distance != nil {
+		cursor.Distance = *product.Distance
+	}
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(sort domain.ProductSort, encoded string) (*productCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, domain.ErrInvalidCursor
+	}
+	var cursor productCursor
+	if err := json.Unmarshal(b, &cursor); err != nil || cursor.Sort != sort || cursor.ID == "" {
+		return nil, domain.ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// AddImage appends the image to a product with stock left,
+// the version is kept since images are not replicated
+func (p *productRepository) AddImage(ctx context.Context, productID string, image *domain.Image, maxImages int) (*domain.Product, error) {
+	var updatedProduct domain.Product
+	res := p.collection.FindOneAndUpdate(ctx, bson.M{
+		"_id":         productID,
+		"available":   bson.M{"$gt": 0},
+		"archived_at": nil,
+		// fewer than max images
+		fmt.Sprintf("images.%d", maxImages-1): bson.M{"$exists": false},
+	}, bson.M{"$push": bson.M{
+		"images": image,
+	}}, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&updatedProduct); err != nil {
+		return nil, err
+	}
+	return &updatedProduct, nil
+}
+
+// RemoveImage removes the image from the product
+func (p *productRepository) RemoveImage(ctx context.Context, productID, imageID string) (*domain.Product, error) {
+	var updatedProduct domain.Product
+	res := p.collection.FindOneAndUpdate(ctx, bson.M{
+		"_id":       productID,
+		"images.id": imageID,
+	}, bson.M{"$pull": bson.M{
+		"images": bson.M{"id": imageID},
+	}}, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&updatedProduct); err != nil {
+		return nil, err
+	}
+	return &updatedProduct, nil
+}
+
+// ReorderImages puts the images in the given order,
+// ids must be exactly the ids of the current images
+func (p *productRepository) ReorderImages(ctx context.Context, productID string, imageIDs []string) (*domain.Product, error) {
+	var updatedProduct domain.Product
+	// images are reordered inside the update so
+	// a concurrently added image isn't lost
+	res := p.collection.FindOneAndUpdate(ctx, bson.M{
+		"_id":       productID,
+		"images":    bson.M{"$size": len(imageIDs)},
+		"images.id": bson.M{"$all": imageIDs},
+	}, mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"images": bson.M{"$map": bson.M{
+			"input": imageIDs,
+			"as":    "imageID",
+			"in": bson.M{"$arrayElemAt": bson.A{bson.M{"$filter": bson.M{
+				"input": "$images",
+				"cond":  bson.M{"$eq": bson.A{"$$this.id", "$$imageID"}},
+			}}, 0}},
+		}},
+	}}}}, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&updatedProduct); err != nil {
+		return nil, err
+	}
+	return &updatedProduct, nil
+}
+
+// CountByCategory counts products directly in the category
+func (p *productRepository) CountByCategory(ctx context.Context, categoryID string) (int64, error) {
+	return p.collection.CountDocuments(ctx, bson.M{"category_id": categoryID})
+}
+
+// AvailableProducts lists one page of unreserved products
+func (p *productRepository) AvailableProducts(ctx context.Context, query *domain.ProductQuery) (*domain.ProductPage, error) {
+	sort := query.Sort
+	if sort == "" {
+		sort = domain.SortNewest
+	}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+
+	// filters
+	filter := bson.M{
+		"available":   bson.M{"$gt": 0},
+		"archived_at": nil,
+		// closed auctions are not for sale anymore
+		"auction.status": bson.M{"$nin": bson.A{domain.AuctionSold, domain.AuctionUnsold}},
+		// listings waiting for or rejected by moderators
+		"moderation.status": bson.M{"$nin": hiddenStatuses},
+	}
+	if query.Search != "" {
+		filter["$text"] = bson.M{"$search": query.Search}
+	}
+	if query.SellerID != "" {
+		filter["user_id"] = query.SellerID
+	}
+	if len(query.CategoryIDs) > 0 {
+		filter["category_id"] = bson.M{"$in": query.CategoryIDs}
+	}
+	if query.Tag != "" {
+		filter["tags"] = query.Tag
+	}
+	for name, value := range query.Attributes {
+		filter["attributes."+name] = value
+	}
+	if query.Origin != nil && sort != domain.SortDistance {
+		filter["location.point"] = bson.M{"$geoWithin": bson.M{
+			"$centerSphere": bson.A{query.Origin.Coordinates, query.Radius / domain.EarthRadius},
+		}}
+	}
+	priceRange := bson.M{}
+	if query.MinPrice != nil {
+		priceRange["$gte"] = *query.MinPrice
+	}
+	if query.MaxPrice != nil {
+		priceRange["$lte"] = *query.MaxPrice
+	}
+	if len(priceRange) > 0 {
+		filter["price"] = priceRange
+	}
+
+	// nearest products are found by their own pipeline
+	if sort == domain.SortDistance {
+		return p.nearestProducts(ctx, filter, query, limit)
+	}
+
+	// estimate the total before the cursor narrows the filter
+	total, err := p.collection.CountDocuments(ctx, filter, options.Count().SetLimit(totalEstimateLimit))
+	if err != nil {
+		return nil, err
+	}
+
+	// sort order and position after the cursor
+	var sortBy bson.D
+	switch sort {
+	case domain.SortPriceAsc:
+		sortBy = bson.D{{Key: "price", Value: 1}, {Key: "_id", Value: 1}}
+	case domain.SortPriceDesc:
+		sortBy = bson.D{{Key: "price", Value: -1}, {Key: "_id", Value: -1}}
+	default:
+		sortBy = bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}
+	}
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(sort, query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		switch sort {
+		case domain.SortPriceAsc:
+			filter["$or"] = bson.A{
+				bson.M{"price": bson.M{"$gt": cursor.Price}},
+				bson.M{"price": cursor.Price, "_id": bson.M{"$gt": cursor.ID}},
+			}
+		case domain.SortPriceDesc:
+			filter["$or"] = bson.A{
+				bson.M{"price": bson.M{"$lt": cursor.Price}},
+				bson.M{"price": cursor.Price, "_id": bson.M{"$lt": cursor.ID}},
+			}
+		default:
+			filter["$or"] = bson.A{
+				bson.M{"created_at": bson.M{"$lt": cursor.CreatedAt}},
+				bson.M{"created_at": cursor.CreatedAt, "_id": bson.M{"$lt": cursor.ID}},
+			}
+		}
+	}
+
+	// fetch one more to know if there is a next page
+	opts := options.Find().SetSort(sortBy).SetLimit(int64(limit + 1))
+	cur, err := p.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	products := make([]*domain.Product, 0, limit)
+	for cur.Next(ctx) {
+		var product domain.Product
+		if err := cur.Decode(&product); err != nil {
+			return nil, err
+		}
+		products = append(products, &product)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+
+	page := &domain.ProductPage{Items: products, TotalEstimate: total}
+	if len(products) > limit {
+		page.Items = products[:limit]
+		page.NextCursor = encodeCursor(sort, page.Items[limit-1])
+	}
+	return page, nil
+}
+
+// Reserve takes the stock of the reservation if enough is left,
+// an order reserves at most once
+func (p *productRepository) Reserve(ctx context.Context, productID string, reservation *domain.Reservation) (*domain.Product, error) {
+	var updatedProduct domain.Product
+	res := p.collection.FindOneAndUpdate(ctx, bson.M{
+		"_id":                   productID,
+		"available":             bson.M{"$gte": reservation.Quantity},
+		"reservations.order_id": bson.M{"$ne": reservation.OrderID},
+	}, bson.M{
+		"$inc":  bson.M{"available": -reservation.Quantity, "version": 1},
+		"$push": bson.M{"reservations": reservation},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&updatedProduct); err != nil {
+		return nil, err
+	}
+	return &updatedProduct, nil
+}
+
+// Release gives the stock of a pending reservation back,
+// sold reservations are never released
+func (p *productRepository) Release(ctx context.Context, productID string, reservation *domain.Reservation) (*domain.Product, error) {
+	var updatedProduct domain.Product
+	res := p.collection.FindOneAndUpdate(ctx, bson.M{
+		"_id": productID,
+		"reservations": bson.M{"$elemMatch": bson.M{
+			"order_id": reservation.OrderID,
+			"quantity": reservation.Quantity,
+			"sold":     false,
+		}},
+	}, bson.M{
+		"$inc":  bson.M{"available": reservation.Quantity, "version": 1},
+		"$pull": bson.M{"reservations": bson.M{"order_id": reservation.OrderID}},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&updatedProduct); err != nil {
+		return nil, err
+	}
+	return &updatedProduct, nil
+}
+
+// MarkSold keeps the reservations of a paid order for good on every
+// product of the order, the version is kept since the stock does not change
+func (p *productRepository) MarkSold(ctx context.Context, orderID string) error {
+	res, err := p.collection.UpdateMany(ctx, bson.M{
+		"reservations.order_id": orderID,
+	}, bson.M{"$set": bson.M{
+		"reservations.$.sold": true,
+	}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// nearestProducts lists one page of the products within the radius
+// of the origin, nearest first with the distance set in km
+func (p *productRepository) nearestProducts(ctx context.Context, filter bson.M, query *domain.ProductQuery, limit int) (*domain.ProductPage, error) {
+	// estimate the total before the cursor narrows the results
+	countFilter := bson.M{"location.point": bson.M{"$geoWithin": bson.M{
+		"$centerSphere": bson.A{query.Origin.Coordinates, query.Radius / domain.EarthRadius},
+	}}}
+	for key, value := range filter {
+		countFilter[key] = value
+	}
+	total, err := p.collection.CountDocuments(ctx, countFilter, options.Count().SetLimit(totalEstimateLimit))
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$geoNear", Value: bson.M{
+			"near":               query.Origin,
+			"key":                "location.point",
+			"distanceField":      "distance",
+			"distanceMultiplier": 0.001,
+			"maxDistance":        query.Radius * 1000,
+			"spherical":          true,
+			"query":              filter,
+		}}},
+	}
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(domain.SortDistance, query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{"$or": bson.A{
+			bson.M{"distance": bson.M{"$gt": cursor.Distance}},
+			bson.M{"distance": cursor.Distance, "_id": bson.M{"$gt": cursor.ID}},
+		}}}})
+	}
+
+	// fetch one more to know if there is a next page
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "distance", Value: 1}, {Key: "_id", Value: 1}}}},
+		bson.D{{Key: "$limit", Value: limit + 1}},
+	)
+	cur, err := p.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	products := make([]*domain.Product, 0, limit)
+	for cur.Next(ctx) {
+		var product domain.Product
+		if err := cur.Decode(&product); err != nil {
+			return nil, err
+		}
+		products = append(products, &product)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+
+	page := &domain.ProductPage{Items: products, TotalEstimate: total}
+	if len(products) > limit {
+		page.Items = products[:limit]
+		page.NextCursor = encodeCursor(domain.SortDistance, page.Items[limit-1])
+	}
+	return page, nil
+}
@@ -0,0 +1,172 @@
+package usecase
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/halilylm/gommon/rest"
+	"github.com/halilylm/secondhand/product/domain"
+	"github.com/halilylm/secondhand/product/imaging"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// AddImage stores the uploaded image and its thumbnail
+// and appends it to the images of the product
+func (p *product) AddImage(ctx context.Context, productID, userID string, data []byte) (*domain.Product, error) {
+	// find the product
+	foundProduct, err := p.editableProduct(ctx, productID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(foundProduct.Images) >= domain.MaxProductImages {
+		return nil, rest.NewBadRequestError(domain.ErrTooManyImages.Error())
+	}
+
+	// check the content and generate the thumbnail
+	processed, err := imaging.Process(data)
+	if err != nil {
+		return nil, rest.NewBadRequestError(err.Error())
+	}
+
+	// store the blobs
+	imageID := uuid.NewString()
+	image := &domain.Image{
+		ID:           imageID,
+		Key:          "products/" + productID + "/" + imageID,
+		ThumbnailKey: "products/" + productID + "/" + imageID + "_thumb",
+		ContentType:  processed.ContentType,
+		Size:         len(data),
+		Width:        processed.Width,
+		Height:       processed.Height,
+		CreatedAt:    time.Now().UTC(),
+	}
+	if err := p.blobStore.Put(ctx, image.Key, data, image.ContentType); err != nil {
+		p.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	if err := p.blobStore.Put(ctx, image.ThumbnailKey, processed.Thumbnail, imaging.ThumbnailContentType); err != nil {
+		p.logger.Error(err)
+		p.deleteImageBlobs(ctx, *image)
+		return nil, rest.NewInternalServerError()
+	}
+
+	// attach the image, blobs of images that
+	// couldn't be attached are orphans
+	updatedProduct, err := p.productRepo.AddImage(ctx, productID, image, domain.MaxProductImages)
+	if err != nil {
+		p.deleteImageBlobs(ctx, *image)
+		if err == mongo.ErrNoDocuments {
+			// reserved or reached the limit meanwhile
+			return nil, rest.NewBadRequestError(domain.ErrTooManyImages.Error())
+		}
+		p.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	return updatedProduct, nil
+}
+
+// RemoveImage detaches the image and deletes its blobs
+func (p *product) RemoveImage(ctx context.Context, productID, userID, imageID string) (*domain.Product, error) {
+	// find the product and the image
+	foundProduct, err := p.editableProduct(ctx, productID, userID)
+	if err != nil {
+		return nil, err
+	}
+	image, found := foundProduct.FindImage(imageID)
+	if !found {
+		return nil, rest.NewNotFoundError()
+	}
+
+	updatedProduct, err := p.productRepo.RemoveImage(ctx, productID, imageID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, rest.NewNotFoundError()
+		}
+		p.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+
+	p.deleteImageBlobs(ctx, *image)
+	return updatedProduct, nil
+}
+
+// ReorderImages changes the order images are listed in
+func (p *product) ReorderImages(ctx context.Context, productID, userID string, imageIDs []string) (*domain.Product, error) {
+	// find the product
+	foundProduct, err := p.editableProduct(ctx, productID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// ids must be a permutation of the current images
+	seen := make(map[string]struct{}, len(imageIDs))
+	for _, id := range imageIDs {
+		if _, found := foundProduct.FindImage(id); !found {
+			return nil, rest.NewBadRequestError("unknown image " + id)
+		}
+		seen[id] = struct{}{}
+	}
+	if len(seen) != len(imageIDs) || len(imageIDs) != len(foundProduct.Images) {
+		return nil, rest.NewBadRequestError("every image must be listed once")
+	}
+
+	updatedProduct, err := p.productRepo.ReorderImages(ctx, productID, imageIDs)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			// images changed meanwhile
+			return nil, rest.NewBadRequestError("every image must be listed once")
+		}
+		p.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	return updatedProduct, nil
+}
+
+// ImageContent opens the image or its thumbnail, images of hidden
+// listings are only shown to their sellers like the listings are.
+// The caller must close the returned reader
+func (p *product) ImageContent(ctx context.Context, productID, imageID, userID string, thumbnail bool) (io.ReadCloser, string, error) {
+	// find the product and the image
+	foundProduct, err := p.findProduct(ctx, productID)
+	if err != nil {
+		return nil, "", err
+	}
+	if foundProduct.ArchivedAt != nil {
+		return nil, "", rest.NewNotFoundError()
+	}
+	if foundProduct.Hidden() && foundProduct.UserID != userID {
+		return nil, "", rest.NewNotFoundError()
+	}
+	image, found := foundProduct.FindImage(imageID)
+	if !found {
+		return nil, "", rest.NewNotFoundError()
+	}
+
+	key, contentType := image.Key, image.ContentType
+	if thumbnail {
+		key, contentType = image.ThumbnailKey, imaging.ThumbnailContentType
+	}
+	content, err := p.blobStore.Get(ctx, key)
+	if err != nil {
+		if err == domain.ErrBlobNotFound {
+			return nil, "", rest.NewNotFoundError()
+		}
+		p.logger.Error(err)
+		return nil, "", rest.NewInternalServerError()
+	}
+	return content, contentType, nil
+}
+
+// deleteImageBlobs deletes originals and thumbnails,
+// failures are only logged since the images are gone already
+func (p *product) deleteImageBlobs(ctx context.Context, images ...domain.Image) {
+	for _, image := range images {
+		for _, key := range []string{image.Key, image.ThumbnailKey} {
+			if err := p.blobStore.Delete(ctx, key); err != nil {
+				p.logger.Error(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/halilylm/gommon/rest"
+	"github.com/halilylm/secondhand/product/domain"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ReserveProduct holds stock of the product for the order,
+// redelivered orders get the product as it is
+func (p *product) ReserveProduct(ctx context.Context, productID, orderID string, quantity int) (*domain.Product, error) {
+	reservedProduct, err := p.reserveProduct(ctx, productID, orderID, quantity)
+	switch err {
+	case domain.ErrOutOfStock:
+		return nil, rest.NewBadRequestError(err.Error())
+	case domain.ErrProductUnavailable:
+		return nil, rest.NewNotFoundError()
+	}
+	return reservedProduct, err
+}
+
+// reserveProduct holds the stock, products which cannot be reserved
+// are told apart with ErrOutOfStock and ErrProductUnavailable
+func (p *product) reserveProduct(ctx context.Context, productID, orderID string, quantity int) (*domain.Product, error) {
+	// orders of a single item don't send the quantity
+	if quantity <= 0 {
+		quantity = 1
+	}
+	reservation := &domain.Reservation{
+		OrderID:  orderID,
+		Quantity: quantity,
+	}
+
+	var reservedProduct *domain.Product
+	err := p.outbox.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		reservedProduct, err = p.productRepo.Reserve(ctx, productID, reservation)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				// nothing is written, checked below
+				return nil
+			}
+			p.logger.Error(err)
+			return rest.NewInternalServerError()
+		}
+		if err := p.publishUpdated(ctx, reservedProduct); err != nil {
+			return err
+		}
+
+		// watchers hear about the last item going
+		if reservedProduct.SoldOut() {
+			return p.publish(ctx, domain.ProductSoldOut, domain.ProductSoldOutEvent{
+				ID:      reservedProduct.ID,
+				Version: reservedProduct.Version,
+				Title:   reservedProduct.Title,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if reservedProduct == nil {
+		// either reserved already or out of stock
+		foundProduct, err := p.productRepo.FindByID(ctx, productID)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return nil, domain.ErrProductUnavailable
+			}
+			p.logger.Error(err)
+			return nil, rest.NewInternalServerError()
+		}
+		if _, found := foundProduct.FindReservation(orderID); found {
+			return foundProduct, nil
+		}
+		return nil, domain.ErrOutOfStock
+	}
+	p.recordStockRevision(ctx, reservedProduct, quantity, orderID)
+	return reservedProduct, nil
+}
+
+// ReleaseProduct gives the stock of the cancelled order back,
+// released or unknown orders get the product as it is
+func (p *product) ReleaseProduct(ctx context.Context, productID, orderID string) (*domain.Product, error) {
+	// find the product
+	foundProduct, err := p.findProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	reservation, found := foundProduct.FindReservation(orderID)
+	if !found || reservation.Sold {
+		return foundProduct, nil
+	}
+
+	var releasedProduct *domain.Product
+	err = p.outbox.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		releasedProduct, err = p.productRepo.Release(ctx, productID, reservation)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				// nothing is written, checked below
+				return nil
+			}
+			p.logger.Error(err)
+			return rest.NewInternalServerError()
+		}
+		return p.publishUpdated(ctx, releasedProduct)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if releasedProduct == nil {
+		// released or sold meanwhile
+		return p.findProduct(ctx, productID)
+	}
+	p.recordStockRevision(ctx, releasedProduct, -reservation.Quantity, orderID)
+	return releasedProduct, nil
+}
+
+// ReserveOrder holds stock of every product of the order or of none of
+// them, orders hear whether the order is reserved or rejected. Products
+// are checked before anything is written so rejected orders write nothing
+// but their rejection, stock taken meanwhile fails the transaction
+func (p *product) ReserveOrder(ctx context.Context, orderID string, items []domain.OrderItem) error {
+	return p.outbox.Transaction(ctx, func(ctx context.Context) error {
+		for _, item := range items {
+			if err := p.checkStock(ctx, item.ProductID, orderID, item.Quantity); err != nil {
+				if err != domain.ErrOutOfStock && err != domain.ErrProductUnavailable {
+					return err
+				}
+				return p.publish(ctx, domain.OrderRejected, domain.OrderRejectedEvent{
+					ID:     orderID,
+					Reason: err.Error() + ": " + item.ProductID,
+				})
+			}
+		}
+		for _, item := range items {
+			if _, err := p.reserveProduct(ctx, item.ProductID, orderID, item.Quantity); err != nil {
+				if err == domain.ErrOutOfStock || err == domain.ErrProductUnavailable {
+					p.logger.Error(err)
+					return rest.NewInternalServerError()
+				}
+				return err
+			}
+		}
+		return p.publish(ctx, domain.OrderReserved, domain.OrderReservedEvent{ID: orderID})
+	})
+}
+
+// checkStock tells whether the product can cover the order with
+// ErrOutOfStock and ErrProductUnavailable, reserved orders can
+func (p *product) checkStock(ctx context.Context, productID, orderID string, quantity int) error {
+	// orders of a single item don't send the quantity
+	if quantity <= 0 {
+		quantity = 1
+	}
+	foundProduct, err := p.productRepo.FindByID(ctx, productID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return domain.ErrProductUnavailable
+		}
+		p.logger.Error(err)
+		return rest.NewInternalServerError()
+	}
+	if _, found := foundProduct.FindReservation(orderID); found {
+		return nil
+	}
+	if foundProduct.Available < quantity {
+		return domain.ErrOutOfStock
+	}
+	return nil
+}
+
+// ReleaseOrder gives the stock of every product of the cancelled order back
+func (p *product) ReleaseOrder(ctx context.Context, orderID string, items []domain.OrderItem) error {
+	for _, item := range items {
+		if _, err := p.ReleaseProduct(ctx, item.ProductID, orderID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SellProduct keeps the reservations of the paid order for good
+func (p *product) SellProduct(ctx context.Context, orderID string) error {
+	if err := p.productRepo.MarkSold(ctx, orderID); err != nil {
+		p.logger.Error(err)
+		if err == mongo.ErrNoDocuments {
+			return rest.NewNotFoundError()
+		}
+		return rest.NewInternalServerError()
+	}
+	return nil
+}
+
+// recordStockRevision records the stock taken by the order, reservations
+// only change the stock so the previous version is derived from the product
+func (p *product) recordStockRevision(ctx context.Context, product *domain.Product, taken int, orderID string) {
+	previous := *product
+	previous.Available += taken
+	previous.Version--
+	p.recordRevision(ctx, &previous, product, domain.OrderActor(orderID))
+}
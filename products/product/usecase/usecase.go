@@ -2,87 +2,466 @@ package usecase
 
 import (
 	"context"
-	"encoding/json"
-	"github.com/halilylm/gommon/events"
 	"github.com/halilylm/gommon/events/common/messages"
 	"github.com/halilylm/gommon/logger"
 	"github.com/halilylm/gommon/rest"
+	"github.com/halilylm/secondhand/messaging/outbox"
 	"github.com/halilylm/secondhand/product/domain"
 	"go.mongodb.org/mongo-driver/mongo"
+	"io"
+	"strings"
+	"time"
 )
 
 type product struct {
-	productRepo domain.ProductRepository
-	logger      logger.Logger
-	streaming   events.Streaming
+	productRepo  domain.ProductRepository
+	categoryRepo domain.CategoryRepository
+	sellerRepo   domain.SellerRepository
+	historyRepo  domain.HistoryRepository
+	blobStore    domain.BlobStore
+	rules        domain.ModerationRules
+	logger       logger.Logger
+	outbox       outbox.Store
 }
 
-func NewProduct(productRepo domain.ProductRepository, logger logger.Logger, streaming events.Streaming) Product {
-	return &product{productRepo: productRepo, logger: logger, streaming: streaming}
+func NewProduct(
+	productRepo domain.ProductRepository,
+	categoryRepo domain.CategoryRepository,
+	sellerRepo domain.SellerRepository,
+	historyRepo domain.HistoryRepository,
+	blobStore domain.BlobStore,
+	rules domain.ModerationRules,
+	logger logger.Logger,
+	outbox outbox.Store,
+) Product {
+	return &product{
+		productRepo:  productRepo,
+		categoryRepo: categoryRepo,
+		sellerRepo:   sellerRepo,
+		historyRepo:  historyRepo,
+		blobStore:    blobStore,
+		rules:        rules,
+		logger:       logger,
+		outbox:       outbox,
+	}
 }
 
 func (p *product) NewProduct(ctx context.Context, product *domain.Product) (*domain.Product, error) {
-	createdTicket, err := p.productRepo.Insert(ctx, product)
+	// check category, attributes and tags
+	if err := p.classify(ctx, product); err != nil {
+		return nil, err
+	}
+
+	// a single item unless told otherwise
+	if product.Quantity == 0 {
+		product.Quantity = 1
+	}
+
+	// check the auction if the product is sold by one
+	if err := prepareListing(product, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+
+	// check the delivery options against the location
+	if err := domain.PrepareLocation(product); err != nil {
+		return nil, rest.NewBadRequestError(err.Error())
+	}
+	// distances are measured for listings only
+	product.Distance = nil
+
+	// listings of new sellers or with blocked
+	// keywords wait for a moderator
+	publishedListings, err := p.productRepo.CountPublishedBySeller(ctx, product.UserID)
 	if err != nil {
 		p.logger.Error(err)
 		return nil, rest.NewInternalServerError()
 	}
-	msg := messages.ProductCreatedEvent{
-		ID:      createdTicket.ID,
-		Version: createdTicket.Version,
-		Title:   createdTicket.Title,
-		Price:   createdTicket.Price,
-		UserID:  createdTicket.UserID,
+	product.Moderation = p.rules.Evaluate(product, publishedListings)
+
+	var createdTicket *domain.Product
+	err = p.outbox.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		createdTicket, err = p.productRepo.Insert(ctx, product)
+		if err != nil {
+			p.logger.Error(err)
+			return rest.NewInternalServerError()
+		}
+		return p.publish(ctx, messages.ProductCreated, domain.NewProductCreatedEvent(createdTicket))
+	})
+	if err != nil {
+		return nil, err
 	}
-	encodedMsg, err := json.Marshal(msg)
+	p.recordRevision(ctx, nil, createdTicket, createdTicket.UserID)
+	return createdTicket, nil
+}
+
+func (p *product) UpdateProduct(ctx context.Context, product *domain.Product, actorID string) (*domain.Product, error) {
+	// the stored product tells what changed
+	previousTicket, err := p.findProduct(ctx, product.ID)
 	if err != nil {
-		p.logger.Error(err)
+		return nil, err
+	}
+
+	// the update matched the version so the stored
+	// product was the one right before it
+	previousMatched := previousTicket.Version == product.Version
+
+	var updatedTicket *domain.Product
+	err = p.outbox.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		updatedTicket, err = p.productRepo.Update(ctx, product)
+		if err != nil {
+			p.logger.Error(err)
+			if err == mongo.ErrNoDocuments {
+				return rest.NewNotFoundError()
+			}
+			return rest.NewInternalServerError()
+		}
+		if err := p.publishUpdated(ctx, updatedTicket); err != nil {
+			return err
+		}
+		if previousMatched && updatedTicket.Price < previousTicket.Price {
+			return p.publish(ctx, domain.ProductPriceDropped, domain.ProductPriceDroppedEvent{
+				ID:       updatedTicket.ID,
+				Version:  updatedTicket.Version,
+				Title:    updatedTicket.Title,
+				OldPrice: previousTicket.Price,
+				Price:    updatedTicket.Price,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if previousMatched {
+		p.recordRevision(ctx, previousTicket, updatedTicket, actorID)
 	}
-	if err := p.streaming.Publish(messages.ProductCreated, encodedMsg); err != nil {
+	return updatedTicket, nil
+}
+
+// recordRevision appends the changes of the product to its history,
+// the change is stored whatever happens to its revision
+func (p *product) recordRevision(ctx context.Context, previous, current *domain.Product, actorID string) {
+	revision := domain.NewProductRevision(previous, current, actorID)
+	if err := p.historyRepo.Insert(ctx, revision); err != nil {
 		p.logger.Error(err)
 	}
-	return createdTicket, nil
 }
 
-func (p *product) UpdateProduct(ctx context.Context, product *domain.Product) (*domain.Product, error) {
-	updatedTicket, err := p.productRepo.Update(ctx, product)
+// ProductHistory returns every recorded version of the product, the
+// history of hidden listings and who made the changes are only shown
+// to the seller and admins
+func (p *product) ProductHistory(ctx context.Context, id, userID string, admin bool) ([]*domain.ProductRevision, error) {
+	foundProduct, err := p.findProduct(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	privileged := admin || foundProduct.UserID == userID
+	if foundProduct.Hidden() && !privileged {
+		return nil, rest.NewNotFoundError()
+	}
+	revisions, err := p.historyRepo.ListByProduct(ctx, id)
 	if err != nil {
 		p.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	// actors name moderators and the orders of buyers
+	if !privileged {
+		for _, revision := range revisions {
+			revision.ActorID = ""
+		}
+	}
+	return revisions, nil
+}
+
+// publishUpdated tells replicas about the new version of the product
+func (p *product) publishUpdated(ctx context.Context, product *domain.Product) error {
+	return p.publish(ctx, messages.ProductUpdated, domain.NewProductUpdatedEvent(product))
+}
+
+// publish adds the event to the outbox, it must be called in the
+// transaction of the change so both are stored or neither is
+func (p *product) publish(ctx context.Context, subject string, msg any) error {
+	if err := p.outbox.Add(ctx, subject, msg); err != nil {
+		p.logger.Error(err)
+		return rest.NewInternalServerError()
+	}
+	return nil
+}
+
+// EditProduct applies the seller's changes to the product
+func (p *product) EditProduct(ctx context.Context, id, userID string, update *domain.ProductUpdate) (*domain.Product, error) {
+	// find the product
+	foundProduct, err := p.editableProduct(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the stock cannot go below what orders hold
+	if update.Quantity != 0 {
+		if update.Quantity < foundProduct.Reserved() {
+			return nil, rest.NewBadRequestError(domain.ErrQuantityBelowReserved.Error())
+		}
+		foundProduct.Available = update.Quantity - foundProduct.Reserved()
+		foundProduct.Quantity = update.Quantity
+	}
+
+	// apply whitelisted fields only,
+	// reservations and version stay as they are
+	foundProduct.Title = update.Title
+	foundProduct.Price = update.Price
+	if foundProduct.IsAuction() {
+		// auctions are listed at their start price
+		foundProduct.Price = foundProduct.Auction.StartPrice
+	}
+	foundProduct.CategoryID = update.CategoryID
+	foundProduct.Tags = update.Tags
+	foundProduct.Attributes = update.Attributes
+	if update.Location != nil {
+		foundProduct.Location = update.Location
+	}
+	if update.Delivery != nil {
+		foundProduct.Delivery = update.Delivery
+	}
+
+	// check the delivery options against the location
+	if err := domain.PrepareLocation(foundProduct); err != nil {
+		return nil, rest.NewBadRequestError(err.Error())
+	}
+
+	// check category, attributes and tags
+	if err := p.classify(ctx, foundProduct); err != nil {
+		return nil, err
+	}
+
+	// edited listings with blocked keywords wait for a moderator again
+	if keyword, found := p.rules.BlockedKeyword(foundProduct); found {
+		foundProduct.Moderation.Status = domain.ModerationPending
+		foundProduct.Moderation.Reason = "blocked keyword: " + keyword
+	}
+
+	return p.UpdateProduct(ctx, foundProduct, userID)
+}
+
+// prepareListing checks the auction of the product and opens it,
+// auctions sell a single item listed at the start price
+func prepareListing(product *domain.Product, now time.Time) error {
+	if product.Listing == "" {
+		product.Listing = domain.ListingFixed
+	}
+	if product.Listing == domain.ListingFixed {
+		product.Auction = nil
+		return nil
+	}
+
+	auction := product.Auction
+	switch {
+	case auction == nil:
+		return rest.NewBadRequestError(domain.ErrInvalidAuction.Error() + ", auction is required")
+	case product.Quantity != 1:
+		return rest.NewBadRequestError(domain.ErrInvalidAuction.Error() + ", auctions sell a single item")
+	case auction.EndsAt.Before(now.Add(domain.AuctionExtension)):
+		return rest.NewBadRequestError(domain.ErrInvalidAuction.Error() + ", auction ends too soon")
+	case auction.EndsAt.After(now.Add(domain.MaxAuctionDuration)):
+		return rest.NewBadRequestError(domain.ErrInvalidAuction.Error() + ", auction runs too long")
+	}
+	auction.EndsAt = auction.EndsAt.UTC()
+	auction.Status = domain.AuctionOpen
+	auction.HighestBid = nil
+	auction.BidCount = 0
+	auction.ClosedAt = nil
+	product.Price = auction.StartPrice
+	return nil
+}
+
+// classify validates the attributes of the product against
+// its category schema and normalizes the tags
+func (p *product) classify(ctx context.Context, product *domain.Product) error {
+	category, err := p.findCategory(ctx, product.CategoryID)
+	if err != nil {
+		return err
+	}
+	attributes, err := category.ValidateAttributes(product.Attributes)
+	if err != nil {
+		return rest.NewBadRequestError(err.Error())
+	}
+	product.Attributes = attributes
+	product.Tags = domain.NormalizeTags(product.Tags)
+	return nil
+}
+
+// findCategory returns bad request for unknown categories
+// since they come from the request body or query
+func (p *product) findCategory(ctx context.Context, id string) (*domain.Category, error) {
+	category, err := p.categoryRepo.FindByID(ctx, id)
+	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, rest.NewNotFoundError()
+			return nil, rest.NewBadRequestError("category not found")
 		}
+		p.logger.Error(err)
 		return nil, rest.NewInternalServerError()
 	}
-	msg := messages.ProductUpdatedEvent{
-		ID:      updatedTicket.ID,
-		Version: updatedTicket.Version,
-		Title:   updatedTicket.Title,
-		Price:   updatedTicket.Price,
-		UserID:  updatedTicket.UserID,
+	return category, nil
+}
+
+// DeleteProduct archives the seller's product
+// and tells other services it cannot be ordered
+func (p *product) DeleteProduct(ctx context.Context, id, userID string) error {
+	// find the product
+	foundProduct, err := p.editableProduct(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+
+	// orders waiting for payment keep the product
+	if foundProduct.HasPendingReservations() {
+		return rest.NewBadRequestError(domain.ErrProductReserved.Error())
 	}
-	encodedMsg, err := json.Marshal(msg)
+
+	var archivedProduct *domain.Product
+	err = p.outbox.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		archivedProduct, err = p.productRepo.Archive(ctx, foundProduct)
+		if err != nil {
+			p.logger.Error(err)
+			if err == mongo.ErrNoDocuments {
+				// reserved or changed meanwhile
+				return rest.NewBadRequestError(domain.ErrProductReserved.Error())
+			}
+			return rest.NewInternalServerError()
+		}
+		return p.publish(ctx, domain.ProductDeleted, domain.ProductDeletedEvent{
+			ID:      archivedProduct.ID,
+			Version: archivedProduct.Version,
+		})
+	})
 	if err != nil {
-		p.logger.Error(err)
+		return err
 	}
-	if err := p.streaming.Publish(messages.ProductUpdated, encodedMsg); err != nil {
-		p.logger.Error(err)
+	p.recordRevision(ctx, foundProduct, archivedProduct, userID)
+
+	// images of deleted products are not served anymore
+	p.deleteImageBlobs(ctx, archivedProduct.Images...)
+	return nil
+}
+
+// editableProduct finds the product the seller wants to change,
+// archived products are gone and sold out ones are frozen
+func (p *product) editableProduct(ctx context.Context, id, userID string) (*domain.Product, error) {
+	// find the product
+	foundProduct, err := p.findProduct(ctx, id)
+	if err != nil {
+		return nil, err
 	}
-	return updatedTicket, nil
+
+	// only the seller can change the product
+	if foundProduct.UserID != userID {
+		return nil, rest.NewUnauthorizedError()
+	}
+
+	// archived products are gone for the seller
+	if foundProduct.ArchivedAt != nil {
+		return nil, rest.NewNotFoundError()
+	}
+
+	// moderators have the last word on rejected products
+	if foundProduct.Moderated() {
+		return nil, rest.NewBadRequestError(domain.ErrListingModerated.Error())
+	}
+
+	// sold out products cannot be changed
+	if foundProduct.SoldOut() {
+		return nil, rest.NewBadRequestError(domain.ErrProductReserved.Error())
+	}
+
+	// bidders rely on the auction as it is
+	if foundProduct.IsAuction() && foundProduct.Auction.BidCount > 0 {
+		return nil, rest.NewBadRequestError(domain.ErrAuctionHasBids.Error())
+	}
+
+	return foundProduct, nil
 }
 
-func (p *product) AvailableProducts(ctx context.Context) ([]*domain.Product, error) {
-	availableTickets, err := p.productRepo.AvailableProducts(ctx)
+func (p *product) AvailableProducts(ctx context.Context, query *domain.ProductQuery) (*domain.ProductPage, error) {
+	// resolve the category tree and attribute types
+	if query.Category != "" {
+		category, err := p.findCategory(ctx, query.Category)
+		if err != nil {
+			return nil, err
+		}
+		query.CategoryIDs, err = p.categoryRepo.SubtreeIDs(ctx, category.ID)
+		if err != nil {
+			p.logger.Error(err)
+			return nil, rest.NewInternalServerError()
+		}
+		query.Attributes, err = category.ParseAttributeFilters(query.Attributes)
+		if err != nil {
+			return nil, rest.NewBadRequestError(err.Error())
+		}
+	} else if len(query.Attributes) > 0 {
+		return nil, rest.NewBadRequestError("attribute filters require a category")
+	}
+	query.Tag = strings.ToLower(strings.TrimSpace(query.Tag))
+	if query.MinPrice != nil && query.MaxPrice != nil && *query.MinPrice > *query.MaxPrice {
+		return nil, rest.NewBadRequestError(domain.ErrInvalidPriceRange.Error())
+	}
+
+	// parse the near point, searches around it
+	// are nearest first unless sorted otherwise
+	if query.Near != "" {
+		origin, err := domain.ParseNear(query.Near)
+		if err != nil {
+			return nil, rest.NewBadRequestError(err.Error())
+		}
+		query.Origin = origin
+		if query.Radius == 0 {
+			query.Radius = domain.DefaultSearchRadius
+		}
+		if query.Sort == "" && query.Search == "" {
+			query.Sort = domain.SortDistance
+		}
+	} else if query.Radius != 0 || query.Sort == domain.SortDistance {
+		return nil, rest.NewBadRequestError(domain.ErrNearRequired.Error())
+	}
+	if query.Sort == domain.SortDistance && query.Search != "" {
+		return nil, rest.NewBadRequestError(domain.ErrDistanceSearch.Error())
+	}
+
+	availableTickets, err := p.productRepo.AvailableProducts(ctx, query)
 	if err != nil {
+		if err == domain.ErrInvalidCursor {
+			return nil, rest.NewBadRequestError(err.Error())
+		}
 		p.logger.Error(err)
 		if err == mongo.ErrNoDocuments {
 			return nil, rest.NewNotFoundError()
 		}
 		return nil, rest.NewInternalServerError()
 	}
+	p.attachSellers(ctx, availableTickets.Items...)
+	if query.Origin != nil {
+		attachDistances(*query.Origin, availableTickets.Items...)
+	}
 	return availableTickets, nil
 }
 
-func (p *product) ShowProduct(ctx context.Context, id string) (*domain.Product, error) {
+// ShowProduct returns the product, hidden listings to their sellers only
+func (p *product) ShowProduct(ctx context.Context, id, userID string) (*domain.Product, error) {
+	ticket, err := p.findProduct(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// listings waiting for or rejected by moderators
+	// are only shown to their sellers
+	if ticket.Hidden() && ticket.UserID != userID {
+		return nil, rest.NewNotFoundError()
+	}
+	p.attachSellers(ctx, ticket)
+	return ticket, nil
+}
+
+func (p *product) findProduct(ctx context.Context, id string) (*domain.Product, error) {
 	ticket, err := p.productRepo.FindByID(ctx, id)
 	if err != nil {
 		p.logger.Error(err)
@@ -94,10 +473,54 @@ func (p *product) ShowProduct(ctx context.Context, id string) (*domain.Product,
 	return ticket, nil
 }
 
+// attachSellers sets the scores of the sellers on the products,
+// products are still shown when scores cannot be read
+func (p *product) attachSellers(ctx context.Context, products ...*domain.Product) {
+	if len(products) == 0 {
+		return
+	}
+	sellerIDs := make([]string, 0, len(products))
+	for _, product := range products {
+		sellerIDs = append(sellerIDs, product.UserID)
+	}
+	scores, err := p.sellerRepo.FindScores(ctx, sellerIDs)
+	if err != nil {
+		p.logger.Error(err)
+		return
+	}
+	for _, product := range products {
+		product.Seller = scores[product.UserID]
+	}
+}
+
+// attachDistances sets the distance from the origin on products
+// the repository did not measure, products are within the radius
+// so they all have a location
+func attachDistances(origin domain.GeoPoint, products ...*domain.Product) {
+	for _, product := range products {
+		if product.Distance == nil && product.Location != nil {
+			distance := domain.DistanceKm(origin, product.Location.Point)
+			product.Distance = &distance
+		}
+	}
+}
+
 // Product contract
 type Product interface {
 	NewProduct(ctx context.Context, product *domain.Product) (*domain.Product, error)
-	UpdateProduct(ctx context.Context, product *domain.Product) (*domain.Product, error)
-	AvailableProducts(ctx context.Context) ([]*domain.Product, error)
-	ShowProduct(ctx context.Context, id string) (*domain.Product, error)
+	UpdateProduct(ctx context.Context, product *domain.Product, actorID string) (*domain.Product, error)
+	EditProduct(ctx context.Context, id, userID string, update *domain.ProductUpdate) (*domain.Product, error)
+	DeleteProduct(ctx context.Context, id, userID string) error
+	AddImage(ctx context.Context, productID, userID string, data []byte) (*domain.Product, error)
+	RemoveImage(ctx context.Context, productID, userID, imageID string) (*domain.Product, error)
+	ReorderImages(ctx context.Context, productID, userID string, imageIDs []string) (*domain.Product, error)
+	ImageContent(ctx context.Context, productID, imageID, userID string, thumbnail bool) (io.ReadCloser, string, error)
+	ReserveProduct(ctx context.Context, productID, orderID string, quantity int) (*domain.Product, error)
+	ReleaseProduct(ctx context.Context, productID, orderID string) (*domain.Product, error)
+	ReserveOrder(ctx context.Context, orderID string, items []domain.OrderItem) error
+	ReleaseOrder(ctx context.Context, orderID string, items []domain.OrderItem) error
+	SellProduct(ctx context.Context, orderID string) error
+	AvailableProducts(ctx context.Context, query *domain.ProductQuery) (*domain.ProductPage, error)
+	ShowProduct(ctx context.Context, id, userID string) (*domain.Product, error)
+	ProductHistory(ctx context.Context, id, userID string, admin bool) ([]*domain.ProductRevision, error)
 }
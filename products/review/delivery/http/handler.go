@@ -0,0 +1,86 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/halilylm/gommon/middlewares"
+	"github.com/halilylm/gommon/rest"
+	"github.com/halilylm/gommon/utils"
+	"github.com/halilylm/secondhand/product/domain"
+	"github.com/halilylm/secondhand/product/review/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+type reviewHandler struct {
+	reviewUC usecase.Review
+}
+
+// NewReviewHandler handler for reviews and seller profiles,
+// the group must already use the jwt middleware
+func NewReviewHandler(g *echo.Group, reviewUC usecase.Review) {
+	handler := &reviewHandler{reviewUC: reviewUC}
+
+	g.POST("/reviews", handler.ReviewSeller)
+	g.PUT("/reviews/:id/response", handler.RespondToReview)
+	g.GET("/sellers/:id", handler.SellerProfile)
+}
+
+func (h *reviewHandler) ReviewSeller(c echo.Context) error {
+	var request domain.ReviewRequest
+
+	// bind request body to review request
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewBadRequestError(err.Error())))
+	}
+
+	// validate the struct
+	if err := utils.ValidateStruct(&request); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewValidationErrors(err)))
+	}
+
+	// get user from the context
+	user := middlewares.UserFromContext(c)
+
+	// call the usecase
+	createdReview, err := h.reviewUC.ReviewSeller(c.Request().Context(), user.ID, &request)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusCreated, createdReview)
+}
+
+func (h *reviewHandler) RespondToReview(c echo.Context) error {
+	var response domain.ReviewResponse
+
+	// bind request body to response
+	if err := c.Bind(&response); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewBadRequestError(err.Error())))
+	}
+
+	// validate the struct
+	if err := utils.ValidateStruct(&response); err != nil {
+		return c.JSON(rest.ErrorResponse(rest.NewValidationErrors(err)))
+	}
+
+	// get user from the context
+	user := middlewares.UserFromContext(c)
+
+	// call the usecase
+	updatedReview, err := h.reviewUC.RespondToReview(c.Request().Context(), c.Param("id"), user.ID, &response)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, updatedReview)
+}
+
+func (h *reviewHandler) SellerProfile(c echo.Context) error {
+	// call the usecase
+	profile, err := h.reviewUC.SellerProfile(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, profile)
+}
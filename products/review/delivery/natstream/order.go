@@ -0,0 +1,45 @@
+package natstream
+
+import (
+	"context"
+	"github.com/halilylm/secondhand/messaging/consumer"
+	"github.com/halilylm/secondhand/messaging/inbox"
+	"github.com/halilylm/secondhand/product/domain"
+	"github.com/halilylm/secondhand/product/review/usecase"
+)
+
+// OrderConsumerGroup keeps the replica of completed orders
+type OrderConsumerGroup struct {
+	reviewUC usecase.Review
+	inbox    inbox.Store
+	groupID  string
+}
+
+func NewOrderConsumerGroup(
+	reviewUC usecase.Review,
+	inbox inbox.Store,
+	groupID string,
+) *OrderConsumerGroup {
+	return &OrderConsumerGroup{
+		reviewUC: reviewUC,
+		inbox:    inbox,
+		groupID:  groupID,
+	}
+}
+
+func (ocg *OrderConsumerGroup) GroupID() string {
+	return ocg.groupID
+}
+
+func (ocg *OrderConsumerGroup) Subscriptions() []consumer.Subscription {
+	return []consumer.Subscription{
+		consumer.On(domain.OrderCompleted, ocg.completedOrder),
+	}
+}
+
+func (ocg *OrderConsumerGroup) completedOrder(ctx context.Context, eventID string, deliveredEvent *domain.OrderCompletedEvent) error {
+	return ocg.inbox.Process(ctx, ocg.groupID, eventID, func(ctx context.Context) error {
+		_, err := ocg.reviewUC.CompleteOrder(ctx, deliveredEvent)
+		return err
+	})
+}
@@ -0,0 +1,59 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/halilylm/secondhand/product/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type orderRepository struct {
+	collection *mongo.Collection
+}
+
+// NewOrderRepository returns a new mongo order replica repository
+func NewOrderRepository(collection *mongo.Collection) domain.OrderRepository {
+	return &orderRepository{collection: collection}
+}
+
+// Upsert stores the order with its seller, sellers completed
+// on their own are added and older versions never replace newer ones
+func (o *orderRepository) Upsert(ctx context.Context, order *domain.Order) (*domain.Order, error) {
+	filter := bson.M{
+		"_id":     order.ID,
+		"version": bson.M{"$lte": order.Version},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"buyer_id": order.BuyerID,
+			"status":   order.Status,
+			"version":  order.Version,
+		},
+		"$addToSet": bson.M{"sellers": bson.M{"$each": order.Sellers}},
+	}
+	_, err := o.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// the order was stored meanwhile, by the event of another seller
+		// of the order or by a newer version which is left as it is
+		_, err = o.collection.UpdateOne(ctx, filter, update)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// FindByID finds an order by its id
+func (o *orderRepository) FindByID(ctx context.Context, id string) (*domain.Order, error) {
+	var foundOrder domain.Order
+	res := o.collection.FindOne(ctx, bson.M{"_id": id})
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&foundOrder); err != nil {
+		return nil, err
+	}
+	return &foundOrder, nil
+}
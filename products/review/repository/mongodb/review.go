@@ -0,0 +1,110 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/halilylm/secondhand/product/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type reviewRepository struct {
+	collection *mongo.Collection
+}
+
+// NewReviewRepository returns a new mongo review repository
+func NewReviewRepository(collection *mongo.Collection) domain.ReviewRepository {
+	return &reviewRepository{collection: collection}
+}
+
+// CreateReviewIndexes creates the indexes reviews rely on,
+// each seller of an order is reviewed once
+func CreateReviewIndexes(ctx context.Context, collection *mongo.Collection) error {
+	// orders were reviewed once before they had several sellers
+	if _, err := collection.Indexes().DropOne(ctx, "order_id_1"); err != nil && !isIndexNotFound(err) {
+		return err
+	}
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "order_id", Value: 1}, {Key: "seller_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "seller_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+	return err
+}
+
+// isIndexNotFound tells the index or the collection doesn't exist yet
+func isIndexNotFound(err error) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && (cmdErr.Name == "IndexNotFound" || cmdErr.Name == "NamespaceNotFound")
+}
+
+// Insert creates a new review in mongodb,
+// a second review of the seller of the order is rejected
+func (r *reviewRepository) Insert(ctx context.Context, review *domain.Review) (*domain.Review, error) {
+	review.ID = uuid.NewString()
+	if _, err := r.collection.InsertOne(ctx, review); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, domain.ErrAlreadyReviewed
+		}
+		return nil, err
+	}
+	return review, nil
+}
+
+// FindByID finds a review by its id
+func (r *reviewRepository) FindByID(ctx context.Context, id string) (*domain.Review, error) {
+	var foundReview domain.Review
+	res := r.collection.FindOne(ctx, bson.M{"_id": id})
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&foundReview); err != nil {
+		return nil, err
+	}
+	return &foundReview, nil
+}
+
+// Respond sets the response of the seller
+func (r *reviewRepository) Respond(ctx context.Context, id string, response *domain.ReviewResponse) (*domain.Review, error) {
+	var updatedReview domain.Review
+	res := r.collection.FindOneAndUpdate(ctx, bson.M{
+		"_id": id,
+	}, bson.M{"$set": bson.M{
+		"response": response,
+	}}, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&updatedReview); err != nil {
+		return nil, err
+	}
+	return &updatedReview, nil
+}
+
+// ListBySeller returns the latest reviews of the seller
+func (r *reviewRepository) ListBySeller(ctx context.Context, sellerID string, limit int) ([]*domain.Review, error) {
+	reviews := make([]*domain.Review, 0)
+	cur, err := r.collection.Find(ctx, bson.M{
+		"seller_id": sellerID,
+	}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var review domain.Review
+		if err := cur.Decode(&review); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, &review)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
@@ -0,0 +1,74 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/halilylm/secondhand/product/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type sellerRepository struct {
+	collection *mongo.Collection
+}
+
+// NewSellerRepository returns a new mongo seller score repository
+func NewSellerRepository(collection *mongo.Collection) domain.SellerRepository {
+	return &sellerRepository{collection: collection}
+}
+
+// AddRating counts the rating in the score of the seller
+func (s *sellerRepository) AddRating(ctx context.Context, sellerID string, rating int) error {
+	_, err := s.collection.UpdateOne(ctx, bson.M{
+		"_id": sellerID,
+	}, bson.M{"$inc": bson.M{
+		"review_count": 1,
+		"rating_total": rating,
+	}}, options.Update().SetUpsert(true))
+	return err
+}
+
+// FindScore returns the score of the seller,
+// sellers without reviews have an empty score
+func (s *sellerRepository) FindScore(ctx context.Context, sellerID string) (*domain.SellerScore, error) {
+	score := domain.SellerScore{SellerID: sellerID}
+	res := s.collection.FindOne(ctx, bson.M{"_id": sellerID})
+	if res.Err() != nil && res.Err() != mongo.ErrNoDocuments {
+		return nil, res.Err()
+	}
+	if res.Err() == nil {
+		if err := res.Decode(&score); err != nil {
+			return nil, err
+		}
+	}
+	score.Compute()
+	return &score, nil
+}
+
+// FindScores returns the scores of the sellers by their ids
+func (s *sellerRepository) FindScores(ctx context.Context, sellerIDs []string) (map[string]*domain.SellerScore, error) {
+	scores := make(map[string]*domain.SellerScore, len(sellerIDs))
+	for _, sellerID := range sellerIDs {
+		scores[sellerID] = &domain.SellerScore{SellerID: sellerID}
+	}
+	cur, err := s.collection.Find(ctx, bson.M{"_id": bson.M{"$in": sellerIDs}})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var score domain.SellerScore
+		if err := cur.Decode(&score); err != nil {
+			return nil, err
+		}
+		scores[score.SellerID] = &score
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	for _, score := range scores {
+		score.Compute()
+	}
+	return scores, nil
+}
@@ -0,0 +1,180 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/halilylm/gommon/events/common/types"
+	"github.com/halilylm/gommon/logger"
+	"github.com/halilylm/gommon/rest"
+	"github.com/halilylm/secondhand/messaging/outbox"
+	"github.com/halilylm/secondhand/product/domain"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// profileReviewLimit caps the reviews shown on a seller profile
+const profileReviewLimit = 20
+
+type review struct {
+	reviewRepo  domain.ReviewRepository
+	orderRepo   domain.OrderRepository
+	sellerRepo  domain.SellerRepository
+	productRepo domain.ProductRepository
+	logger      logger.Logger
+	outbox      outbox.Store
+}
+
+// NewReview returns review usecase
+func NewReview(
+	reviewRepo domain.ReviewRepository,
+	orderRepo domain.OrderRepository,
+	sellerRepo domain.SellerRepository,
+	productRepo domain.ProductRepository,
+	logger logger.Logger,
+	outbox outbox.Store,
+) Review {
+	return &review{
+		reviewRepo:  reviewRepo,
+		orderRepo:   orderRepo,
+		sellerRepo:  sellerRepo,
+		productRepo: productRepo,
+		logger:      logger,
+		outbox:      outbox,
+	}
+}
+
+// CompleteOrder keeps the replica of the completed order with
+// the seller, orders of a single product name the seller of it
+func (r *review) CompleteOrder(ctx context.Context, order *domain.OrderCompletedEvent) (*domain.Order, error) {
+	sellerID := order.SellerID
+	if sellerID == "" {
+		product, err := r.productRepo.FindByID(ctx, order.ProductID)
+		if err != nil {
+			r.logger.Error(err)
+			if err == mongo.ErrNoDocuments {
+				return nil, rest.NewNotFoundError()
+			}
+			return nil, rest.NewInternalServerError()
+		}
+		sellerID = product.UserID
+	}
+
+	completedOrder, err := r.orderRepo.Upsert(ctx, &domain.Order{
+		ID:      order.ID,
+		BuyerID: order.UserID,
+		Sellers: []domain.OrderSeller{{SellerID: sellerID, ProductID: order.ProductID}},
+		Status:  types.Complete,
+		Version: order.Version,
+	})
+	if err != nil {
+		r.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	return completedOrder, nil
+}
+
+// ReviewSeller rates a seller of a completed order of the buyer
+func (r *review) ReviewSeller(ctx context.Context, buyerID string, request *domain.ReviewRequest) (*domain.Review, error) {
+	// find the order, orders are known once completed
+	order, err := r.orderRepo.FindByID(ctx, request.OrderID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, rest.NewBadRequestError(domain.ErrOrderNotComplete.Error())
+		}
+		r.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	if order.BuyerID != buyerID {
+		return nil, rest.NewUnauthorizedError()
+	}
+	if order.Status != types.Complete {
+		return nil, rest.NewBadRequestError(domain.ErrOrderNotComplete.Error())
+	}
+
+	// orders of several sellers name the reviewed one
+	seller, found := order.FindSeller(request.SellerID)
+	if !found {
+		return nil, rest.NewBadRequestError(domain.ErrUnknownOrderSeller.Error())
+	}
+
+	// the score of the seller counts every stored review
+	var createdReview *domain.Review
+	err = r.outbox.Transaction(ctx, func(ctx context.Context) error {
+		createdReview, err = r.reviewRepo.Insert(ctx, &domain.Review{
+			OrderID:   order.ID,
+			ProductID: seller.ProductID,
+			SellerID:  seller.SellerID,
+			BuyerID:   buyerID,
+			Rating:    request.Rating,
+			Comment:   request.Comment,
+			CreatedAt: time.Now().UTC(),
+		})
+		if err != nil {
+			if err == domain.ErrAlreadyReviewed {
+				return rest.NewBadRequestError(err.Error())
+			}
+			r.logger.Error(err)
+			return rest.NewInternalServerError()
+		}
+		if err := r.sellerRepo.AddRating(ctx, seller.SellerID, request.Rating); err != nil {
+			r.logger.Error(err)
+			return rest.NewInternalServerError()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return createdReview, nil
+}
+
+// RespondToReview sets the answer of the seller to the review
+func (r *review) RespondToReview(ctx context.Context, reviewID, sellerID string, response *domain.ReviewResponse) (*domain.Review, error) {
+	// find the review
+	foundReview, err := r.reviewRepo.FindByID(ctx, reviewID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, rest.NewNotFoundError()
+		}
+		r.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+
+	// only the reviewed seller can respond
+	if foundReview.SellerID != sellerID {
+		return nil, rest.NewUnauthorizedError()
+	}
+
+	response.CreatedAt = time.Now().UTC()
+	updatedReview, err := r.reviewRepo.Respond(ctx, reviewID, response)
+	if err != nil {
+		r.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	return updatedReview, nil
+}
+
+// SellerProfile returns the score and the latest reviews of the seller
+func (r *review) SellerProfile(ctx context.Context, sellerID string) (*domain.SellerProfile, error) {
+	score, err := r.sellerRepo.FindScore(ctx, sellerID)
+	if err != nil {
+		r.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	reviews, err := r.reviewRepo.ListBySeller(ctx, sellerID, profileReviewLimit)
+	if err != nil {
+		r.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	return &domain.SellerProfile{
+		SellerScore: *score,
+		Reviews:     reviews,
+	}, nil
+}
+
+type Review interface {
+	CompleteOrder(ctx context.Context, order *domain.OrderCompletedEvent) (*domain.Order, error)
+	ReviewSeller(ctx context.Context, buyerID string, request *domain.ReviewRequest) (*domain.Review, error)
+	RespondToReview(ctx context.Context, reviewID, sellerID string, response *domain.ReviewResponse) (*domain.Review, error)
+	SellerProfile(ctx context.Context, sellerID string) (*domain.SellerProfile, error)
+}
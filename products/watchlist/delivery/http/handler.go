@@ -0,0 +1,62 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/halilylm/gommon/middlewares"
+	"github.com/halilylm/gommon/rest"
+	"github.com/halilylm/secondhand/product/watchlist/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+type watchlistHandler struct {
+	watchlistUC usecase.Watchlist
+}
+
+// NewWatchlistHandler handler for the watchlist of the user,
+// the group must already use the jwt middleware
+func NewWatchlistHandler(g *echo.Group, watchlistUC usecase.Watchlist) {
+	handler := &watchlistHandler{watchlistUC: watchlistUC}
+
+	g.GET("/", handler.ListWatchlist)
+	g.PUT("/:product_id", handler.AddToWatchlist)
+	g.DELETE("/:product_id", handler.RemoveFromWatchlist)
+}
+
+func (h *watchlistHandler) AddToWatchlist(c echo.Context) error {
+	// get user from the context
+	user := middlewares.UserFromContext(c)
+
+	// call the usecase
+	addedItem, err := h.watchlistUC.AddToWatchlist(c.Request().Context(), user.ID, c.Param("product_id"))
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, addedItem)
+}
+
+func (h *watchlistHandler) RemoveFromWatchlist(c echo.Context) error {
+	// get user from the context
+	user := middlewares.UserFromContext(c)
+
+	// call the usecase
+	if err := h.watchlistUC.RemoveFromWatchlist(c.Request().Context(), user.ID, c.Param("product_id")); err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *watchlistHandler) ListWatchlist(c echo.Context) error {
+	// get user from the context
+	user := middlewares.UserFromContext(c)
+
+	// call the usecase
+	watched, err := h.watchlistUC.ListWatchlist(c.Request().Context(), user.ID)
+	if err != nil {
+		return c.JSON(rest.ErrorResponse(err))
+	}
+
+	return c.JSON(http.StatusOK, watched)
+}
@@ -0,0 +1,103 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/halilylm/secondhand/product/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type watchlistRepository struct {
+	collection *mongo.Collection
+}
+
+// NewWatchlistRepository returns a new mongo watchlist repository
+func NewWatchlistRepository(collection *mongo.Collection) domain.WatchlistRepository {
+	return &watchlistRepository{collection: collection}
+}
+
+// CreateWatchlistIndexes creates the indexes watchlists rely on,
+// a product is watched once per user
+func CreateWatchlistIndexes(ctx context.Context, collection *mongo.Collection) error {
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "product_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "product_id", Value: 1}}},
+	})
+	return err
+}
+
+// Add watches the product for the user,
+// watching it again keeps the first item
+func (w *watchlistRepository) Add(ctx context.Context, item *domain.WatchlistItem) (*domain.WatchlistItem, error) {
+	var addedItem domain.WatchlistItem
+	res := w.collection.FindOneAndUpdate(ctx, bson.M{
+		"user_id":    item.UserID,
+		"product_id": item.ProductID,
+	}, bson.M{"$setOnInsert": bson.M{
+		"_id":        uuid.NewString(),
+		"created_at": item.CreatedAt,
+	}}, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After))
+	if res.Err() != nil {
+		return nil, res.Err()
+	}
+	if err := res.Decode(&addedItem); err != nil {
+		return nil, err
+	}
+	return &addedItem, nil
+}
+
+// Remove stops watching the product
+func (w *watchlistRepository) Remove(ctx context.Context, userID, productID string) error {
+	res, err := w.collection.DeleteOne(ctx, bson.M{"user_id": userID, "product_id": productID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// ListByUser returns the watchlist of the user, latest first
+func (w *watchlistRepository) ListByUser(ctx context.Context, userID string) ([]*domain.WatchlistItem, error) {
+	items := make([]*domain.WatchlistItem, 0)
+	cur, err := w.collection.Find(ctx, bson.M{
+		"user_id": userID,
+	}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var item domain.WatchlistItem
+		if err := cur.Decode(&item); err != nil {
+			return nil, err
+		}
+		items = append(items, &item)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// WatcherIDs returns the users watching the product
+func (w *watchlistRepository) WatcherIDs(ctx context.Context, productID string) ([]string, error) {
+	values, err := w.collection.Distinct(ctx, "user_id", bson.M{"product_id": productID})
+	if err != nil {
+		return nil, err
+	}
+	watcherIDs := make([]string, 0, len(values))
+	for _, value := range values {
+		if userID, ok := value.(string); ok {
+			watcherIDs = append(watcherIDs, userID)
+		}
+	}
+	return watcherIDs, nil
+}
@@ -0,0 +1,102 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/halilylm/gommon/logger"
+	"github.com/halilylm/gommon/rest"
+	"github.com/halilylm/secondhand/product/domain"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type watchlist struct {
+	watchlistRepo domain.WatchlistRepository
+	productRepo   domain.ProductRepository
+	logger        logger.Logger
+}
+
+// NewWatchlist returns watchlist usecase
+func NewWatchlist(watchlistRepo domain.WatchlistRepository, productRepo domain.ProductRepository, logger logger.Logger) Watchlist {
+	return &watchlist{watchlistRepo: watchlistRepo, productRepo: productRepo, logger: logger}
+}
+
+// AddToWatchlist saves the product for the user
+func (w *watchlist) AddToWatchlist(ctx context.Context, userID, productID string) (*domain.WatchlistItem, error) {
+	// find the product
+	product, err := w.productRepo.FindByID(ctx, productID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, rest.NewNotFoundError()
+		}
+		w.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	if product.ArchivedAt != nil || product.Hidden() {
+		return nil, rest.NewNotFoundError()
+	}
+
+	addedItem, err := w.watchlistRepo.Add(ctx, &domain.WatchlistItem{
+		UserID:    userID,
+		ProductID: productID,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		w.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	return addedItem, nil
+}
+
+// RemoveFromWatchlist stops watching the product
+func (w *watchlist) RemoveFromWatchlist(ctx context.Context, userID, productID string) error {
+	if err := w.watchlistRepo.Remove(ctx, userID, productID); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return rest.NewNotFoundError()
+		}
+		w.logger.Error(err)
+		return rest.NewInternalServerError()
+	}
+	return nil
+}
+
+// ListWatchlist returns the watched products of the user, latest first
+func (w *watchlist) ListWatchlist(ctx context.Context, userID string) ([]*domain.WatchedProduct, error) {
+	items, err := w.watchlistRepo.ListByUser(ctx, userID)
+	if err != nil {
+		w.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+
+	// attach the products
+	productIDs := make([]string, 0, len(items))
+	for _, item := range items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	products, err := w.productRepo.FindByIDs(ctx, productIDs)
+	if err != nil {
+		w.logger.Error(err)
+		return nil, rest.NewInternalServerError()
+	}
+	productsByID := make(map[string]*domain.Product, len(products))
+	for _, product := range products {
+		if product.ArchivedAt == nil && !product.Hidden() {
+			productsByID[product.ID] = product
+		}
+	}
+
+	watched := make([]*domain.WatchedProduct, 0, len(items))
+	for _, item := range items {
+		watched = append(watched, &domain.WatchedProduct{
+			WatchlistItem: *item,
+			Product:       productsByID[item.ProductID],
+		})
+	}
+	return watched, nil
+}
+
+type Watchlist interface {
+	AddToWatchlist(ctx context.Context, userID, productID string) (*domain.WatchlistItem, error)
+	RemoveFromWatchlist(ctx context.Context, userID, productID string) error
+	ListWatchlist(ctx context.Context, userID string) ([]*domain.WatchedProduct, error)
+}